@@ -0,0 +1,322 @@
+// Package app wire the repositories, services and http server from the config, every command
+// of the cli build the same App so the server and the tooling share one set of rules.
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/internal/domain/policy"
+	domainRepository "gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/internal/infrastructure/database"
+	"gofiber-cleanarch-test/internal/infrastructure/metrics"
+	"gofiber-cleanarch-test/internal/infrastructure/repository"
+	"gofiber-cleanarch-test/internal/infrastructure/tracing"
+	"gofiber-cleanarch-test/internal/service"
+	"gofiber-cleanarch-test/pkg/authz"
+	"gofiber-cleanarch-test/pkg/cache"
+	"gofiber-cleanarch-test/pkg/dbcluster"
+	"gofiber-cleanarch-test/pkg/health"
+	"gofiber-cleanarch-test/pkg/lifecycle"
+	"gofiber-cleanarch-test/pkg/notify"
+	"gofiber-cleanarch-test/pkg/password"
+	"gofiber-cleanarch-test/pkg/ratelimit"
+	"gofiber-cleanarch-test/pkg/secretbox"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"gofiber-cleanarch-test/pkg/workqueue"
+
+	"github.com/redis/go-redis/v9"
+)
+
+type App struct {
+	Config config.Config
+	// DB is the primary, used by migrations and everything that must not read from a replica
+	DB *sql.DB
+	// Cluster route read only transactions of the services to healthy replicas, same as DB without replicas
+	Cluster *dbcluster.Cluster
+	// Logger add request id and trace ids found in the context, use the *Context methods
+	Logger *slog.Logger
+	// Lifecycle stop components in reverse start order, the database pool and tracing are the last ones
+	Lifecycle *lifecycle.Lifecycle
+	// Health hold the readiness checks, subsystems register their own check when wired
+	Health *health.Registry
+	// Metrics is always collected, config only decide where /metrics is served
+	Metrics *metrics.Metrics
+	// RateLimiter count requests in memory or in redis, policies are applied per route group by the server
+	RateLimiter *ratelimit.Limiter
+
+	TokenRevocationStore domainRepository.TokenRevocationStore
+	TokenSigner          *tokensigner.Signer
+	Authorizer           *authz.Engine
+
+	UserService          service.UserService
+	AuthService          service.AuthService
+	RoleService          service.RoleService
+	PermissionService    service.PermissionService
+	TwoFactorService     service.TwoFactorService
+	PasswordResetService service.PasswordResetService
+}
+
+func New(cfg config.Config, logger *slog.Logger) (*App, error) {
+	// tracing first so the instrumented database driver and every component use the provider
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := database.ConnectDB(cfg.Database)
+	if err != nil {
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+
+	replicas, err := database.ConnectReplicas(cfg.Database)
+	if err != nil {
+		db.Close()
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+	closeDB := func() error {
+		errs := []error{db.Close()}
+		for _, replica := range replicas {
+			errs = append(errs, replica.Close())
+		}
+
+		return errors.Join(errs...)
+	}
+	logger.Info("database connected", "replicas", len(replicas))
+
+	clusterOpts := []dbcluster.Option{
+		dbcluster.WithCheckInterval(cfg.Database.ReplicaCheckInterval),
+		dbcluster.WithStateListener(func(replica int, healthy bool, err error) {
+			if healthy {
+				logger.Info("database replica healthy", "replica", replica)
+			} else {
+				logger.Warn("database replica unhealthy, reads use other replicas or the primary", "replica", replica, "error", err)
+			}
+		}),
+	}
+	if cfg.Database.ReplicaMaxLag > 0 {
+		clusterOpts = append(clusterOpts, dbcluster.WithChecker(database.ReplicaLagChecker(cfg.Database.ReplicaMaxLag)))
+	}
+	cluster := dbcluster.New(db, replicas, clusterOpts...)
+
+	tokenSigner, err := tokensigner.NewFromConfig(cfg.JWT.SignerConfig())
+	if err != nil {
+		closeDB()
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+
+	// one redis client shared by the cache and the rate limit counters
+	var redisClient *redis.Client
+	if cfg.Cache.Driver == "redis" || cfg.RateLimit.Store == "redis" {
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+	}
+
+	// one cache for the user lookup and the role permissions, none leave it nil
+	var appCache cache.Cache
+	switch cfg.Cache.Driver {
+	case "memory":
+		appCache = cache.NewMemory(cfg.Cache.Size)
+	case "redis":
+		appCache = cache.NewRedis(redisClient, cfg.Redis.Prefix+"cache:")
+	}
+
+	// repo init, the user cache sit below tracing so the span show cache hits
+	userRepo := repository.NewUserRepository(logger)
+	if appCache != nil {
+		userRepo = repository.NewUserRepositoryCache(userRepo, appCache, cfg.Cache.TTL, logger)
+	}
+	userRepo = repository.NewUserRepositoryTracing(userRepo)
+	roleRepo := repository.NewRoleRepository()
+	permissionRepo := repository.NewPermissionRepository()
+	refreshTokenRepo := repository.NewRefreshTokenRepository(logger)
+	twoFactorRepo := repository.NewTwoFactorRepository(logger)
+	passwordResetRepo := repository.NewPasswordResetRepository(logger)
+
+	// nil box (no key) keep the authenticator secrets in plain text
+	secretBox, err := secretbox.NewFromBase64(cfg.TwoFactor.EncryptionKey)
+	if err != nil {
+		closeDB()
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+	if secretBox == nil {
+		logger.Warn("two factor encryption key not set, authenticator secrets are stored in plain text")
+	}
+
+	// memory store only valid for single instance, use postgres (default) when running multiple instances
+	// hashes of the other algorithm or parameters are still accepted and upgraded on login
+	passwordHasher, err := password.New(cfg.PasswordHash.HasherConfig())
+	if err != nil {
+		closeDB()
+		shutdownTracing(context.Background())
+		return nil, err
+	}
+
+	passwordPolicy := cfg.PasswordPolicy.Policy(cfg.PasswordHash.Algorithm)
+	if cfg.PasswordPolicy.BreachedFile != "" {
+		start := time.Now()
+		if passwordPolicy.Breached, err = password.LoadBreachedList(cfg.PasswordPolicy.BreachedFile, cfg.PasswordPolicy.BreachedFalsePositiveRate); err != nil {
+			closeDB()
+			shutdownTracing(context.Background())
+			return nil, err
+		}
+		logger.Info("breached password list loaded", "file", cfg.PasswordPolicy.BreachedFile, "passwords", passwordPolicy.Breached.Len(), "duration", time.Since(start))
+	}
+
+	var tokenRevocationStore domainRepository.TokenRevocationStore
+	var memoryTokenRevocationStore *repository.MemoryTokenRevocationStore
+	if cfg.JWT.RevocationStore == "memory" {
+		memoryTokenRevocationStore = repository.NewMemoryTokenRevocationStore()
+		tokenRevocationStore = memoryTokenRevocationStore
+	} else {
+		tokenRevocationStore = repository.NewPostgresTokenRevocationStore(db)
+	}
+
+	// failed logins must be counted by every instance, memory is only valid for a single instance
+	var loginAttemptStore domainRepository.LoginAttemptStore
+	if cfg.LoginLockout.Store == "memory" {
+		loginAttemptStore = repository.NewMemoryLoginAttemptStore()
+	} else {
+		loginAttemptStore = repository.NewPostgresLoginAttemptStore(db)
+	}
+	loginGuard := service.NewLoginGuard(loginAttemptStore, cfg.LoginLockout, cfg.TwoFactor, logger)
+
+	// policy engine init, decision log is only for debugging policies
+	var authzOpts []authz.Option
+	if cfg.Authz.DecisionLog {
+		authzOpts = append(authzOpts, authz.WithDecisionLogger(authz.DecisionLoggerFunc(func(ctx context.Context, entry authz.DecisionEntry) {
+			logger.InfoContext(ctx, "authz decision",
+				"allowed", entry.Decision.Allowed,
+				"subject_id", entry.Subject.Id,
+				"action", entry.Action,
+				"resource_type", entry.Resource.Type,
+				"resource_id", entry.Resource.Id,
+				"reason", entry.Decision.Reason,
+			)
+		})))
+	}
+	authorizer := authz.NewEngine(authzOpts...)
+	policy.RegisterAll(authorizer)
+
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemory()
+	if cfg.RateLimit.Store == "redis" {
+		rateLimitStore = ratelimit.NewRedis(redisClient, cfg.Redis.Prefix+"ratelimit:")
+	}
+
+	// stop order is reverse: tracing is flushed after everything else is stopped
+	lc := lifecycle.New()
+	lc.Append(lifecycle.Hook{
+		Name:   "tracing",
+		OnStop: shutdownTracing,
+	})
+	lc.Append(lifecycle.Hook{
+		Name: "database",
+		OnStop: func(ctx context.Context) error {
+			return closeDB()
+		},
+	})
+	lc.Append(lifecycle.Hook{
+		Name:    "database replica checks",
+		OnStart: cluster.Start,
+		OnStop:  cluster.Stop,
+	})
+	// appended after the database so it is drained before the database is closed
+	passwordResetQueue := workqueue.New(cfg.PasswordReset.Workers, cfg.PasswordReset.QueueSize)
+	lc.Append(lifecycle.Hook{
+		Name:    "password reset queue",
+		OnStart: passwordResetQueue.Start,
+		OnStop:  passwordResetQueue.Stop,
+	})
+	lc.Append(lifecycle.Hook{
+		Name:    "login failures pruning",
+		OnStart: loginGuard.Start,
+		OnStop:  loginGuard.Stop,
+	})
+	if memoryTokenRevocationStore != nil {
+		lc.Append(lifecycle.Hook{
+			Name:    "revoked tokens pruning",
+			OnStart: memoryTokenRevocationStore.Start,
+			OnStop:  memoryTokenRevocationStore.Stop,
+		})
+	}
+	if redisClient != nil {
+		lc.Append(lifecycle.Hook{
+			Name: "redis",
+			// cache reads fall back to the database and the rate limiter let requests through while
+			// redis is down, so it does not fail the start
+			OnStart: func(ctx context.Context) error {
+				if err := redisClient.Ping(ctx).Err(); err != nil {
+					logger.Warn("redis unreachable", "addr", cfg.Redis.Addr, "error", err)
+				}
+				return nil
+			},
+			OnStop: func(ctx context.Context) error {
+				return redisClient.Close()
+			},
+		})
+	}
+
+	healthRegistry := health.NewRegistry(cfg.Health.CheckTimeout)
+	healthRegistry.Register("database", health.CheckerFunc(db.PingContext))
+	if redisClient != nil {
+		healthRegistry.Register("redis", health.CheckerFunc(func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}))
+	}
+
+	appMetrics := metrics.New()
+	appMetrics.RegisterDB(db, "postgres")
+	for i, replica := range replicas {
+		appMetrics.RegisterDB(replica, fmt.Sprintf("postgres_replica_%d", i))
+	}
+
+	// log and file sinks only fit local development, a real channel implement notify.Notifier
+	var notifier notify.Notifier = notify.NewLog(logger)
+	if cfg.Notifier.Driver == "file" {
+		notifier = notify.NewFile(cfg.Notifier.File)
+	}
+
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, userRepo, secretBox, passwordHasher, cfg.TwoFactor, cluster, logger)
+
+	return &App{
+		Config:               cfg,
+		DB:                   db,
+		Cluster:              cluster,
+		Logger:               logger,
+		Lifecycle:            lc,
+		Health:               healthRegistry,
+		Metrics:              appMetrics,
+		RateLimiter:          ratelimit.New(rateLimitStore),
+		TokenRevocationStore: tokenRevocationStore,
+		TokenSigner:          tokenSigner,
+		Authorizer:           authorizer,
+		UserService:          service.NewUserServiceTracing(service.NewUserService(userRepo, roleRepo, refreshTokenRepo, passwordResetRepo, tokenRevocationStore, loginGuard, passwordHasher, &passwordPolicy, authorizer, cfg.User, cluster, logger)),
+		AuthService:          service.NewAuthService(userRepo, refreshTokenRepo, tokenRevocationStore, tokenSigner, twoFactorService, loginGuard, passwordHasher, appMetrics, cfg.JWT, cfg.TwoFactor, cluster, logger),
+		RoleService:          service.NewRoleService(roleRepo, cluster, logger),
+		PermissionService:    service.NewPermissionService(permissionRepo, roleRepo, appCache, cfg.Cache.TTL, cluster, logger),
+		TwoFactorService:     twoFactorService,
+		PasswordResetService: service.NewPasswordResetService(passwordResetRepo, userRepo, refreshTokenRepo, tokenRevocationStore, loginGuard, passwordHasher, &passwordPolicy, notifier, passwordResetQueue, cfg.PasswordReset, cluster, logger),
+	}, nil
+}
+
+// Start run the start hooks of every registered component
+func (a *App) Start(ctx context.Context) error {
+	return a.Lifecycle.Start(ctx)
+}
+
+// Stop stop every started component in reverse order, ctx bound how long it may take
+func (a *App) Stop(ctx context.Context) error {
+	return a.Lifecycle.Stop(ctx)
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"runtime/debug"
+
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/policy"
+	"gofiber-cleanarch-test/internal/interfaces/http/controllers"
+	"gofiber-cleanarch-test/internal/interfaces/http/middleware"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/ratelimit"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/gofiber/fiber/v2/middleware/cors"
+	"github.com/gofiber/fiber/v2/middleware/helmet"
+	"github.com/gofiber/fiber/v2/middleware/recover"
+)
+
+// Server build the fiber app with every middleware and route
+func (a *App) Server() *fiber.App {
+	// controller init
+	userController := controllers.NewUserController(a.UserService)
+	authController := controllers.NewAuthController(a.AuthService)
+	twoFactorController := controllers.NewTwoFactorController(a.TwoFactorService)
+	passwordController := controllers.NewPasswordController(a.PasswordResetService)
+	roleController := controllers.NewRoleController(a.RoleService)
+	permissionController := controllers.NewPermissionController(a.PermissionService)
+	jwksController := controllers.NewJWKSController(a.TokenSigner)
+	healthController := controllers.NewHealthController(a.Health)
+
+	// middleware init
+	authMiddleware := middleware.NewAuthMiddleware(a.UserService, a.PermissionService, a.TokenRevocationStore, a.TokenSigner)
+	policyMiddleware := middleware.NewPolicyMiddleware(a.Authorizer)
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: func(c *fiber.Ctx, err error) error {
+			code := fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				code = e.Code
+			}
+
+			// the access log already recorded the error, response carry the request id to find it
+			return helper.RespondError(c, code, err.Error())
+		},
+	})
+
+	// probes and metrics are registered before the other middleware so they are not logged, traced, measured or rate limited
+	app.Get("/healthz", healthController.Liveness)
+	app.Get("/readyz", healthController.Readiness)
+
+	if a.Config.Metrics.Enabled && a.Config.Metrics.ListenAddr == "" {
+		app.Get(a.Config.Metrics.Path, adaptor.HTTPHandler(a.Metrics.Handler()))
+	}
+
+	// first middleware so rejected (rate limited, panicking, ...) requests are traced, measured and logged too
+	app.Use(middleware.RequestID())
+	app.Use(middleware.Tracing())
+	app.Use(middleware.Metrics(a.Metrics))
+	app.Use(middleware.AccessLog(a.Logger))
+	app.Use(middleware.PinPrimaryForWrites(a.Config.Database.PrimaryStickiness(), []byte(a.Config.Database.ReplicaStickinessSecret)))
+	app.Use(cors.New())
+	app.Use(helmet.New())
+	app.Use(middleware.RateLimit(middleware.RateLimitConfig{
+		Limiter:     a.RateLimiter,
+		Rules:       a.rateLimitRules(),
+		TokenSigner: a.TokenSigner,
+		APIKeys:     a.Config.RateLimit.APIKeys,
+		LimitReached: func(c *fiber.Ctx, policyName string) {
+			a.Metrics.RateLimited(policyName)
+		},
+		Logger: a.Logger,
+	}))
+	// recover will catch panics like from handler and recover the panic and throw to fiber error handler
+	app.Use(recover.New(recover.Config{
+		EnableStackTrace: true,
+		StackTraceHandler: func(c *fiber.Ctx, e interface{}) {
+			a.Logger.ErrorContext(c.UserContext(), "panic recovered", "panic", e, "stack", string(debug.Stack()))
+		},
+	}))
+
+	// app.Get("/monitor", monitor.New()) // still beta on fiber
+
+	// routing
+	app.Get("/.well-known/jwks.json", jwksController.GetJWKS)
+
+	api := app.Group("/api")
+	v1 := api.Group("/v1")
+	// below will be the endpoint with prefix /api/v1
+	v1.Get("/users", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserRead, middleware.UserResource), userController.GetAllUsers)
+	v1.Get("/users/:id", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserRead, middleware.UserResource), userController.GetUserById)
+	v1.Post("/users", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserCreate, middleware.UserResource), userController.CreateUser)
+	v1.Patch("/users/:id", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserUpdate, middleware.UserResource), userController.EditUser)
+	v1.Patch("/users/:id/password", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserChangePassword, middleware.UserResource), userController.EditUserPassword)
+	v1.Delete("/users/:id", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserDelete, middleware.UserResource), userController.DeleteUser)
+	v1.Post("/users/:id/restore", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserRestore, middleware.UserResource), userController.RestoreUser)
+	v1.Post("/users/:id/unlock", authMiddleware.IsAuth, policyMiddleware.Authorize(policy.ActionUserUnlock, middleware.UserResource), userController.UnlockUser)
+
+	v1.Get("/roles", authMiddleware.IsAuth, roleController.GetAllRoles)
+	v1.Get("/roles/:id", authMiddleware.IsAuth, roleController.GetRoleById)
+	v1.Post("/roles", authMiddleware.IsAuth, middleware.RequirePermission(entity.PermissionRolesWrite), roleController.CreateRole)
+	v1.Patch("/roles/:id", authMiddleware.IsAuth, middleware.RequirePermission(entity.PermissionRolesWrite), roleController.EditRole)
+	v1.Delete("/roles/:id", authMiddleware.IsAuth, middleware.RequirePermission(entity.PermissionRolesWrite), roleController.DeleteRole)
+	v1.Get("/roles/:id/permissions", authMiddleware.IsAuth, permissionController.GetRolePermissions)
+	v1.Put("/roles/:id/permissions", authMiddleware.IsAuth, middleware.RequirePermission(entity.PermissionRolesWrite), permissionController.SetRolePermissions)
+
+	v1.Get("/permissions", authMiddleware.IsAuth, permissionController.GetAllPermissions)
+
+	v1.Post("/login", authController.Login)
+	v1.Post("/login/2fa", authController.LoginTwoFactor)
+	v1.Post("/token/refresh", authController.RefreshToken)
+	v1.Post("/logout", authMiddleware.IsAuthFor2FASetup, authController.Logout)
+
+	v1.Post("/password/forgot", passwordController.Forgot)
+	v1.Post("/password/reset", passwordController.Reset)
+
+	v1.Post("/2fa/enroll", authMiddleware.IsAuthFor2FASetup, twoFactorController.Enroll)
+	v1.Post("/2fa/confirm", authMiddleware.IsAuthFor2FASetup, twoFactorController.Confirm)
+	v1.Post("/2fa/disable", authMiddleware.IsAuth, twoFactorController.Disable)
+
+	return app
+}
+
+// rateLimitRules give every route group its policy, the longest matching prefix win
+func (a *App) rateLimitRules() []middleware.RateLimitRule {
+	cfg := a.Config.RateLimit
+
+	return []middleware.RateLimitRule{
+		{
+			Prefix: "/",
+			Policy: ratelimit.Policy{Name: "default", Limit: cfg.Max, Window: cfg.Expiration},
+			By:     cfg.By,
+		},
+		{
+			Prefix: "/api",
+			Policy: ratelimit.Policy{Name: "api", Limit: cfg.APIMax, Window: cfg.APIExpiration},
+			By:     cfg.APIBy,
+		},
+		{
+			// also cover /api/v1/login/2fa
+			Prefix: "/api/v1/login",
+			Policy: ratelimit.Policy{Name: "login", Limit: cfg.LoginMax, Window: cfg.LoginExpiration},
+			By:     cfg.LoginBy,
+		},
+		{
+			// same policy and counters as login, guessing tokens or flooding a user with links cost the same budget
+			Prefix: "/api/v1/password",
+			Policy: ratelimit.Policy{Name: "login", Limit: cfg.LoginMax, Window: cfg.LoginExpiration},
+			By:     cfg.LoginBy,
+		},
+	}
+}
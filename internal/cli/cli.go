@@ -0,0 +1,151 @@
+// Package cli is the command line entry point, every command load the same config and use
+// the same services as the http api.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+
+	"gofiber-cleanarch-test/internal/app"
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/pkg/logging"
+)
+
+// ErrUsage is returned when the arguments are wrong, the usage is already printed
+var ErrUsage = errors.New("invalid usage")
+
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+type CLI struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	commands map[string]command
+}
+
+func New() *CLI {
+	c := &CLI{
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+
+	c.commands = map[string]command{
+		"serve":   {usage: "start the http server (default command)", run: c.serve},
+		"migrate": {usage: "up | down | status | to <version>", run: c.migrate},
+		"seed":    {usage: "insert default roles and permissions", run: c.seed},
+		"user":    {usage: "create | reset-password | unlock | list", run: c.user},
+	}
+
+	return c
+}
+
+// Run execute the command named by the first argument, no argument start the server
+func (c *CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return c.serve(ctx, nil)
+	}
+
+	name := args[0]
+	if name == "help" || name == "-h" || name == "--help" {
+		c.printUsage()
+		return nil
+	}
+
+	cmd, ok := c.commands[name]
+	if !ok {
+		fmt.Fprintf(c.Stderr, "unknown command %q\n\n", name)
+		c.printUsage()
+		return ErrUsage
+	}
+
+	return cmd.run(ctx, args[1:])
+}
+
+func (c *CLI) printUsage() {
+	names := make([]string, 0, len(c.commands))
+	for name := range c.commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(c.Stderr, "usage: <binary> <command> [arguments]")
+	fmt.Fprintln(c.Stderr)
+	fmt.Fprintln(c.Stderr, "commands:")
+	for _, name := range names {
+		fmt.Fprintf(c.Stderr, "  %-10s %s\n", name, c.commands[name].usage)
+	}
+}
+
+// newFlagSet return a flag set that report errors to stderr instead of exiting
+func (c *CLI) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.Stderr)
+
+	return fs
+}
+
+// parseFlags map flag errors and -h to ErrUsage
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return ErrUsage
+	}
+
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected arguments: %s\n", strings.Join(fs.Args(), " "))
+		fs.Usage()
+		return ErrUsage
+	}
+
+	return nil
+}
+
+// loadApp load the config, wire and start the application, caller must Stop it
+func (c *CLI) loadApp(ctx context.Context) (*app.App, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	logger, err := c.newLogger(cfg.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = a.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// newLogger build the logger on stderr and make it the default, so the standard log package and
+// libraries using slog.Default write structured lines too
+func (c *CLI) newLogger(cfg config.LogConfig) (*slog.Logger, error) {
+	logger, err := logging.New(c.Stderr, cfg.Format, cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+
+	return logger, nil
+}
@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/internal/infrastructure/database"
+	"gofiber-cleanarch-test/pkg/migrate"
+)
+
+const migrateUsage = "usage: migrate up | down | status | to <version>"
+
+func (c *CLI) migrate(ctx context.Context, args []string) error {
+	db, err := connectDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return c.runMigrate(ctx, db, args)
+}
+
+func (c *CLI) seed(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("seed")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	db, err := connectDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	names, err := database.Seed(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Fprintln(c.Stdout, "seeded", name)
+	}
+
+	return nil
+}
+
+// runMigrate handle "up|down|status|to N"
+func (c *CLI) runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(c.Stderr, migrateUsage)
+		return ErrUsage
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	var done []migrate.Migration
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		done, err = migrator.Up(ctx)
+	case args[0] == "down" && len(args) == 1:
+		done, err = migrator.Down(ctx)
+	case args[0] == "to" && len(args) == 2:
+		version, parseErr := strconv.ParseInt(args[1], 10, 64)
+		if parseErr != nil {
+			return fmt.Errorf("invalid version %q", args[1])
+		}
+		done, err = migrator.To(ctx, version)
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.Applied {
+				appliedAt = "applied " + status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(c.Stdout, "%-40s %s\n", status.Migration, appliedAt)
+		}
+		return nil
+	default:
+		fmt.Fprintln(c.Stderr, migrateUsage)
+		return ErrUsage
+	}
+
+	for _, migration := range done {
+		fmt.Fprintln(c.Stdout, "migrated", migration)
+	}
+	if err == nil && len(done) == 0 {
+		fmt.Fprintln(c.Stdout, "nothing to migrate")
+	}
+
+	return err
+}
+
+// connectDB only need the database settings, so migrate and seed work before keys are configured
+func connectDB() (*sql.DB, error) {
+	cfg, err := config.LoadDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	return database.ConnectDB(cfg)
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"gofiber-cleanarch-test/internal/app"
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/pkg/lifecycle"
+)
+
+func (c *CLI) serve(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("serve")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	logger, err := c.newLogger(cfg.Log)
+	if err != nil {
+		return err
+	}
+
+	a, err := app.New(cfg, logger)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Database.AutoMigrate {
+		a.Lifecycle.Append(lifecycle.Hook{
+			Name: "migrations",
+			OnStart: func(ctx context.Context) error {
+				return c.runMigrate(ctx, a.DB, []string{"up"})
+			},
+		})
+	}
+
+	// listen synchronously so a busy port fail the start, then serve in background
+	server := a.Server()
+	serveErr := make(chan error, 1)
+	a.Lifecycle.Append(lifecycle.Hook{
+		Name: "http server",
+		OnStart: func(ctx context.Context) error {
+			ln, err := net.Listen("tcp", cfg.App.ListenAddr)
+			if err != nil {
+				return err
+			}
+
+			go func() { serveErr <- server.Listener(ln) }()
+
+			return nil
+		},
+		// stop accepting connections and wait for in-flight requests until ctx deadline
+		OnStop: server.ShutdownWithContext,
+	})
+
+	// internal listener keep /metrics off the public port
+	if cfg.Metrics.Enabled && cfg.Metrics.ListenAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle(cfg.Metrics.Path, a.Metrics.Handler())
+		metricsServer := &http.Server{Addr: cfg.Metrics.ListenAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+
+		a.Lifecycle.Append(lifecycle.Hook{
+			Name: "metrics server",
+			OnStart: func(ctx context.Context) error {
+				ln, err := net.Listen("tcp", cfg.Metrics.ListenAddr)
+				if err != nil {
+					return err
+				}
+
+				go func() {
+					if err := metricsServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+						serveErr <- err
+					}
+				}()
+
+				return nil
+			},
+			OnStop: metricsServer.Shutdown,
+		})
+	}
+
+	// appended last so it is stopped first: readiness fail while requests are still served
+	a.Lifecycle.Append(lifecycle.Hook{
+		Name: "readiness",
+		OnStop: func(ctx context.Context) error {
+			a.Health.SetShuttingDown()
+
+			select {
+			case <-time.After(cfg.App.ShutdownDelay):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+	})
+
+	ctx, stopSignal := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stopSignal()
+
+	if err = a.Start(ctx); err != nil {
+		return err
+	}
+	logger.Info("server started", "addr", cfg.App.ListenAddr)
+
+	select {
+	case <-ctx.Done():
+		logger.Info("shutting down", "timeout", cfg.App.ShutdownTimeout)
+	case err = <-serveErr:
+	}
+	// a second signal kill the process right away
+	stopSignal()
+
+	stopCtx, cancel := context.WithTimeout(context.Background(), cfg.App.ShutdownTimeout)
+	defer cancel()
+
+	return errors.Join(err, a.Stop(stopCtx))
+}
@@ -0,0 +1,282 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gofiber-cleanarch-test/internal/app"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+
+	"github.com/go-playground/validator/v10"
+	"golang.org/x/term"
+)
+
+const userUsage = `usage:
+  user create --username <name> [--password <password>] [--role <name or id>]
+  user reset-password (--username <name> | --id <id>) [--password <password>]
+  user unlock (--username <name> | --id <id>)
+  user list [--page <n>] [--per-page <n>]
+
+password is prompted when not given as flag`
+
+func (c *CLI) user(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintln(c.Stderr, userUsage)
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "create":
+		return c.userCreate(ctx, args[1:])
+	case "reset-password":
+		return c.userResetPassword(ctx, args[1:])
+	case "unlock":
+		return c.userUnlock(ctx, args[1:])
+	case "list":
+		return c.userList(ctx, args[1:])
+	default:
+		fmt.Fprintln(c.Stderr, userUsage)
+		return ErrUsage
+	}
+}
+
+func (c *CLI) userCreate(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("user create")
+	username := fs.String("username", "", "username of the new user")
+	password := fs.String("password", "", "password of the new user, prompted when empty")
+	roleArg := fs.String("role", "user", "role name or id, e.g. superadmin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	a, err := c.loadApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Stop(context.Background())
+
+	role, err := findRole(ctx, a, *roleArg)
+	if err != nil {
+		return err
+	}
+
+	if *password == "" {
+		if *password, err = c.readPassword(); err != nil {
+			return err
+		}
+	}
+
+	// same validation as POST /users
+	input := &dto.UserCreate{
+		Username: *username,
+		Password: *password,
+		Role:     role.Id,
+	}
+	if err = validateInput(input); err != nil {
+		return err
+	}
+
+	user, err := a.UserService.Create(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "created user %s (id %d, role %s)\n", user.Username, user.Id, user.RoleName)
+
+	return nil
+}
+
+func (c *CLI) userResetPassword(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("user reset-password")
+	username := fs.String("username", "", "username of the user")
+	id := fs.Int("id", 0, "id of the user")
+	password := fs.String("password", "", "new password, prompted when empty")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if (*username == "") == (*id == 0) {
+		fmt.Fprintln(c.Stderr, "exactly one of --username or --id is required")
+		return ErrUsage
+	}
+
+	a, err := c.loadApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Stop(context.Background())
+
+	if *username != "" {
+		user, err := a.UserService.FindByUsername(ctx, *username)
+		if err != nil {
+			return err
+		}
+		*id = user.Id
+	}
+
+	if *password == "" {
+		if *password, err = c.readPassword(); err != nil {
+			return err
+		}
+	}
+
+	input := &dto.UserResetPassword{
+		Id:       *id,
+		Password: *password,
+	}
+	if err = validateInput(input); err != nil {
+		return err
+	}
+
+	// existing sessions of the user are revoked by the service
+	if err = a.UserService.ResetPassword(ctx, input); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "password of user %d changed, existing sessions revoked\n", *id)
+
+	return nil
+}
+
+func (c *CLI) userUnlock(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("user unlock")
+	username := fs.String("username", "", "username of the user")
+	id := fs.Int("id", 0, "id of the user")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if (*username == "") == (*id == 0) {
+		fmt.Fprintln(c.Stderr, "exactly one of --username or --id is required")
+		return ErrUsage
+	}
+
+	a, err := c.loadApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Stop(context.Background())
+
+	if *username != "" {
+		user, err := a.UserService.FindByUsername(ctx, *username)
+		if err != nil {
+			return err
+		}
+		*id = user.Id
+	}
+
+	// the failures of the ip are kept, only the account lockout is lifted
+	if err = a.UserService.Unlock(ctx, *id); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "login of user %d unlocked\n", *id)
+
+	return nil
+}
+
+func (c *CLI) userList(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("user list")
+	page := fs.Int("page", 1, "page number")
+	perPage := fs.Int("per-page", 20, "users per page")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	if *page < 1 || *perPage < 1 {
+		fmt.Fprintln(c.Stderr, "--page and --per-page must be at least 1")
+		return ErrUsage
+	}
+
+	a, err := c.loadApp(ctx)
+	if err != nil {
+		return err
+	}
+	defer a.Stop(context.Background())
+
+	res, err := a.UserService.FindAllWithPagination(ctx, *perPage, (*page-1)**perPage)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tROLE\tCREATED AT")
+	for _, user := range res.Data.([]dto.UserResponse) {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", user.Id, user.Username, user.RoleName, user.CreatedAt)
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.Stdout, "page %d, %d users in total\n", *page, res.TotalData)
+
+	return nil
+}
+
+// findRole accept the role id or its name case insensitive
+func findRole(ctx context.Context, a *app.App, value string) (dto.RoleResponse, error) {
+	if id, err := strconv.Atoi(value); err == nil {
+		return a.RoleService.FindById(ctx, id)
+	}
+
+	return a.RoleService.FindByName(ctx, value)
+}
+
+// readPassword prompt without echo on a terminal, otherwise read one line from stdin
+func (c *CLI) readPassword() (string, error) {
+	if f, ok := c.Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(c.Stderr, "Password: ")
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(c.Stderr)
+		if err != nil {
+			return "", err
+		}
+
+		fmt.Fprint(c.Stderr, "Confirm password: ")
+		confirm, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(c.Stderr)
+		if err != nil {
+			return "", err
+		}
+
+		if string(password) != string(confirm) {
+			return "", errors.New("passwords do not match")
+		}
+
+		return string(password), nil
+	}
+
+	line, err := bufio.NewReader(c.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// validateInput run the dto validation used by the http controllers and list every failed field
+func validateInput(input interface{}) error {
+	err := helper.ValidateStruct(input)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	problems := make([]string, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		problems = append(problems, fmt.Sprintf("%s failed on %s", fieldErr.Field(), fieldErr.Tag()))
+	}
+
+	return fmt.Errorf("invalid input: %s", strings.Join(problems, ", "))
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultConfigFile = "config.yaml"
+
+// Load read defaults, YAML file, .env and environment, then validate the result
+func Load() (Config, error) {
+	cfg, err := load()
+	if err != nil {
+		return cfg, err
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+// LoadDatabase is Load that only validate the database settings
+func LoadDatabase() (DatabaseConfig, error) {
+	cfg, err := load()
+	if err != nil {
+		return cfg.Database, err
+	}
+
+	if err = cfg.Database.Validate(); err != nil {
+		return cfg.Database, err
+	}
+
+	return cfg.Database, nil
+}
+
+func load() (Config, error) {
+	cfg := Default()
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return cfg, fmt.Errorf("config: load .env: %w", err)
+	}
+
+	// YAML file is optional unless set explicitly
+	path, explicit := os.LookupEnv("CONFIG_FILE")
+	if !explicit {
+		path = defaultConfigFile
+	}
+
+	if err := loadYAML(path, &cfg); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) || explicit {
+			return cfg, err
+		}
+	}
+
+	if err := loadEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return cfg, err
+	}
+
+	if err := loadVerificationKeysEnv(&cfg.JWT); err != nil {
+		return cfg, err
+	}
+
+	return cfg, nil
+}
+
+func loadYAML(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: read %s: %w", path, err)
+	}
+
+	if err = yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("config: parse %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// loadEnv walk the struct and set every field that has env tag and the variable is set (also when empty)
+func loadEnv(v reflect.Value) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		fieldType := t.Field(i)
+
+		if fieldType.Type.Kind() == reflect.Struct {
+			if err := loadEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		key := fieldType.Tag.Get("env")
+		if key == "" {
+			continue
+		}
+
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("config: %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	// empty value keep the default for non string field, so "KEY=" in .env does not break parsing
+	if value == "" && field.Kind() != reflect.String {
+		return nil
+	}
+
+	switch {
+	case field.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
+
+func loadVerificationKeysEnv(cfg *JWTConfig) error {
+	value := strings.TrimSpace(os.Getenv("JWT_VERIFICATION_KEYS"))
+	if value == "" {
+		return nil
+	}
+
+	cfg.VerificationKeys = nil
+	for _, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("config: JWT_VERIFICATION_KEYS: entry %q must be kid:alg:value", entry)
+		}
+
+		key := VerificationKeyConfig{Id: parts[0], Algorithm: parts[1]}
+		if key.Algorithm == "HS256" {
+			key.Secret = parts[2]
+		} else {
+			key.PublicKeyFile = parts[2]
+		}
+
+		cfg.VerificationKeys = append(cfg.VerificationKeys, key)
+	}
+
+	return nil
+}
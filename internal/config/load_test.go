@@ -0,0 +1,184 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		env   map[string]string
+		check func(cfg Config) bool
+	}{
+		{
+			name:  "string",
+			env:   map[string]string{"APP_LISTEN_ADDR": " :4000 "},
+			check: func(cfg Config) bool { return cfg.App.ListenAddr == ":4000" },
+		},
+		{
+			name:  "empty string",
+			env:   map[string]string{"JWT_AUDIENCE": ""},
+			check: func(cfg Config) bool { return cfg.JWT.Audience == "" },
+		},
+		{
+			name:  "int",
+			env:   map[string]string{"RATE_LIMIT_MAX": "42"},
+			check: func(cfg Config) bool { return cfg.RateLimit.Max == 42 },
+		},
+		{
+			name:  "duration",
+			env:   map[string]string{"JWT_ACCESS_TOKEN_TTL": "5m"},
+			check: func(cfg Config) bool { return cfg.JWT.AccessTokenTTL == 5*time.Minute },
+		},
+		{
+			name:  "bool",
+			env:   map[string]string{"DB_AUTO_MIGRATE": "false"},
+			check: func(cfg Config) bool { return !cfg.Database.AutoMigrate },
+		},
+		{
+			name: "float",
+			env:  map[string]string{"PASSWORD_POLICY_BREACHED_FALSE_POSITIVE_RATE": "0.01"},
+			check: func(cfg Config) bool {
+				return cfg.PasswordPolicy.BreachedFalsePositiveRate == 0.01
+			},
+		},
+		{
+			name: "list",
+			env:  map[string]string{"JWT_ALLOWED_ALGORITHMS": "HS256, RS256,"},
+			check: func(cfg Config) bool {
+				return reflect.DeepEqual(cfg.JWT.AllowedAlgorithms, []string{"HS256", "RS256"})
+			},
+		},
+		{
+			name: "empty value keep the default",
+			env:  map[string]string{"JWT_REFRESH_TOKEN_TTL": "", "LOGIN_LOCKOUT_THRESHOLD": " "},
+			check: func(cfg Config) bool {
+				return cfg.JWT.RefreshTokenTTL == Default().JWT.RefreshTokenTTL && cfg.LoginLockout.Threshold == Default().LoginLockout.Threshold
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg := Default()
+			if err := loadEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+				t.Fatalf("loadEnv: %v", err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("env %v not applied", tt.env)
+			}
+		})
+	}
+}
+
+func TestLoadEnvInvalid(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{"JWT_ACCESS_TOKEN_TTL", "15"},
+		{"JWT_ACCESS_TOKEN_TTL", "5 minutes"},
+		{"RATE_LIMIT_MAX", "ten"},
+		{"DB_AUTO_MIGRATE", "maybe"},
+		{"PASSWORD_POLICY_BREACHED_FALSE_POSITIVE_RATE", "1%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.key, tt.value)
+
+			cfg := Default()
+			err := loadEnv(reflect.ValueOf(&cfg).Elem())
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("error %q does not name %s", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestLoadYAMLThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yaml := "app:\n  listen_addr: \":5000\"\njwt:\n  issuer: yaml-issuer\n  access_token_ttl: 2m\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("APP_LISTEN_ADDR", ":6000")
+
+	cfg, err := load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// env win over the file, the file win over the defaults
+	if cfg.App.ListenAddr != ":6000" {
+		t.Errorf("listen_addr = %q, want the env value", cfg.App.ListenAddr)
+	}
+	if cfg.JWT.Issuer != "yaml-issuer" || cfg.JWT.AccessTokenTTL != 2*time.Minute {
+		t.Errorf("jwt = %q %v, want the file values", cfg.JWT.Issuer, cfg.JWT.AccessTokenTTL)
+	}
+	if cfg.JWT.RefreshTokenTTL != Default().JWT.RefreshTokenTTL {
+		t.Errorf("refresh_token_ttl = %v, want the default", cfg.JWT.RefreshTokenTTL)
+	}
+
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err = load(); err == nil {
+		t.Error("a missing file set with CONFIG_FILE should fail")
+	}
+}
+
+func TestLoadVerificationKeysEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []VerificationKeyConfig
+		wantErr bool
+	}{
+		{
+			name:  "unset keep the file keys",
+			value: "",
+			want:  []VerificationKeyConfig{{Id: "file", Algorithm: "HS256", Secret: "from-file"}},
+		},
+		{
+			name:  "secret and public key file",
+			value: "old:HS256:s3cr:et, rsa:RS256:/keys/rsa.pem",
+			want: []VerificationKeyConfig{
+				{Id: "old", Algorithm: "HS256", Secret: "s3cr:et"},
+				{Id: "rsa", Algorithm: "RS256", PublicKeyFile: "/keys/rsa.pem"},
+			},
+		},
+		{
+			name:    "missing value",
+			value:   "old:HS256",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("JWT_VERIFICATION_KEYS", tt.value)
+
+			cfg := JWTConfig{VerificationKeys: []VerificationKeyConfig{{Id: "file", Algorithm: "HS256", Secret: "from-file"}}}
+			err := loadVerificationKeysEnv(&cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(cfg.VerificationKeys, tt.want) {
+				t.Errorf("keys = %+v, want %+v", cfg.VerificationKeys, tt.want)
+			}
+		})
+	}
+}
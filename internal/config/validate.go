@@ -0,0 +1,334 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gofiber-cleanarch-test/pkg/password"
+	"gofiber-cleanarch-test/pkg/secretbox"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ValidationError list every problem at once so startup does not fail one setting at a time
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "config: invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+func (c Config) Validate() error {
+	var problems []string
+
+	missing := func(name, env string) {
+		problems = append(problems, fmt.Sprintf("%s is required (env %s)", name, env))
+	}
+
+	if c.App.ListenAddr == "" {
+		missing("app.listen_addr", "APP_LISTEN_ADDR")
+	}
+	if c.App.ShutdownTimeout <= 0 {
+		problems = append(problems, "app.shutdown_timeout must be positive (env APP_SHUTDOWN_TIMEOUT)")
+	}
+	if c.App.ShutdownDelay < 0 {
+		problems = append(problems, "app.shutdown_delay can not be negative (env APP_SHUTDOWN_DELAY)")
+	}
+
+	problems = append(problems, c.Database.problems()...)
+
+	switch c.JWT.Algorithm {
+	case tokensigner.AlgorithmHS256:
+		if c.JWT.Secret == "" {
+			missing("jwt.secret", "JWT_SECRET")
+		}
+	case tokensigner.AlgorithmRS256, tokensigner.AlgorithmEdDSA:
+		if c.JWT.PrivateKeyFile == "" {
+			missing("jwt.private_key_file", "JWT_PRIVATE_KEY_FILE")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("jwt.algorithm %q is not supported, use HS256, RS256 or EdDSA (env JWT_ALGORITHM)", c.JWT.Algorithm))
+	}
+
+	if c.JWT.KeyId == "" {
+		missing("jwt.key_id", "JWT_KEY_ID")
+	}
+	if c.JWT.AccessTokenTTL <= 0 {
+		problems = append(problems, "jwt.access_token_ttl must be positive (env JWT_ACCESS_TOKEN_TTL)")
+	}
+	if c.JWT.RefreshTokenTTL <= 0 {
+		problems = append(problems, "jwt.refresh_token_ttl must be positive (env JWT_REFRESH_TOKEN_TTL)")
+	}
+	if c.JWT.RevocationStore != "postgres" && c.JWT.RevocationStore != "memory" {
+		problems = append(problems, fmt.Sprintf("jwt.revocation_store %q must be postgres or memory (env TOKEN_REVOCATION_STORE)", c.JWT.RevocationStore))
+	}
+
+	switch c.RateLimit.Store {
+	case "memory", "redis":
+	default:
+		problems = append(problems, fmt.Sprintf("rate_limit.store %q must be memory or redis (env RATE_LIMIT_STORE)", c.RateLimit.Store))
+	}
+	for _, policy := range []struct {
+		name, env  string
+		max        int
+		expiration time.Duration
+		by         string
+	}{
+		{"", "", c.RateLimit.Max, c.RateLimit.Expiration, c.RateLimit.By},
+		{"api_", "API_", c.RateLimit.APIMax, c.RateLimit.APIExpiration, c.RateLimit.APIBy},
+		{"login_", "LOGIN_", c.RateLimit.LoginMax, c.RateLimit.LoginExpiration, c.RateLimit.LoginBy},
+	} {
+		if policy.max < 1 {
+			problems = append(problems, fmt.Sprintf("rate_limit.%smax must be at least 1 (env RATE_LIMIT_%sMAX)", policy.name, policy.env))
+		}
+		if policy.expiration <= 0 {
+			problems = append(problems, fmt.Sprintf("rate_limit.%sexpiration must be positive (env RATE_LIMIT_%sEXPIRATION)", policy.name, policy.env))
+		}
+		switch policy.by {
+		case "ip", "user":
+		case "api_key":
+			if len(c.RateLimit.APIKeys) == 0 {
+				problems = append(problems, fmt.Sprintf("rate_limit.%sby api_key need rate_limit.api_keys (env RATE_LIMIT_API_KEYS)", policy.name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("rate_limit.%sby %q must be ip, user or api_key (env RATE_LIMIT_%sBY)", policy.name, policy.by, policy.env))
+		}
+	}
+	for i, hash := range c.RateLimit.APIKeys {
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != hex.EncodedLen(sha256.Size) {
+			problems = append(problems, fmt.Sprintf("rate_limit.api_keys[%d] must be the hex SHA-256 of a key (env RATE_LIMIT_API_KEYS)", i))
+		}
+	}
+
+	switch c.LoginLockout.Store {
+	case "postgres", "memory":
+	default:
+		problems = append(problems, fmt.Sprintf("login_lockout.store %q must be postgres or memory (env LOGIN_LOCKOUT_STORE)", c.LoginLockout.Store))
+	}
+	if c.LoginLockout.Window <= 0 {
+		problems = append(problems, "login_lockout.window must be positive (env LOGIN_LOCKOUT_WINDOW)")
+	}
+	if c.LoginLockout.PruneInterval <= 0 {
+		problems = append(problems, "login_lockout.prune_interval must be positive (env LOGIN_LOCKOUT_PRUNE_INTERVAL)")
+	}
+	if c.LoginLockout.DelayAfter < 0 {
+		problems = append(problems, "login_lockout.delay_after can not be negative (env LOGIN_LOCKOUT_DELAY_AFTER)")
+	}
+	if c.LoginLockout.DelayAfter > 0 && c.LoginLockout.Delay <= 0 {
+		problems = append(problems, "login_lockout.delay must be positive when delay_after is set (env LOGIN_LOCKOUT_DELAY)")
+	}
+	if c.LoginLockout.MaxDelay < c.LoginLockout.Delay {
+		problems = append(problems, "login_lockout.max_delay can not be lower than login_lockout.delay (env LOGIN_LOCKOUT_MAX_DELAY)")
+	}
+	// the failures are forgotten after the window, a longer block would end early
+	if c.LoginLockout.MaxDelay > c.LoginLockout.Window {
+		problems = append(problems, "login_lockout.max_delay can not be longer than login_lockout.window (env LOGIN_LOCKOUT_MAX_DELAY)")
+	}
+	for _, lock := range []struct {
+		name, env string
+		threshold int
+		duration  time.Duration
+	}{
+		{"", "", c.LoginLockout.Threshold, c.LoginLockout.Duration},
+		{"ip_", "IP_", c.LoginLockout.IPThreshold, c.LoginLockout.IPDuration},
+	} {
+		if lock.threshold < 0 {
+			problems = append(problems, fmt.Sprintf("login_lockout.%sthreshold can not be negative (env LOGIN_LOCKOUT_%sTHRESHOLD)", lock.name, lock.env))
+		}
+		if lock.threshold > 0 && lock.duration <= 0 {
+			problems = append(problems, fmt.Sprintf("login_lockout.%sduration must be positive when %sthreshold is set (env LOGIN_LOCKOUT_%sDURATION)", lock.name, lock.name, lock.env))
+		}
+		if lock.duration > c.LoginLockout.Window {
+			problems = append(problems, fmt.Sprintf("login_lockout.%sduration can not be longer than login_lockout.window (env LOGIN_LOCKOUT_%sDURATION)", lock.name, lock.env))
+		}
+	}
+
+	switch {
+	case c.PasswordHash.Argon2Memory < 0 || c.PasswordHash.Argon2Memory > math.MaxUint32:
+		problems = append(problems, "password_hash.argon2_memory is out of range (env PASSWORD_HASH_ARGON2_MEMORY)")
+	case c.PasswordHash.Argon2Time < 0 || c.PasswordHash.Argon2Time > math.MaxUint32:
+		problems = append(problems, "password_hash.argon2_time is out of range (env PASSWORD_HASH_ARGON2_TIME)")
+	case c.PasswordHash.Argon2Threads < 0 || c.PasswordHash.Argon2Threads > math.MaxUint8:
+		problems = append(problems, "password_hash.argon2_threads must be between 1 and 255 (env PASSWORD_HASH_ARGON2_THREADS)")
+	default:
+		if _, err := password.New(c.PasswordHash.HasherConfig()); err != nil {
+			problems = append(problems, fmt.Sprintf("password_hash: %v (env PASSWORD_HASH_*)", err))
+		}
+	}
+
+	if c.PasswordPolicy.MinLength < 1 {
+		problems = append(problems, "password_policy.min_length must be at least 1 (env PASSWORD_POLICY_MIN_LENGTH)")
+	}
+	if c.PasswordPolicy.MaxLength < c.PasswordPolicy.MinLength {
+		problems = append(problems, "password_policy.max_length can not be lower than password_policy.min_length (env PASSWORD_POLICY_MAX_LENGTH)")
+	}
+	// bcrypt refuse passwords over 72 bytes, the policy also check the bytes for non ascii characters
+	if c.PasswordHash.Algorithm == password.AlgorithmBcrypt && c.PasswordPolicy.MaxLength > password.BcryptMaxBytes {
+		problems = append(problems, fmt.Sprintf("password_policy.max_length must be at most %d with bcrypt (env PASSWORD_POLICY_MAX_LENGTH)", password.BcryptMaxBytes))
+	}
+	if c.PasswordPolicy.BreachedFile != "" && (c.PasswordPolicy.BreachedFalsePositiveRate <= 0 || c.PasswordPolicy.BreachedFalsePositiveRate >= 1) {
+		problems = append(problems, "password_policy.breached_false_positive_rate must be between 0 and 1 exclusive (env PASSWORD_POLICY_BREACHED_FALSE_POSITIVE_RATE)")
+	}
+
+	if c.Health.CheckTimeout <= 0 {
+		problems = append(problems, "health.check_timeout must be positive (env HEALTH_CHECK_TIMEOUT)")
+	}
+
+	if c.Metrics.Enabled && !strings.HasPrefix(c.Metrics.Path, "/") {
+		problems = append(problems, "metrics.path must start with / (env METRICS_PATH)")
+	}
+	if c.Metrics.Enabled && c.Metrics.ListenAddr != "" && c.Metrics.ListenAddr == c.App.ListenAddr {
+		problems = append(problems, "metrics.listen_addr must differ from app.listen_addr, leave it empty to serve metrics on the main listener (env METRICS_LISTEN_ADDR)")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	case "file":
+		if c.Tracing.File == "" {
+			missing("tracing.file", "TRACING_FILE")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("tracing.exporter %q must be none, otlp, stdout or file (env TRACING_EXPORTER)", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		problems = append(problems, "tracing.sample_ratio must be between 0 and 1 (env TRACING_SAMPLE_RATIO)")
+	}
+
+	switch c.Cache.Driver {
+	case "none":
+	case "memory":
+		if c.Cache.Size < 1 {
+			problems = append(problems, "cache.size must be at least 1 (env CACHE_SIZE)")
+		}
+	case "redis":
+	default:
+		problems = append(problems, fmt.Sprintf("cache.driver %q must be none, memory or redis (env CACHE_DRIVER)", c.Cache.Driver))
+	}
+	if c.Cache.Driver != "none" && c.Cache.TTL <= 0 {
+		problems = append(problems, "cache.ttl must be positive (env CACHE_TTL)")
+	}
+
+	if (c.Cache.Driver == "redis" || c.RateLimit.Store == "redis") && c.Redis.Addr == "" {
+		missing("redis.addr", "REDIS_ADDR")
+	}
+
+	if c.TwoFactor.Issuer == "" {
+		missing("two_factor.issuer", "TWO_FACTOR_ISSUER")
+	}
+	if c.TwoFactor.ChallengeTTL <= 0 {
+		problems = append(problems, "two_factor.challenge_ttl must be positive (env TWO_FACTOR_CHALLENGE_TTL)")
+	}
+	if c.TwoFactor.ChallengeMaxAttempts < 1 {
+		problems = append(problems, "two_factor.challenge_max_attempts must be at least 1 (env TWO_FACTOR_CHALLENGE_MAX_ATTEMPTS)")
+	}
+	if _, err := secretbox.NewFromBase64(c.TwoFactor.EncryptionKey); err != nil {
+		problems = append(problems, fmt.Sprintf("two_factor.encryption_key must be the base64 of %d bytes (env TWO_FACTOR_ENCRYPTION_KEY)", secretbox.KeySize))
+	}
+
+	if c.PasswordReset.TokenTTL <= 0 {
+		problems = append(problems, "password_reset.token_ttl must be positive (env PASSWORD_RESET_TOKEN_TTL)")
+	}
+	if c.PasswordReset.Workers < 1 {
+		problems = append(problems, "password_reset.workers must be at least 1 (env PASSWORD_RESET_WORKERS)")
+	}
+	if c.PasswordReset.QueueSize < 1 {
+		problems = append(problems, "password_reset.queue_size must be at least 1 (env PASSWORD_RESET_QUEUE_SIZE)")
+	}
+	if c.PasswordReset.URL != "" {
+		if u, err := url.Parse(c.PasswordReset.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			problems = append(problems, fmt.Sprintf("password_reset.url %q must be an absolute url (env PASSWORD_RESET_URL)", c.PasswordReset.URL))
+		}
+	}
+
+	switch c.Notifier.Driver {
+	case "log":
+	case "file":
+		if c.Notifier.File == "" {
+			missing("notifier.file", "NOTIFIER_FILE")
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("notifier.driver %q must be log or file (env NOTIFIER_DRIVER)", c.Notifier.Driver))
+	}
+
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		problems = append(problems, fmt.Sprintf("log.format %q must be json or text (env LOG_FORMAT)", c.Log.Format))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log.level %q must be debug, info, warn or error (env LOG_LEVEL)", c.Log.Level))
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// Validate only check the database settings, used by commands that do not need the rest
+func (c DatabaseConfig) Validate() error {
+	if problems := c.problems(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func (c DatabaseConfig) problems() []string {
+	var problems []string
+
+	missing := func(name, env string) {
+		problems = append(problems, fmt.Sprintf("%s is required (env %s)", name, env))
+	}
+
+	// connection fields are only needed without a dsn
+	if c.DSN == "" {
+		if c.Host == "" {
+			missing("database.host", "HOST_POSTGRES")
+		}
+		if c.Port == "" {
+			missing("database.port", "PORT_POSTGRES")
+		}
+		if c.User == "" {
+			missing("database.user", "USER_POSTGRES")
+		}
+		if c.Name == "" {
+			missing("database.name", "DATABASE_POSTGRES")
+		}
+	}
+	if c.MaxOpenConns < 1 {
+		problems = append(problems, "database.max_open_conns must be at least 1 (env DB_MAX_OPEN_CONNS)")
+	}
+	if c.MaxIdleConns < 0 {
+		problems = append(problems, "database.max_idle_conns can not be negative (env DB_MAX_IDLE_CONNS)")
+	}
+	for i, dsn := range c.ReplicaDSNs {
+		if strings.TrimSpace(dsn) == "" {
+			problems = append(problems, fmt.Sprintf("database.replica_dsns[%d] is empty (env DB_REPLICA_DSNS)", i))
+		}
+	}
+	if len(c.ReplicaDSNs) > 0 && c.ReplicaCheckInterval <= 0 {
+		problems = append(problems, "database.replica_check_interval must be positive (env DB_REPLICA_CHECK_INTERVAL)")
+	}
+	if c.ReplicaMaxLag < 0 {
+		problems = append(problems, "database.replica_max_lag can not be negative (env DB_REPLICA_MAX_LAG)")
+	}
+	if c.ReplicaStickiness < 0 {
+		problems = append(problems, "database.replica_stickiness can not be negative (env DB_REPLICA_STICKINESS)")
+	}
+	if c.ReplicaStickiness > MaxPrimaryStickiness {
+		problems = append(problems, fmt.Sprintf("database.replica_stickiness can not be longer than %s (env DB_REPLICA_STICKINESS)", MaxPrimaryStickiness))
+	}
+	if c.ReplicaStickinessSecret != "" && len(c.ReplicaStickinessSecret) < 32 {
+		problems = append(problems, "database.replica_stickiness_secret must be at least 32 bytes (env DB_REPLICA_STICKINESS_SECRET)")
+	}
+
+	return problems
+}
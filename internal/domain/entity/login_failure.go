@@ -0,0 +1,10 @@
+package entity
+
+import "time"
+
+// LoginFailure count the recent failed logins of an account or an ip
+type LoginFailure struct {
+	Key           string    `json:"key"`
+	Count         int       `json:"count"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
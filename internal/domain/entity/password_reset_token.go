@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// PasswordResetToken let a user set a new password without the old one, it is used once
+type PasswordResetToken struct {
+	Id        int        `json:"id"`
+	UserId    int        `json:"user_id"`
+	TokenHash string     `json:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
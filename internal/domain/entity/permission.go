@@ -0,0 +1,16 @@
+package entity
+
+// permission names seeded by migration
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionUsersWrite  = "users:write"
+	PermissionUsersDelete = "users:delete"
+	PermissionUsersPurge  = "users:purge"
+	PermissionRolesWrite  = "roles:write"
+)
+
+type Permission struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
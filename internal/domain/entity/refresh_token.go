@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+type RefreshToken struct {
+	Id        int        `json:"id"`
+	UserId    int        `json:"user_id"`
+	FamilyId  string     `json:"family_id"`
+	TokenHash string     `json:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	RotatedAt *time.Time `json:"rotated_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+	// MFA is set when the login of the family completed a second factor
+	MFA bool `json:"mfa"`
+}
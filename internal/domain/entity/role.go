@@ -0,0 +1,15 @@
+package entity
+
+// id of the default roles seeded by migration
+const (
+	RoleAdmin      = 1
+	RoleUser       = 2
+	RoleSuperAdmin = 3
+)
+
+type Role struct {
+	Id   int    `json:"id"`
+	Name string `json:"name"`
+	// Require2FA make users of the role complete two factor authentication before using the api
+	Require2FA bool `json:"require_2fa"`
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// UserTOTP is the authenticator enrolled by a user, it is only used once confirmed
+type UserTOTP struct {
+	UserId int `json:"user_id"`
+	// Secret is the base32 key, sealed when an encryption key is configured
+	Secret      string     `json:"secret"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+	// LastUsedStep is the time step of the last accepted code, older or equal steps are refused
+	LastUsedStep int64     `json:"last_used_step"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -1,11 +1,15 @@
 package entity
 
 type User struct {
-	Id        int    `json:"id"`
-	Username  string `json:"username"`
-	Password  string `json:"password"`
-	Role      int    `json:"role"`
-	CreatedAt string `json:"created_at"`
-	UpdatedAt string `json:"updated_at"`
-	IsDeleted bool   `json:"is_deleted"`
+	Id        int     `json:"id"`
+	Username  string  `json:"username"`
+	Password  string  `json:"password"`
+	Role      int     `json:"role"`
+	RoleName  string  `json:"role_name"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+	IsDeleted bool    `json:"is_deleted"`
+	DeletedAt *string `json:"deleted_at"`
+	// RoleRequire2FA is Require2FA of the user role
+	RoleRequire2FA bool `json:"role_require_2fa"`
 }
@@ -0,0 +1,20 @@
+package policy
+
+import (
+	"gofiber-cleanarch-test/pkg/authz"
+	"strconv"
+)
+
+// RegisterAll register the policy of every domain type to the engine
+func RegisterAll(engine *authz.Engine) {
+	engine.Register(ResourceUser, authz.PolicyFunc(UserPolicy))
+}
+
+// NewUserResource build user resource, a user always own itself
+func NewUserResource(id int) authz.Resource {
+	return authz.Resource{
+		Type:    ResourceUser,
+		Id:      strconv.Itoa(id),
+		OwnerId: id,
+	}
+}
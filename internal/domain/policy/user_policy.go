@@ -0,0 +1,69 @@
+package policy
+
+import (
+	"context"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/pkg/authz"
+)
+
+const ResourceUser = "user"
+
+const (
+	ActionUserRead           = "read"
+	ActionUserCreate         = "create"
+	ActionUserUpdate         = "update"
+	ActionUserChangePassword = "change_password"
+	ActionUserResetPassword  = "reset_password"
+	ActionUserAssignRole     = "assign_role"
+	ActionUserDelete         = "delete"
+	ActionUserRestore        = "restore"
+	ActionUserPurge          = "purge"
+	ActionUserUnlock         = "unlock"
+)
+
+// UserPolicy owner can read and edit its own data, everything else need permission.
+// Resetting password without the old one or lifting a login lockout always need users:write.
+// Changing role also need roles:write, a holder of users:write alone could otherwise give itself
+// (or anyone) a role with more permissions than its own.
+func UserPolicy(ctx context.Context, subject authz.Subject, action string, resource authz.Resource) authz.Decision {
+	switch action {
+	case ActionUserRead:
+		return ownerOrPermission(subject, resource, entity.PermissionUsersRead)
+	case ActionUserUpdate:
+		return ownerOrPermission(subject, resource, entity.PermissionUsersWrite)
+	case ActionUserChangePassword:
+		if resource.IsOwnedBy(subject) {
+			return authz.Allow("owner")
+		}
+		return authz.Deny("only the owner can change password")
+	case ActionUserCreate, ActionUserResetPassword, ActionUserUnlock:
+		return permission(subject, entity.PermissionUsersWrite)
+	case ActionUserAssignRole:
+		if decision := permission(subject, entity.PermissionUsersWrite); !decision.Allowed {
+			return decision
+		}
+		return permission(subject, entity.PermissionRolesWrite)
+	case ActionUserDelete, ActionUserRestore:
+		return permission(subject, entity.PermissionUsersDelete)
+	case ActionUserPurge:
+		return permission(subject, entity.PermissionUsersPurge)
+	default:
+		return authz.Deny("unknown action " + action)
+	}
+}
+
+func ownerOrPermission(subject authz.Subject, resource authz.Resource, permissionName string) authz.Decision {
+	if resource.IsOwnedBy(subject) {
+		return authz.Allow("owner")
+	}
+
+	return permission(subject, permissionName)
+}
+
+func permission(subject authz.Subject, permissionName string) authz.Decision {
+	if subject.HasPermission(permissionName) {
+		return authz.Allow("has permission " + permissionName)
+	}
+
+	return authz.Deny("missing permission " + permissionName)
+}
@@ -0,0 +1,74 @@
+package policy
+
+import (
+	"context"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/pkg/authz"
+	"testing"
+)
+
+func TestUserPolicy(t *testing.T) {
+	self := authz.Subject{Id: 1, Role: entity.RoleUser}
+	admin := authz.Subject{Id: 2, Role: entity.RoleAdmin, Permissions: []string{entity.PermissionUsersRead}}
+	// can manage users but not roles
+	userAdmin := authz.Subject{Id: 4, Role: entity.RoleAdmin, Permissions: []string{entity.PermissionUsersRead, entity.PermissionUsersWrite}}
+	superAdmin := authz.Subject{Id: 3, Role: entity.RoleSuperAdmin, Permissions: []string{
+		entity.PermissionUsersRead,
+		entity.PermissionUsersWrite,
+		entity.PermissionUsersDelete,
+		entity.PermissionUsersPurge,
+		entity.PermissionRolesWrite,
+	}}
+
+	user1 := NewUserResource(1)
+	user4 := NewUserResource(4)
+	users := authz.Resource{Type: ResourceUser}
+
+	tests := []struct {
+		name     string
+		subject  authz.Subject
+		action   string
+		resource authz.Resource
+		allowed  bool
+	}{
+		{"self read own data", self, ActionUserRead, user1, true},
+		{"self list users", self, ActionUserRead, users, false},
+		{"admin read other user", admin, ActionUserRead, user1, true},
+		{"admin list users", admin, ActionUserRead, users, true},
+		{"self update own data", self, ActionUserUpdate, user1, true},
+		{"admin update other user", admin, ActionUserUpdate, user1, false},
+		{"super admin update other user", superAdmin, ActionUserUpdate, user1, true},
+		{"self assign own role", self, ActionUserAssignRole, user1, false},
+		{"user admin assign own role", userAdmin, ActionUserAssignRole, user4, false},
+		{"user admin assign other role", userAdmin, ActionUserAssignRole, user1, false},
+		{"user admin update other user", userAdmin, ActionUserUpdate, user1, true},
+		{"super admin assign role", superAdmin, ActionUserAssignRole, user1, true},
+		{"self change own password", self, ActionUserChangePassword, user1, true},
+		{"super admin change other password", superAdmin, ActionUserChangePassword, user1, false},
+		{"self reset own password", self, ActionUserResetPassword, user1, false},
+		{"super admin reset password", superAdmin, ActionUserResetPassword, user1, true},
+		{"self delete own account", self, ActionUserDelete, user1, false},
+		{"super admin delete", superAdmin, ActionUserDelete, user1, true},
+		{"super admin restore", superAdmin, ActionUserRestore, user1, true},
+		{"admin purge", admin, ActionUserPurge, user1, false},
+		{"super admin purge", superAdmin, ActionUserPurge, user1, true},
+		{"self unlock own login", self, ActionUserUnlock, user1, false},
+		{"admin unlock login", admin, ActionUserUnlock, user1, false},
+		{"super admin unlock login", superAdmin, ActionUserUnlock, user1, true},
+		{"admin create", admin, ActionUserCreate, users, false},
+		{"super admin create", superAdmin, ActionUserCreate, users, true},
+		{"unknown action", superAdmin, "export", users, false},
+	}
+
+	engine := authz.NewEngine()
+	RegisterAll(engine)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Authorize(context.Background(), tt.subject, tt.action, tt.resource)
+			if decision.Allowed != tt.allowed {
+				t.Errorf("Allowed = %v, want %v (reason: %s)", decision.Allowed, tt.allowed, decision.Reason)
+			}
+		})
+	}
+}
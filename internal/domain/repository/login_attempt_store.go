@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/pkg/lockout"
+	"time"
+)
+
+// LoginAttemptStore count failed logins per key, it does not join the request transaction so a
+// failed login is counted even though its transaction roll back
+type LoginAttemptStore interface {
+	// Reserve count an attempt as failed before its password is checked. The check against policy
+	// and the count are atomic, so parallel attempts can not all pass before one is counted: a key
+	// still blocked return allowed false and count nothing. The count restart from 1 when the last
+	// attempt is older than window.
+	Reserve(ctx context.Context, key string, at time.Time, window time.Duration, policy lockout.Policy) (failure entity.LoginFailure, allowed bool, err error)
+	// Release give back an attempt reserved by a login that did not fail
+	Release(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+	// Prune delete the keys whose last attempt is before the given time
+	Prune(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,18 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+)
+
+type PasswordResetRepository interface {
+	// Save replace every previous token of the user, only the last link sent work
+	Save(ctx context.Context, tx *sql.Tx, token *entity.PasswordResetToken) error
+	FindByTokenHash(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.PasswordResetToken, error)
+	// FindByTokenHashForUpdate lock the row until the transaction end, for the path that use the token
+	FindByTokenHashForUpdate(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.PasswordResetToken, error)
+	MarkUsed(ctx context.Context, tx *sql.Tx, token *entity.PasswordResetToken) error
+	// DeleteByUser drop the pending tokens of the user, once the password is set another way
+	DeleteByUser(ctx context.Context, tx *sql.Tx, userId int) error
+}
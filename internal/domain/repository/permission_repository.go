@@ -0,0 +1,14 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+)
+
+type PermissionRepository interface {
+	FindAll(ctx context.Context, tx *sql.Tx) ([]entity.Permission, error)
+	FindByNames(ctx context.Context, tx *sql.Tx, names []string) ([]entity.Permission, error)
+	FindByRoleID(ctx context.Context, tx *sql.Tx, roleId int) ([]entity.Permission, error)
+	ReplaceRolePermissions(ctx context.Context, tx *sql.Tx, roleId int, permissionIds []int) error
+}
@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+)
+
+type RefreshTokenRepository interface {
+	Save(ctx context.Context, tx *sql.Tx, token *entity.RefreshToken) (entity.RefreshToken, error)
+	FindByTokenHash(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.RefreshToken, error)
+	MarkRotated(ctx context.Context, tx *sql.Tx, token *entity.RefreshToken) error
+	RevokeFamily(ctx context.Context, tx *sql.Tx, familyId string) error
+	RevokeByUser(ctx context.Context, tx *sql.Tx, userId int) error
+}
@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+)
+
+type RoleRepository interface {
+	Save(ctx context.Context, tx *sql.Tx, role *entity.Role) (entity.Role, error)
+	Update(ctx context.Context, tx *sql.Tx, role *entity.Role) error
+	Delete(ctx context.Context, tx *sql.Tx, role *entity.Role) error
+	FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.Role, error)
+	FindByName(ctx context.Context, tx *sql.Tx, name string) (entity.Role, error)
+	FindAll(ctx context.Context, tx *sql.Tx) ([]entity.Role, error)
+	CountUsers(ctx context.Context, tx *sql.Tx, id int) (int, error)
+}
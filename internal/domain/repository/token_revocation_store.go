@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// TokenRevocationStore keep track of access tokens that must be rejected before their exp
+type TokenRevocationStore interface {
+	// RevokeToken revoke single token by jti, the entry can be dropped after expiresAt
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsTokenRevoked(ctx context.Context, jti string) (bool, error)
+	// RevokeUserTokens revoke every token of the user issued before or at the given time
+	RevokeUserTokens(ctx context.Context, userId int, before time.Time) error
+	// UserTokensRevokedBefore return zero time if user never had tokens revoked
+	UserTokensRevokedBefore(ctx context.Context, userId int) (time.Time, error)
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+)
+
+type TwoFactorRepository interface {
+	// Save replace the enrollment of the user with a new unconfirmed one
+	Save(ctx context.Context, tx *sql.Tx, totp *entity.UserTOTP) error
+	FindByUserID(ctx context.Context, tx *sql.Tx, userId int) (entity.UserTOTP, error)
+	// FindByUserIDForUpdate lock the row until the transaction end, for the paths that write it
+	FindByUserIDForUpdate(ctx context.Context, tx *sql.Tx, userId int) (entity.UserTOTP, error)
+	Confirm(ctx context.Context, tx *sql.Tx, totp *entity.UserTOTP) error
+	UpdateLastUsedStep(ctx context.Context, tx *sql.Tx, totp *entity.UserTOTP) error
+	// Delete remove the enrollment and the recovery codes
+	Delete(ctx context.Context, tx *sql.Tx, userId int) error
+	ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId int, codeHashes []string) error
+	// UseRecoveryCode mark an unused code as used, false when there is none
+	UseRecoveryCode(ctx context.Context, tx *sql.Tx, userId int, codeHash string) (bool, error)
+}
@@ -10,9 +10,15 @@ type UserRepository interface {
 	Save(ctx context.Context, tx *sql.Tx, user *entity.User) (entity.User, error)
 	Update(ctx context.Context, tx *sql.Tx, user *entity.User) error
 	Delete(ctx context.Context, tx *sql.Tx, user *entity.User) error
+	Restore(ctx context.Context, tx *sql.Tx, user *entity.User) error
+	Purge(ctx context.Context, tx *sql.Tx, user *entity.User) error
 	ChangePassword(ctx context.Context, tx *sql.Tx, user *entity.User) error
+	// UpdatePasswordHash store a new hash of the same password, only while the hash is still oldHash
+	UpdatePasswordHash(ctx context.Context, tx *sql.Tx, user *entity.User, oldHash string) error
 	FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.User, error)
+	FindByIDWithDeleted(ctx context.Context, tx *sql.Tx, id int) (entity.User, error)
 	FindByUsername(ctx context.Context, tx *sql.Tx, username string) (entity.User, error)
+	FindByUsernameWithDeleted(ctx context.Context, tx *sql.Tx, username string) (entity.User, error)
 	FindAllWithPagination(ctx context.Context, tx *sql.Tx, limit int, offset int) ([]entity.User, error)
 	FindTotal(ctx context.Context, tx *sql.Tx) (int, error)
 }
@@ -1,39 +1,87 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"os"
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/pkg/dbcluster"
+	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
-var DB *sql.DB
+func ConnectDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+	connStr := cfg.DSN
+	if connStr == "" {
+		connStr = fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s", cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name, cfg.SSLMode)
+	}
 
-func ConnectDB() {
-	var err error
+	db, err := open(connStr, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect postgres error: %w", err)
+	}
 
-	host := os.Getenv("HOST_POSTGRES")
-	port := os.Getenv("PORT_POSTGRES")
-	user := os.Getenv("USER_POSTGRES")
-	password := os.Getenv("PASSWORD_POSTGRES")
-	dbname := os.Getenv("DATABASE_POSTGRES")
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ping postgres error: %w", err)
+	}
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s", host, port, user, password, dbname)
+	return db, nil
+}
 
-	DB, err = sql.Open("postgres", connStr)
-	if err != nil {
-		log.Fatal("Connect postgres error: ", err)
+// ConnectReplicas open the read replicas without pinging them, a replica down at startup is only
+// skipped by the cluster until its check succeed
+func ConnectReplicas(cfg config.DatabaseConfig) ([]*sql.DB, error) {
+	var replicas []*sql.DB
+	for i, dsn := range cfg.ReplicaDSNs {
+		db, err := open(dsn, cfg)
+		if err != nil {
+			for _, replica := range replicas {
+				replica.Close()
+			}
+			return nil, fmt.Errorf("connect postgres replica %d error: %w", i, err)
+		}
+
+		replicas = append(replicas, db)
 	}
 
-	DB.SetMaxIdleConns(10)
-	DB.SetMaxOpenConns(10)
+	return replicas, nil
+}
 
-	err = DB.Ping()
+func open(connStr string, cfg config.DatabaseConfig) (*sql.DB, error) {
+	// instrumented driver create a span for every query and exec, the statement is recorded without arguments
+	db, err := otelsql.Open("postgres", connStr, otelsql.WithAttributes(semconv.DBSystemPostgreSQL), otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}))
 	if err != nil {
-		log.Fatal("Ping postgres error: ", err)
+		return nil, err
 	}
 
-	fmt.Println("Successfully connected to database")
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+
+	return db, nil
+}
+
+// ReplicaLagChecker fail when the replica replay more than maxLag behind the primary, a replica
+// that replayed everything it received is not lagging even when the primary had no write lately
+func ReplicaLagChecker(maxLag time.Duration) dbcluster.Checker {
+	return func(ctx context.Context, db *sql.DB) error {
+		var lag float64
+		sql := `select case
+			when pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() then 0
+			else coalesce(extract(epoch from now() - pg_last_xact_replay_timestamp()), 0)
+		end`
+		if err := db.QueryRowContext(ctx, sql).Scan(&lag); err != nil {
+			return err
+		}
+
+		if current := time.Duration(lag * float64(time.Second)); current > maxLag {
+			return fmt.Errorf("replication lag %s higher than %s", current.Round(time.Millisecond), maxLag)
+		}
+
+		return nil
+	}
 }
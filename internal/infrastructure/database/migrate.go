@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gofiber-cleanarch-test/internal/infrastructure/database/migrations"
+	"gofiber-cleanarch-test/internal/infrastructure/database/seeds"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/migrate"
+	"io/fs"
+	"sort"
+)
+
+// NewMigrator return a migrator over the embedded schema migrations
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	return migrate.New(db, migrations.FS)
+}
+
+// Seed insert the default data, seed files run in name order in one transaction and are
+// written to be idempotent so seeding an already seeded database change nothing
+func Seed(ctx context.Context, db *sql.DB) ([]string, error) {
+	names, err := fs.Glob(seeds.FS, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	err = helper.RunTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+		for _, name := range names {
+			script, err := fs.ReadFile(seeds.FS, name)
+			if err != nil {
+				return err
+			}
+
+			if _, err = tx.ExecContext(ctx, string(script)); err != nil {
+				return fmt.Errorf("seed %s: %w", name, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
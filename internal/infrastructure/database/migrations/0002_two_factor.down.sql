@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS user_recovery_codes;
+DROP TABLE IF EXISTS user_totp;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS mfa;
+ALTER TABLE role DROP COLUMN IF EXISTS require_2fa;
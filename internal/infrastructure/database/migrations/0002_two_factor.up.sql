@@ -0,0 +1,23 @@
+ALTER TABLE role ADD COLUMN require_2fa BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- set when the login of the token family completed a second factor, access tokens issued by a refresh carry it too
+ALTER TABLE refresh_tokens ADD COLUMN mfa BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- secret may be encrypted by the application, the row exist from enrollment and is only active once confirmed
+CREATE TABLE user_totp (
+    user_id INT NOT NULL PRIMARY KEY,
+    secret VARCHAR(255) NOT NULL,
+    confirmed_at TIMESTAMPTZ NULL,
+    last_used_step BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT user_totp_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE user_recovery_codes (
+    id BIGSERIAL NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMPTZ NULL,
+    CONSTRAINT user_recovery_codes_user_id_code_hash_key UNIQUE (user_id, code_hash),
+    CONSTRAINT user_recovery_codes_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
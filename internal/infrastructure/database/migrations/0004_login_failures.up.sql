@@ -0,0 +1,8 @@
+-- keyed by account (username) or ip, no foreign key so unknown usernames are counted like the others
+CREATE TABLE login_failures (
+    key VARCHAR(255) NOT NULL PRIMARY KEY,
+    count INT NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX login_failures_last_failure_at_idx ON login_failures (last_failure_at);
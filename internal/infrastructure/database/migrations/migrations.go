@@ -0,0 +1,7 @@
+// Package migrations embed the versioned schema migrations, see pkg/migrate for the file naming
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,3 @@
+-- default roles use fixed ids, the application refer to them by id (entity.RoleAdmin, ...)
+INSERT INTO role (id, name) VALUES (1, 'Admin'), (2, 'User'), (3, 'SuperAdmin') ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('role', 'id'), GREATEST((SELECT MAX(id) FROM role), 1));
@@ -0,0 +1,11 @@
+INSERT INTO permissions (name, description) VALUES
+    ('users:read', 'Read any user data'),
+    ('users:write', 'Create and edit any user'),
+    ('users:delete', 'Soft delete and restore user'),
+    ('users:purge', 'Permanently remove user'),
+    ('roles:write', 'Manage roles and role permissions')
+ON CONFLICT (name) DO NOTHING;
+
+-- SuperAdmin get every permission, Admin can read users
+INSERT INTO role_permissions (role_id, permission_id) SELECT 3, id FROM permissions ON CONFLICT DO NOTHING;
+INSERT INTO role_permissions (role_id, permission_id) SELECT 1, id FROM permissions WHERE name = 'users:read' ON CONFLICT DO NOTHING;
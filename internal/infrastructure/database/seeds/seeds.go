@@ -0,0 +1,7 @@
+// Package seeds embed the default data, every file must be safe to run more than once
+package seeds
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
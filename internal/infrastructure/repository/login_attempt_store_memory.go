@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/pkg/lockout"
+	"sync"
+	"time"
+)
+
+// MemoryLoginAttemptStore only suitable for single instance deployment, data lost on restart
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	failures map[string]entity.LoginFailure
+}
+
+func NewMemoryLoginAttemptStore() repository.LoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		failures: make(map[string]entity.LoginFailure),
+	}
+}
+
+func (s *MemoryLoginAttemptStore) Reserve(ctx context.Context, key string, at time.Time, window time.Duration, policy lockout.Policy) (entity.LoginFailure, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failure, ok := s.failures[key]
+	if !ok || failure.LastFailureAt.Before(at.Add(-window)) {
+		failure = entity.LoginFailure{Key: key}
+	}
+
+	if failure.Count > 0 && at.Before(policy.BlockedUntil(failure.Count, failure.LastFailureAt)) {
+		return failure, false, nil
+	}
+
+	failure.Count++
+	failure.LastFailureAt = at
+	s.failures[key] = failure
+
+	return failure, true, nil
+}
+
+func (s *MemoryLoginAttemptStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if failure, ok := s.failures[key]; ok && failure.Count > 0 {
+		failure.Count--
+		s.failures[key] = failure
+	}
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.failures, key)
+
+	return nil
+}
+
+func (s *MemoryLoginAttemptStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pruned int64
+	for key, failure := range s.failures {
+		if failure.LastFailureAt.Before(before) {
+			delete(s.failures, key)
+			pruned++
+		}
+	}
+
+	return pruned, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"gofiber-cleanarch-test/pkg/lockout"
+	"testing"
+	"time"
+)
+
+func TestMemoryLoginAttemptStore(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	window := 10 * time.Minute
+	policy := lockout.Policy{Threshold: 2, Duration: time.Minute}
+
+	store := NewMemoryLoginAttemptStore()
+
+	steps := []struct {
+		name      string
+		at        time.Duration
+		release   bool
+		reset     bool
+		wantCount int
+		wantOk    bool
+	}{
+		{name: "first", at: 0, wantCount: 1, wantOk: true},
+		{name: "second lock", at: time.Second, wantCount: 2, wantOk: true},
+		{name: "locked", at: 30 * time.Second, wantCount: 2, wantOk: false},
+		{name: "lock expired", at: 61 * time.Second, wantCount: 3, wantOk: true},
+		{name: "released", at: 61 * time.Second, release: true, wantCount: 2, wantOk: false},
+		{name: "outside the window", at: 62*time.Second + window, wantCount: 1, wantOk: true},
+		{name: "reset", at: 63*time.Second + window, reset: true, wantCount: 1, wantOk: true},
+	}
+
+	for _, step := range steps {
+		if step.release {
+			if err := store.Release(ctx, "key"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if step.reset {
+			if err := store.Reset(ctx, "key"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		failure, ok, err := store.Reserve(ctx, "key", start.Add(step.at), window, policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if failure.Count != step.wantCount || ok != step.wantOk {
+			t.Errorf("%s: Reserve = %d, %v, want %d, %v", step.name, failure.Count, ok, step.wantCount, step.wantOk)
+		}
+	}
+}
+
+func TestMemoryLoginAttemptStoreRelease(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryLoginAttemptStore()
+	at := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	// releasing an unknown key or more than reserved never go below zero
+	for i := 0; i < 3; i++ {
+		if err := store.Release(ctx, "key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := store.Reserve(ctx, "key", at, time.Minute, lockout.Policy{}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.Release(ctx, "key"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failure, _, err := store.Reserve(ctx, "key", at, time.Minute, lockout.Policy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failure.Count != 1 {
+		t.Errorf("count = %d, want 1", failure.Count)
+	}
+}
+
+func TestMemoryLoginAttemptStorePrune(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryLoginAttemptStore()
+	at := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	for i, key := range []string{"first", "second", "recent"} {
+		if _, _, err := store.Reserve(ctx, key, at.Add(time.Duration(i)*time.Minute), time.Hour, lockout.Policy{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruned, err := store.Prune(ctx, at.Add(2*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 2 {
+		t.Errorf("pruned = %d, want 2", pruned)
+	}
+
+	if pruned, _ = store.Prune(ctx, at.Add(time.Hour)); pruned != 1 {
+		t.Errorf("second prune = %d, want the recent key", pruned)
+	}
+}
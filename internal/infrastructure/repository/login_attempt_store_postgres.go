@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/pkg/lockout"
+	"time"
+)
+
+// PostgresLoginAttemptStore use its own connection (not the request transaction), the failure of a
+// rolled back login must stay counted
+type PostgresLoginAttemptStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresLoginAttemptStore(db *sql.DB) repository.LoginAttemptStore {
+	return &PostgresLoginAttemptStore{
+		DB: db,
+	}
+}
+
+// Reserve lock the row of the key for the check, the attempts of one key are serialized and every
+// one see the count of the previous
+func (s *PostgresLoginAttemptStore) Reserve(ctx context.Context, key string, at time.Time, window time.Duration, policy lockout.Policy) (entity.LoginFailure, bool, error) {
+	failure := entity.LoginFailure{Key: key}
+
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return failure, false, err
+	}
+	defer tx.Rollback()
+
+	// the row must exist to be locked, a new one is rolled back with the transaction when blocked
+	sql := "insert into login_failures (key, count, last_failure_at) values ($1, 0, $2) on conflict (key) do nothing"
+	if _, err = tx.ExecContext(ctx, sql, key, at); err != nil {
+		return failure, false, err
+	}
+
+	sql = "select count, last_failure_at from login_failures where key = $1 for update"
+	if err = tx.QueryRowContext(ctx, sql, key).Scan(&failure.Count, &failure.LastFailureAt); err != nil {
+		return failure, false, err
+	}
+	if failure.LastFailureAt.Before(at.Add(-window)) {
+		failure.Count = 0
+	}
+
+	if failure.Count > 0 && at.Before(policy.BlockedUntil(failure.Count, failure.LastFailureAt)) {
+		return failure, false, nil
+	}
+
+	failure.Count++
+	failure.LastFailureAt = at
+
+	sql = "update login_failures set count = $2, last_failure_at = $3 where key = $1"
+	if _, err = tx.ExecContext(ctx, sql, key, failure.Count, failure.LastFailureAt); err != nil {
+		return failure, false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return failure, false, err
+	}
+
+	return failure, true, nil
+}
+
+func (s *PostgresLoginAttemptStore) Release(ctx context.Context, key string) error {
+	if _, err := s.DB.ExecContext(ctx, "update login_failures set count = count - 1 where key = $1 and count > 0", key); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresLoginAttemptStore) Reset(ctx context.Context, key string) error {
+	if _, err := s.DB.ExecContext(ctx, "delete from login_failures where key = $1", key); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresLoginAttemptStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.DB.ExecContext(ctx, "delete from login_failures where last_failure_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
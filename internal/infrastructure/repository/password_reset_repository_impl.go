@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"log/slog"
+)
+
+type PasswordResetRepositoryImpl struct {
+	Logger *slog.Logger
+}
+
+func NewPasswordResetRepository(logger *slog.Logger) repository.PasswordResetRepository {
+	return &PasswordResetRepositoryImpl{
+		Logger: logger,
+	}
+}
+
+func (r *PasswordResetRepositoryImpl) Save(ctx context.Context, tx *sql.Tx, token *entity.PasswordResetToken) error {
+	if err := r.DeleteByUser(ctx, tx, token.UserId); err != nil {
+		return err
+	}
+
+	sql := "insert into password_reset_tokens (user_id, token_hash, expires_at) values ($1, $2, $3) returning id, created_at"
+	if err := tx.QueryRowContext(ctx, sql, token.UserId, token.TokenHash, token.ExpiresAt).Scan(&token.Id, &token.CreatedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *PasswordResetRepositoryImpl) FindByTokenHash(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.PasswordResetToken, error) {
+	return r.findByTokenHash(ctx, tx, "select id, user_id, token_hash, expires_at, used_at, created_at from password_reset_tokens where token_hash = $1", tokenHash)
+}
+
+// FindByTokenHashForUpdate locks the row so two concurrent resets with the same token cannot both use it
+func (r *PasswordResetRepositoryImpl) FindByTokenHashForUpdate(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.PasswordResetToken, error) {
+	return r.findByTokenHash(ctx, tx, "select id, user_id, token_hash, expires_at, used_at, created_at from password_reset_tokens where token_hash = $1 for update", tokenHash)
+}
+
+func (r *PasswordResetRepositoryImpl) findByTokenHash(ctx context.Context, tx *sql.Tx, sql string, tokenHash string) (entity.PasswordResetToken, error) {
+	var token entity.PasswordResetToken
+
+	if err := tx.QueryRowContext(ctx, sql, tokenHash).Scan(&token.Id, &token.UserId, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt); err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+func (r *PasswordResetRepositoryImpl) MarkUsed(ctx context.Context, tx *sql.Tx, token *entity.PasswordResetToken) error {
+	sql := "update password_reset_tokens set used_at = NOW() where id = $1 returning used_at"
+	if err := tx.QueryRowContext(ctx, sql, token.Id).Scan(&token.UsedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *PasswordResetRepositoryImpl) DeleteByUser(ctx context.Context, tx *sql.Tx, userId int) error {
+	result, err := tx.ExecContext(ctx, "delete from password_reset_tokens where user_id = $1", userId)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	r.Logger.DebugContext(ctx, "password reset tokens deleted", "user_id", userId, "rows", rows)
+
+	return nil
+}
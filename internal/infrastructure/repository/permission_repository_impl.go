@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+
+	"github.com/lib/pq"
+)
+
+type PermissionRepositoryImpl struct{}
+
+func NewPermissionRepository() repository.PermissionRepository {
+	return &PermissionRepositoryImpl{}
+}
+
+func (r *PermissionRepositoryImpl) FindAll(ctx context.Context, tx *sql.Tx) ([]entity.Permission, error) {
+	sql := "select id, name, coalesce(description, '') from permissions order by name"
+	rows, err := tx.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPermissions(rows)
+}
+
+func (r *PermissionRepositoryImpl) FindByNames(ctx context.Context, tx *sql.Tx, names []string) ([]entity.Permission, error) {
+	sql := "select id, name, coalesce(description, '') from permissions where name = any($1) order by name"
+	rows, err := tx.QueryContext(ctx, sql, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPermissions(rows)
+}
+
+func (r *PermissionRepositoryImpl) FindByRoleID(ctx context.Context, tx *sql.Tx, roleId int) ([]entity.Permission, error) {
+	sql := `select p.id, p.name, coalesce(p.description, '') from permissions p
+		join role_permissions rp on rp.permission_id = p.id
+		where rp.role_id = $1 order by p.name`
+	rows, err := tx.QueryContext(ctx, sql, roleId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanPermissions(rows)
+}
+
+func (r *PermissionRepositoryImpl) ReplaceRolePermissions(ctx context.Context, tx *sql.Tx, roleId int, permissionIds []int) error {
+	sql := "delete from role_permissions where role_id = $1"
+	if _, err := tx.ExecContext(ctx, sql, roleId); err != nil {
+		return err
+	}
+
+	sql = "insert into role_permissions (role_id, permission_id) values ($1, $2)"
+	for _, permissionId := range permissionIds {
+		if _, err := tx.ExecContext(ctx, sql, roleId, permissionId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func scanPermissions(rows *sql.Rows) ([]entity.Permission, error) {
+	var permissions []entity.Permission
+
+	for rows.Next() {
+		var permission entity.Permission
+		if err := rows.Scan(&permission.Id, &permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"log/slog"
+)
+
+type RefreshTokenRepositoryImpl struct {
+	Logger *slog.Logger
+}
+
+func NewRefreshTokenRepository(logger *slog.Logger) repository.RefreshTokenRepository {
+	return &RefreshTokenRepositoryImpl{
+		Logger: logger,
+	}
+}
+
+func (r *RefreshTokenRepositoryImpl) Save(ctx context.Context, tx *sql.Tx, token *entity.RefreshToken) (entity.RefreshToken, error) {
+	sql := "insert into refresh_tokens (user_id, family_id, token_hash, expires_at, mfa) values ($1, $2, $3, $4, $5) returning id, created_at"
+	result := tx.QueryRowContext(ctx, sql, token.UserId, token.FamilyId, token.TokenHash, token.ExpiresAt, token.MFA)
+
+	if err := result.Scan(&token.Id, &token.CreatedAt); err != nil {
+		return *token, err
+	}
+
+	return *token, nil
+}
+
+// FindByTokenHash locks the row so two concurrent refreshes of the same token cannot both rotate it
+func (r *RefreshTokenRepositoryImpl) FindByTokenHash(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.RefreshToken, error) {
+	var token entity.RefreshToken
+
+	sql := "select id, user_id, family_id, token_hash, expires_at, created_at, rotated_at, revoked_at, mfa from refresh_tokens where token_hash = $1 for update"
+
+	if err := tx.QueryRowContext(ctx, sql, tokenHash).Scan(&token.Id, &token.UserId, &token.FamilyId, &token.TokenHash, &token.ExpiresAt, &token.CreatedAt, &token.RotatedAt, &token.RevokedAt, &token.MFA); err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+func (r *RefreshTokenRepositoryImpl) MarkRotated(ctx context.Context, tx *sql.Tx, token *entity.RefreshToken) error {
+	sql := "update refresh_tokens set rotated_at = NOW() where id = $1"
+	if _, err := tx.ExecContext(ctx, sql, token.Id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *RefreshTokenRepositoryImpl) RevokeFamily(ctx context.Context, tx *sql.Tx, familyId string) error {
+	sql := "update refresh_tokens set revoked_at = NOW() where family_id = $1 and revoked_at is null"
+	result, err := tx.ExecContext(ctx, sql, familyId)
+	if err != nil {
+		return err
+	}
+
+	revoked, _ := result.RowsAffected()
+	r.Logger.DebugContext(ctx, "refresh token family revoked", "revoked", revoked)
+
+	return nil
+}
+
+func (r *RefreshTokenRepositoryImpl) RevokeByUser(ctx context.Context, tx *sql.Tx, userId int) error {
+	sql := "update refresh_tokens set revoked_at = NOW() where user_id = $1 and revoked_at is null"
+	result, err := tx.ExecContext(ctx, sql, userId)
+	if err != nil {
+		return err
+	}
+
+	revoked, _ := result.RowsAffected()
+	r.Logger.DebugContext(ctx, "refresh tokens of user revoked", "user_id", userId, "revoked", revoked)
+
+	return nil
+}
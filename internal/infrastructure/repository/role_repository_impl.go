@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+)
+
+type RoleRepositoryImpl struct{}
+
+func NewRoleRepository() repository.RoleRepository {
+	return &RoleRepositoryImpl{}
+}
+
+func (r *RoleRepositoryImpl) Save(ctx context.Context, tx *sql.Tx, role *entity.Role) (entity.Role, error) {
+	sql := "insert into role (name, require_2fa) values ($1, $2) returning id"
+	if err := tx.QueryRowContext(ctx, sql, role.Name, role.Require2FA).Scan(&role.Id); err != nil {
+		return *role, err
+	}
+
+	return *role, nil
+}
+
+func (r *RoleRepositoryImpl) Update(ctx context.Context, tx *sql.Tx, role *entity.Role) error {
+	sql := "update role set name = $1, require_2fa = $2 where id = $3"
+	if _, err := tx.ExecContext(ctx, sql, role.Name, role.Require2FA, role.Id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *RoleRepositoryImpl) Delete(ctx context.Context, tx *sql.Tx, role *entity.Role) error {
+	sql := "delete from role where id = $1"
+	if _, err := tx.ExecContext(ctx, sql, role.Id); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *RoleRepositoryImpl) FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.Role, error) {
+	var role entity.Role
+
+	sql := "select id, name, require_2fa from role where id = $1"
+	if err := tx.QueryRowContext(ctx, sql, id).Scan(&role.Id, &role.Name, &role.Require2FA); err != nil {
+		return role, err
+	}
+
+	return role, nil
+}
+
+func (r *RoleRepositoryImpl) FindByName(ctx context.Context, tx *sql.Tx, name string) (entity.Role, error) {
+	var role entity.Role
+
+	sql := "select id, name, require_2fa from role where lower(name) = lower($1)"
+	if err := tx.QueryRowContext(ctx, sql, name).Scan(&role.Id, &role.Name, &role.Require2FA); err != nil {
+		return role, err
+	}
+
+	return role, nil
+}
+
+func (r *RoleRepositoryImpl) FindAll(ctx context.Context, tx *sql.Tx) ([]entity.Role, error) {
+	var roles []entity.Role
+
+	sql := "select id, name, require_2fa from role order by id"
+	rows, err := tx.QueryContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role entity.Role
+		if err := rows.Scan(&role.Id, &role.Name, &role.Require2FA); err != nil {
+			return nil, err
+		}
+
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+// CountUsers count users (also soft deleted one, they can be restored) that still use the role
+func (r *RoleRepositoryImpl) CountUsers(ctx context.Context, tx *sql.Tx, id int) (int, error) {
+	var total int
+
+	sql := "select count(id) from users where role = $1"
+	if err := tx.QueryRowContext(ctx, sql, id).Scan(&total); err != nil {
+		return 0, err
+	}
+
+	return total, nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultTokenRevocationPruneInterval = 10 * time.Minute
+
+// MemoryTokenRevocationStore only suitable for single instance deployment, data lost on restart.
+// Expired tokens are ignored by lookups and deleted in background every PruneInterval, not on
+// every revocation.
+type MemoryTokenRevocationStore struct {
+	PruneInterval time.Duration
+
+	mu          sync.RWMutex
+	tokens      map[string]time.Time
+	userCutoffs map[int]time.Time
+	now         func() time.Time
+
+	loopMu sync.Mutex
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func NewMemoryTokenRevocationStore() *MemoryTokenRevocationStore {
+	return &MemoryTokenRevocationStore{
+		PruneInterval: defaultTokenRevocationPruneInterval,
+		tokens:        make(map[string]time.Time),
+		userCutoffs:   make(map[int]time.Time),
+		now:           time.Now,
+	}
+}
+
+func (s *MemoryTokenRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[jti] = expiresAt
+
+	return nil
+}
+
+func (s *MemoryTokenRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// an expired entry wait for the next prune, the token is refused by its exp anyway
+	expiresAt, ok := s.tokens[jti]
+
+	return ok && !s.now().After(expiresAt), nil
+}
+
+func (s *MemoryTokenRevocationStore) RevokeUserTokens(ctx context.Context, userId int, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if before.After(s.userCutoffs[userId]) {
+		s.userCutoffs[userId] = before
+	}
+
+	return nil
+}
+
+func (s *MemoryTokenRevocationStore) UserTokensRevokedBefore(ctx context.Context, userId int) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.userCutoffs[userId], nil
+}
+
+// Start prune the expired tokens in background every PruneInterval until Stop
+func (s *MemoryTokenRevocationStore) Start(ctx context.Context) error {
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	if s.stop != nil {
+		return errors.New("token revocation store: already started")
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.loop(s.stop, s.done)
+
+	return nil
+}
+
+func (s *MemoryTokenRevocationStore) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.prune()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// prune delete the expired tokens and return how many
+func (s *MemoryTokenRevocationStore) prune() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	pruned := 0
+	for jti, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, jti)
+			pruned++
+		}
+	}
+
+	return pruned
+}
+
+// Stop end the background pruning
+func (s *MemoryTokenRevocationStore) Stop(ctx context.Context) error {
+	s.loopMu.Lock()
+	defer s.loopMu.Unlock()
+
+	if s.stop == nil {
+		return nil
+	}
+
+	close(s.stop)
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.stop = nil
+
+	return nil
+}
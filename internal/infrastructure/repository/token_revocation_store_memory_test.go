@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryTokenRevocationStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	store := NewMemoryTokenRevocationStore()
+	store.now = func() time.Time { return now }
+
+	if err := store.RevokeToken(ctx, "short", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeToken(ctx, "long", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		prune   bool
+		want    map[string]bool
+		wantLen int
+	}{
+		{name: "revoked", want: map[string]bool{"short": true, "long": true, "other": false}, wantLen: 2},
+		{name: "nothing expired to prune", prune: true, want: map[string]bool{"short": true, "long": true}, wantLen: 2},
+		{name: "expired before the prune", advance: 2 * time.Minute, want: map[string]bool{"short": false, "long": true}, wantLen: 2},
+		{name: "pruned", prune: true, want: map[string]bool{"short": false, "long": true}, wantLen: 1},
+	}
+
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		if tt.prune {
+			store.prune()
+		}
+
+		for jti, want := range tt.want {
+			revoked, err := store.IsTokenRevoked(ctx, jti)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != want {
+				t.Errorf("%s: IsTokenRevoked(%s) = %v, want %v", tt.name, jti, revoked, want)
+			}
+		}
+		if len(store.tokens) != tt.wantLen {
+			t.Errorf("%s: %d entries kept, want %d", tt.name, len(store.tokens), tt.wantLen)
+		}
+	}
+}
+
+func TestMemoryTokenRevocationStoreUserCutoff(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryTokenRevocationStore()
+	at := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	if before, _ := store.UserTokensRevokedBefore(ctx, 1); !before.IsZero() {
+		t.Errorf("cutoff of a user never revoked = %v, want zero", before)
+	}
+
+	// the cutoff only move forward
+	for _, before := range []time.Time{at, at.Add(-time.Hour)} {
+		if err := store.RevokeUserTokens(ctx, 1, before); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if before, _ := store.UserTokensRevokedBefore(ctx, 1); !before.Equal(at) {
+		t.Errorf("cutoff = %v, want %v", before, at)
+	}
+	if before, _ := store.UserTokensRevokedBefore(ctx, 2); !before.IsZero() {
+		t.Errorf("cutoff of another user = %v, want zero", before)
+	}
+}
+
+func TestMemoryTokenRevocationStoreStartStop(t *testing.T) {
+	store := NewMemoryTokenRevocationStore()
+	store.PruneInterval = time.Millisecond
+	ctx := context.Background()
+
+	if err := store.RevokeToken(ctx, "expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Start(ctx); err == nil {
+		t.Error("second Start should fail")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		store.mu.RLock()
+		left := len(store.tokens)
+		store.mu.RUnlock()
+		if left == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired token not pruned in background")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := store.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Stop(ctx); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"time"
+)
+
+// PostgresTokenRevocationStore use its own connection (not the request transaction), revocation must stay even if the caller rollback
+type PostgresTokenRevocationStore struct {
+	DB *sql.DB
+}
+
+func NewPostgresTokenRevocationStore(db *sql.DB) repository.TokenRevocationStore {
+	return &PostgresTokenRevocationStore{
+		DB: db,
+	}
+}
+
+func (s *PostgresTokenRevocationStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	// prune expired entries so the table does not grow forever
+	sql := "delete from revoked_tokens where expires_at < NOW()"
+	if _, err := s.DB.ExecContext(ctx, sql); err != nil {
+		return err
+	}
+
+	sql = "insert into revoked_tokens (jti, expires_at) values ($1, $2) on conflict (jti) do nothing"
+	if _, err := s.DB.ExecContext(ctx, sql, jti, expiresAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresTokenRevocationStore) IsTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+
+	sql := "select exists(select 1 from revoked_tokens where jti = $1)"
+	if err := s.DB.QueryRowContext(ctx, sql, jti).Scan(&revoked); err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+func (s *PostgresTokenRevocationStore) RevokeUserTokens(ctx context.Context, userId int, before time.Time) error {
+	sql := `insert into user_token_revocations (user_id, revoked_before) values ($1, $2)
+		on conflict (user_id) do update set revoked_before = greatest(user_token_revocations.revoked_before, excluded.revoked_before)`
+	if _, err := s.DB.ExecContext(ctx, sql, userId, before); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *PostgresTokenRevocationStore) UserTokensRevokedBefore(ctx context.Context, userId int) (time.Time, error) {
+	var before time.Time
+
+	query := "select revoked_before from user_token_revocations where user_id = $1"
+	if err := s.DB.QueryRowContext(ctx, query, userId).Scan(&before); err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+
+	return before, nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"log/slog"
+)
+
+type TwoFactorRepositoryImpl struct {
+	Logger *slog.Logger
+}
+
+func NewTwoFactorRepository(logger *slog.Logger) repository.TwoFactorRepository {
+	return &TwoFactorRepositoryImpl{
+		Logger: logger,
+	}
+}
+
+func (r *TwoFactorRepositoryImpl) Save(ctx context.Context, tx *sql.Tx, totp *entity.UserTOTP) error {
+	sql := `insert into user_totp (user_id, secret) values ($1, $2)
+		on conflict (user_id) do update set secret = excluded.secret, confirmed_at = null, last_used_step = 0, created_at = NOW()
+		returning created_at`
+	if err := tx.QueryRowContext(ctx, sql, totp.UserId, totp.Secret).Scan(&totp.CreatedAt); err != nil {
+		return err
+	}
+
+	totp.ConfirmedAt = nil
+	totp.LastUsedStep = 0
+
+	return nil
+}
+
+func (r *TwoFactorRepositoryImpl) FindByUserID(ctx context.Context, tx *sql.Tx, userId int) (entity.UserTOTP, error) {
+	return r.findByUserID(ctx, tx, "select user_id, secret, confirmed_at, last_used_step, created_at from user_totp where user_id = $1", userId)
+}
+
+// FindByUserIDForUpdate locks the row so two concurrent logins can not both accept the same code
+func (r *TwoFactorRepositoryImpl) FindByUserIDForUpdate(ctx context.Context, tx *sql.Tx, userId int) (entity.UserTOTP, error) {
+	return r.findByUserID(ctx, tx, "select user_id, secret, confirmed_at, last_used_step, created_at from user_totp where user_id = $1 for update", userId)
+}
+
+func (r *TwoFactorRepositoryImpl) findByUserID(ctx context.Context, tx *sql.Tx, sql string, userId int) (entity.UserTOTP, error) {
+	var totp entity.UserTOTP
+
+	if err := tx.QueryRowContext(ctx, sql, userId).Scan(&totp.UserId, &totp.Secret, &totp.ConfirmedAt, &totp.LastUsedStep, &totp.CreatedAt); err != nil {
+		return totp, err
+	}
+
+	return totp, nil
+}
+
+func (r *TwoFactorRepositoryImpl) Confirm(ctx context.Context, tx *sql.Tx, totp *entity.UserTOTP) error {
+	sql := "update user_totp set confirmed_at = NOW(), last_used_step = $1 where user_id = $2 returning confirmed_at"
+	if err := tx.QueryRowContext(ctx, sql, totp.LastUsedStep, totp.UserId).Scan(&totp.ConfirmedAt); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepositoryImpl) UpdateLastUsedStep(ctx context.Context, tx *sql.Tx, totp *entity.UserTOTP) error {
+	sql := "update user_totp set last_used_step = $1 where user_id = $2"
+	if _, err := tx.ExecContext(ctx, sql, totp.LastUsedStep, totp.UserId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepositoryImpl) Delete(ctx context.Context, tx *sql.Tx, userId int) error {
+	if _, err := tx.ExecContext(ctx, "delete from user_recovery_codes where user_id = $1", userId); err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx, "delete from user_totp where user_id = $1", userId)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	r.Logger.DebugContext(ctx, "totp enrollment deleted", "user_id", userId, "rows", rows)
+
+	return nil
+}
+
+func (r *TwoFactorRepositoryImpl) ReplaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId int, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, "delete from user_recovery_codes where user_id = $1", userId); err != nil {
+		return err
+	}
+
+	sql := "insert into user_recovery_codes (user_id, code_hash) values ($1, $2)"
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, sql, userId, hash); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *TwoFactorRepositoryImpl) UseRecoveryCode(ctx context.Context, tx *sql.Tx, userId int, codeHash string) (bool, error) {
+	sql := "update user_recovery_codes set used_at = NOW() where user_id = $1 and code_hash = $2 and used_at is null"
+	result, err := tx.ExecContext(ctx, sql, userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/pkg/cache"
+	"gofiber-cleanarch-test/pkg/helper"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UserRepositoryCache cache FindByID in read only transactions, e.g. the user lookup of IsAuth.
+// Read write transactions always read the database so a write never start from a stale row, this
+// also mean cached users never need (and never contain) the password hash.
+//
+// Writes invalidate the entry right away and again after commit, so a read running meanwhile can
+// not keep the old row. A read on a lagging replica can still cache it until the TTL expire.
+type UserRepositoryCache struct {
+	repository.UserRepository
+	Cache  cache.Cache
+	TTL    time.Duration
+	Logger *slog.Logger
+}
+
+func NewUserRepositoryCache(next repository.UserRepository, cache cache.Cache, ttl time.Duration, logger *slog.Logger) repository.UserRepository {
+	return &UserRepositoryCache{
+		UserRepository: next,
+		Cache:          cache,
+		TTL:            ttl,
+		Logger:         logger,
+	}
+}
+
+func (r *UserRepositoryCache) FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.User, error) {
+	if !helper.InReadOnlyTx(ctx) {
+		return r.UserRepository.FindByID(ctx, tx, id)
+	}
+
+	key := userCacheKey(id)
+
+	// cache failure only cost a database read
+	value, found, err := r.Cache.Get(ctx, key)
+	if err != nil {
+		r.Logger.WarnContext(ctx, "user cache get failed", "error", err)
+	}
+	if found {
+		var user entity.User
+		if err = json.Unmarshal(value, &user); err == nil {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", true))
+			return user, nil
+		}
+		r.Logger.WarnContext(ctx, "user cache entry invalid", "error", err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("cache.hit", false))
+
+	user, err := r.UserRepository.FindByID(ctx, tx, id)
+	if err != nil {
+		return user, err
+	}
+
+	cached := user
+	cached.Password = ""
+	if value, err = json.Marshal(cached); err == nil {
+		err = r.Cache.Set(ctx, key, value, r.TTL)
+	}
+	if err != nil {
+		r.Logger.WarnContext(ctx, "user cache set failed", "error", err)
+	}
+
+	return cached, nil
+}
+
+func (r *UserRepositoryCache) Update(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	if err := r.UserRepository.Update(ctx, tx, user); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.Id)
+	return nil
+}
+
+func (r *UserRepositoryCache) ChangePassword(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	if err := r.UserRepository.ChangePassword(ctx, tx, user); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.Id)
+	return nil
+}
+
+func (r *UserRepositoryCache) UpdatePasswordHash(ctx context.Context, tx *sql.Tx, user *entity.User, oldHash string) error {
+	if err := r.UserRepository.UpdatePasswordHash(ctx, tx, user, oldHash); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.Id)
+	return nil
+}
+
+func (r *UserRepositoryCache) Delete(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	if err := r.UserRepository.Delete(ctx, tx, user); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.Id)
+	return nil
+}
+
+func (r *UserRepositoryCache) Restore(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	if err := r.UserRepository.Restore(ctx, tx, user); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.Id)
+	return nil
+}
+
+func (r *UserRepositoryCache) Purge(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	if err := r.UserRepository.Purge(ctx, tx, user); err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, user.Id)
+	return nil
+}
+
+func (r *UserRepositoryCache) invalidate(ctx context.Context, id int) {
+	key := userCacheKey(id)
+	remove := func(ctx context.Context) {
+		if err := r.Cache.Delete(ctx, key); err != nil {
+			r.Logger.ErrorContext(ctx, "user cache invalidation failed, entry stay stale until it expire", "user_id", id, "error", err)
+		}
+	}
+
+	remove(ctx)
+	// after commit the request can already be canceled, the invalidation must still happen
+	afterCommitCtx := context.WithoutCancel(ctx)
+	helper.AfterCommit(ctx, func() { remove(afterCommitCtx) })
+}
+
+func userCacheKey(id int) string {
+	return "user:" + strconv.Itoa(id)
+}
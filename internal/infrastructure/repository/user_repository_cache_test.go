@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/pkg/cache"
+	"gofiber-cleanarch-test/pkg/helper"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// nopDriver open transactions that do nothing, the repositories under test never reach it
+type nopDriver struct{}
+
+func (nopDriver) Open(name string) (driver.Conn, error) { return nopConn{}, nil }
+
+type nopConn struct{}
+
+func (nopConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (nopConn) Close() error              { return nil }
+func (nopConn) Begin() (driver.Tx, error) { return nopConn{}, nil }
+func (nopConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return nopConn{}, nil
+}
+func (nopConn) Commit() error   { return nil }
+func (nopConn) Rollback() error { return nil }
+
+var registerNopDriver sync.Once
+
+func openNopDB(t *testing.T) *sql.DB {
+	registerNopDriver.Do(func() { sql.Register("repository-nop", nopDriver{}) })
+
+	db, err := sql.Open("repository-nop", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// countingUserRepository stand for the database, it count the lookups reaching it
+type countingUserRepository struct {
+	repository.UserRepository
+	user  entity.User
+	reads int
+}
+
+func (r *countingUserRepository) FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.User, error) {
+	r.reads++
+	if id != r.user.Id {
+		return entity.User{}, sql.ErrNoRows
+	}
+
+	return r.user, nil
+}
+
+func (r *countingUserRepository) Update(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	return nil
+}
+func (r *countingUserRepository) Delete(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	return nil
+}
+func (r *countingUserRepository) Restore(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	return nil
+}
+func (r *countingUserRepository) Purge(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	return nil
+}
+func (r *countingUserRepository) ChangePassword(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	return nil
+}
+func (r *countingUserRepository) UpdatePasswordHash(ctx context.Context, tx *sql.Tx, user *entity.User, oldHash string) error {
+	return nil
+}
+
+func newTestUserRepositoryCache(t *testing.T) (*UserRepositoryCache, *countingUserRepository, *cache.Memory, *sql.DB) {
+	next := &countingUserRepository{user: entity.User{Id: 1, Username: "alice", Password: "$argon2id$hash", Role: entity.RoleUser}}
+	memory := cache.NewMemory(10)
+	repo := NewUserRepositoryCache(next, memory, time.Hour, slog.New(slog.NewTextHandler(io.Discard, nil))).(*UserRepositoryCache)
+
+	return repo, next, memory, openNopDB(t)
+}
+
+func findUser(t *testing.T, repo repository.UserRepository, db *sql.DB, opts ...helper.TxOption) entity.User {
+	t.Helper()
+
+	user, err := helper.WithTx(context.Background(), db, func(ctx context.Context, tx *sql.Tx) (entity.User, error) {
+		return repo.FindByID(ctx, tx, 1)
+	}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+func TestUserRepositoryCacheReadOnly(t *testing.T) {
+	repo, next, memory, db := newTestUserRepositoryCache(t)
+
+	for i := 1; i <= 2; i++ {
+		user := findUser(t, repo, db, helper.ReadOnly())
+		if user.Username != "alice" || user.Password != "" {
+			t.Errorf("read %d = %+v, want alice without password", i, user)
+		}
+	}
+	if next.reads != 1 {
+		t.Errorf("database reads = %d, want 1", next.reads)
+	}
+
+	value, found, _ := memory.Get(context.Background(), userCacheKey(1))
+	if !found || strings.Contains(string(value), "argon2id") {
+		t.Errorf("cached entry = %s, want one without the password hash", value)
+	}
+}
+
+func TestUserRepositoryCacheReadWrite(t *testing.T) {
+	repo, next, memory, db := newTestUserRepositoryCache(t)
+
+	// read write transactions always read the database, and get the password
+	for i := 1; i <= 2; i++ {
+		if user := findUser(t, repo, db); user.Password != next.user.Password {
+			t.Errorf("read %d password = %q, want the hash", i, user.Password)
+		}
+	}
+	if next.reads != 2 {
+		t.Errorf("database reads = %d, want 2", next.reads)
+	}
+	if memory.Len() != 0 {
+		t.Error("read write transaction filled the cache")
+	}
+
+	// even when a read only transaction cached the user
+	findUser(t, repo, db, helper.ReadOnly())
+	if user := findUser(t, repo, db); user.Password != next.user.Password {
+		t.Errorf("read after caching password = %q, want the hash", user.Password)
+	}
+}
+
+func TestUserRepositoryCacheInvalidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(ctx context.Context, repo repository.UserRepository, tx *sql.Tx, user *entity.User) error
+	}{
+		{"update", func(ctx context.Context, repo repository.UserRepository, tx *sql.Tx, user *entity.User) error {
+			return repo.Update(ctx, tx, user)
+		}},
+		{"delete", func(ctx context.Context, repo repository.UserRepository, tx *sql.Tx, user *entity.User) error {
+			return repo.Delete(ctx, tx, user)
+		}},
+		{"restore", func(ctx context.Context, repo repository.UserRepository, tx *sql.Tx, user *entity.User) error {
+			return repo.Restore(ctx, tx, user)
+		}},
+		{"purge", func(ctx context.Context, repo repository.UserRepository, tx *sql.Tx, user *entity.User) error {
+			return repo.Purge(ctx, tx, user)
+		}},
+		{"change password", func(ctx context.Context, repo repository.UserRepository, tx *sql.Tx, user *entity.User) error {
+			return repo.ChangePassword(ctx, tx, user)
+		}},
+		{"rehash password", func(ctx context.Context, repo repository.UserRepository, tx *sql.Tx, user *entity.User) error {
+			return repo.UpdatePasswordHash(ctx, tx, user, "$argon2id$hash")
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, next, memory, db := newTestUserRepositoryCache(t)
+			findUser(t, repo, db, helper.ReadOnly())
+
+			err := helper.RunTx(context.Background(), db, func(ctx context.Context, tx *sql.Tx) error {
+				if err := tt.write(ctx, repo, tx, &entity.User{Id: 1}); err != nil {
+					return err
+				}
+				if memory.Len() != 0 {
+					t.Error("entry kept after the write")
+				}
+
+				// a read only request caching the old row before the commit
+				findUser(t, repo, db, helper.ReadOnly())
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if memory.Len() != 0 {
+				t.Error("entry cached during the transaction kept after the commit")
+			}
+			findUser(t, repo, db, helper.ReadOnly())
+			if next.reads != 3 {
+				t.Errorf("database reads = %d, want 3", next.reads)
+			}
+		})
+	}
+}
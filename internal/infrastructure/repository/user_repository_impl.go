@@ -5,12 +5,17 @@ import (
 	"database/sql"
 	"gofiber-cleanarch-test/internal/domain/entity"
 	"gofiber-cleanarch-test/internal/domain/repository"
+	"log/slog"
 )
 
-type UserRepositoryImpl struct{}
+type UserRepositoryImpl struct {
+	Logger *slog.Logger
+}
 
-func NewUserRepository() repository.UserRepository {
-	return &UserRepositoryImpl{}
+func NewUserRepository(logger *slog.Logger) repository.UserRepository {
+	return &UserRepositoryImpl{
+		Logger: logger,
+	}
 }
 
 func (r *UserRepositoryImpl) Save(ctx context.Context, tx *sql.Tx, user *entity.User) (entity.User, error) {
@@ -37,11 +42,42 @@ func (r *UserRepositoryImpl) Update(ctx context.Context, tx *sql.Tx, user *entit
 }
 
 func (r *UserRepositoryImpl) Delete(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	sql := "update users set is_deleted = true, deleted_at = NOW(), updated_at = NOW() where id = $1"
+	result, err := tx.ExecContext(ctx, sql, user.Id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	r.Logger.DebugContext(ctx, "user soft deleted", "user_id", user.Id, "rows", rows)
+
+	return nil
+}
+
+func (r *UserRepositoryImpl) Restore(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	sql := "update users set is_deleted = false, deleted_at = null, updated_at = NOW() where id = $1"
+	result, err := tx.ExecContext(ctx, sql, user.Id)
+	if err != nil {
+		return err
+	}
+
+	rows, _ := result.RowsAffected()
+	r.Logger.DebugContext(ctx, "user restored", "user_id", user.Id, "rows", rows)
+
+	return nil
+}
+
+// Purge remove the row permanently, unlike Delete that only mark it as deleted
+func (r *UserRepositoryImpl) Purge(ctx context.Context, tx *sql.Tx, user *entity.User) error {
 	sql := "delete from users where id = $1"
-	if _, err := tx.ExecContext(ctx, sql, user.Id); err != nil {
+	result, err := tx.ExecContext(ctx, sql, user.Id)
+	if err != nil {
 		return err
 	}
 
+	rows, _ := result.RowsAffected()
+	r.Logger.DebugContext(ctx, "user purged", "user_id", user.Id, "rows", rows)
+
 	return nil
 }
 
@@ -54,12 +90,35 @@ func (r *UserRepositoryImpl) ChangePassword(ctx context.Context, tx *sql.Tx, use
 	return nil
 }
 
+// UpdatePasswordHash keep updated_at, the password itself did not change. A password changed
+// concurrently is not overwritten.
+func (r *UserRepositoryImpl) UpdatePasswordHash(ctx context.Context, tx *sql.Tx, user *entity.User, oldHash string) error {
+	sql := "update users set password = $1 where id = $2 and password = $3"
+	if _, err := tx.ExecContext(ctx, sql, user.Password, user.Id, oldHash); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (r *UserRepositoryImpl) FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.User, error) {
 	var user entity.User
 
-	sql := "select id, username, password, role, created_at, updated_at, is_deleted from users where id = $1 and is_deleted = false"
+	sql := "select u.id, u.username, u.password, u.role, coalesce(r.name, ''), coalesce(r.require_2fa, false), u.created_at, u.updated_at, u.is_deleted from users u left join role r on r.id = u.role where u.id = $1 and u.is_deleted = false"
+
+	if err := tx.QueryRowContext(ctx, sql, id).Scan(&user.Id, &user.Username, &user.Password, &user.Role, &user.RoleName, &user.RoleRequire2FA, &user.CreatedAt, &user.UpdatedAt, &user.IsDeleted); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+func (r *UserRepositoryImpl) FindByIDWithDeleted(ctx context.Context, tx *sql.Tx, id int) (entity.User, error) {
+	var user entity.User
+
+	sql := "select u.id, u.username, u.password, u.role, coalesce(r.name, ''), coalesce(r.require_2fa, false), u.created_at, u.updated_at, u.is_deleted, u.deleted_at from users u left join role r on r.id = u.role where u.id = $1"
 
-	if err := tx.QueryRowContext(ctx, sql, id).Scan(&user.Id, &user.Username, &user.Password, &user.Role, &user.CreatedAt, &user.UpdatedAt, &user.IsDeleted); err != nil {
+	if err := tx.QueryRowContext(ctx, sql, id).Scan(&user.Id, &user.Username, &user.Password, &user.Role, &user.RoleName, &user.RoleRequire2FA, &user.CreatedAt, &user.UpdatedAt, &user.IsDeleted, &user.DeletedAt); err != nil {
 		return user, err
 	}
 
@@ -69,9 +128,22 @@ func (r *UserRepositoryImpl) FindByID(ctx context.Context, tx *sql.Tx, id int) (
 func (r *UserRepositoryImpl) FindByUsername(ctx context.Context, tx *sql.Tx, username string) (entity.User, error) {
 	var user entity.User
 
-	sql := "select id, username, password, role, created_at, updated_at, is_deleted from users where username = $1 and is_deleted = false"
+	sql := "select u.id, u.username, u.password, u.role, coalesce(r.name, ''), coalesce(r.require_2fa, false), u.created_at, u.updated_at, u.is_deleted from users u left join role r on r.id = u.role where u.username = $1 and u.is_deleted = false"
+
+	if err := tx.QueryRowContext(ctx, sql, username).Scan(&user.Id, &user.Username, &user.Password, &user.Role, &user.RoleName, &user.RoleRequire2FA, &user.CreatedAt, &user.UpdatedAt, &user.IsDeleted); err != nil {
+		return user, err
+	}
+
+	return user, nil
+}
+
+// FindByUsernameWithDeleted prefer the active user when deleted users with the same username also exist
+func (r *UserRepositoryImpl) FindByUsernameWithDeleted(ctx context.Context, tx *sql.Tx, username string) (entity.User, error) {
+	var user entity.User
+
+	sql := "select u.id, u.username, u.password, u.role, coalesce(r.name, ''), coalesce(r.require_2fa, false), u.created_at, u.updated_at, u.is_deleted, u.deleted_at from users u left join role r on r.id = u.role where u.username = $1 order by u.is_deleted asc, u.id desc limit 1"
 
-	if err := tx.QueryRowContext(ctx, sql, username).Scan(&user.Id, &user.Username, &user.Password, &user.Role, &user.CreatedAt, &user.UpdatedAt, &user.IsDeleted); err != nil {
+	if err := tx.QueryRowContext(ctx, sql, username).Scan(&user.Id, &user.Username, &user.Password, &user.Role, &user.RoleName, &user.RoleRequire2FA, &user.CreatedAt, &user.UpdatedAt, &user.IsDeleted, &user.DeletedAt); err != nil {
 		return user, err
 	}
 
@@ -81,7 +153,7 @@ func (r *UserRepositoryImpl) FindByUsername(ctx context.Context, tx *sql.Tx, use
 func (r *UserRepositoryImpl) FindAllWithPagination(ctx context.Context, tx *sql.Tx, limit int, offset int) ([]entity.User, error) {
 	var users []entity.User
 
-	sql := "select id, username, role, created_at, updated_at from users where is_deleted=false limit $1 offset $2"
+	sql := "select u.id, u.username, u.role, coalesce(r.name, ''), u.created_at, u.updated_at from users u left join role r on r.id = u.role where u.is_deleted=false order by u.id limit $1 offset $2"
 	rows, err := tx.QueryContext(ctx, sql, limit, offset)
 	if err != nil {
 		return nil, err
@@ -90,7 +162,7 @@ func (r *UserRepositoryImpl) FindAllWithPagination(ctx context.Context, tx *sql.
 
 	for rows.Next() {
 		var user entity.User
-		err := rows.Scan(&user.Id, &user.Username, &user.Role, &user.CreatedAt, &user.UpdatedAt)
+		err := rows.Scan(&user.Id, &user.Username, &user.Role, &user.RoleName, &user.CreatedAt, &user.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "gofiber-cleanarch-test/internal/infrastructure/repository"
+
+// UserRepositoryTracing wrap a UserRepository with one span per call, the sql statements
+// below it get their own span from the instrumented driver
+type UserRepositoryTracing struct {
+	Next   repository.UserRepository
+	Tracer trace.Tracer
+}
+
+func NewUserRepositoryTracing(next repository.UserRepository) repository.UserRepository {
+	return &UserRepositoryTracing{
+		Next:   next,
+		Tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (r *UserRepositoryTracing) Save(ctx context.Context, tx *sql.Tx, user *entity.User) (entity.User, error) {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.Save")
+	res, err := r.Next.Save(ctx, tx, user)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (r *UserRepositoryTracing) Update(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.Update", userIdAttribute(user.Id))
+	err := r.Next.Update(ctx, tx, user)
+	endSpan(span, err)
+
+	return err
+}
+
+func (r *UserRepositoryTracing) Delete(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.Delete", userIdAttribute(user.Id))
+	err := r.Next.Delete(ctx, tx, user)
+	endSpan(span, err)
+
+	return err
+}
+
+func (r *UserRepositoryTracing) Restore(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.Restore", userIdAttribute(user.Id))
+	err := r.Next.Restore(ctx, tx, user)
+	endSpan(span, err)
+
+	return err
+}
+
+func (r *UserRepositoryTracing) Purge(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.Purge", userIdAttribute(user.Id))
+	err := r.Next.Purge(ctx, tx, user)
+	endSpan(span, err)
+
+	return err
+}
+
+func (r *UserRepositoryTracing) ChangePassword(ctx context.Context, tx *sql.Tx, user *entity.User) error {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.ChangePassword", userIdAttribute(user.Id))
+	err := r.Next.ChangePassword(ctx, tx, user)
+	endSpan(span, err)
+
+	return err
+}
+
+func (r *UserRepositoryTracing) UpdatePasswordHash(ctx context.Context, tx *sql.Tx, user *entity.User, oldHash string) error {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.UpdatePasswordHash", userIdAttribute(user.Id))
+	err := r.Next.UpdatePasswordHash(ctx, tx, user, oldHash)
+	endSpan(span, err)
+
+	return err
+}
+
+func (r *UserRepositoryTracing) FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.User, error) {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.FindByID", userIdAttribute(id))
+	res, err := r.Next.FindByID(ctx, tx, id)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (r *UserRepositoryTracing) FindByIDWithDeleted(ctx context.Context, tx *sql.Tx, id int) (entity.User, error) {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.FindByIDWithDeleted", userIdAttribute(id))
+	res, err := r.Next.FindByIDWithDeleted(ctx, tx, id)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (r *UserRepositoryTracing) FindByUsername(ctx context.Context, tx *sql.Tx, username string) (entity.User, error) {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.FindByUsername")
+	res, err := r.Next.FindByUsername(ctx, tx, username)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (r *UserRepositoryTracing) FindByUsernameWithDeleted(ctx context.Context, tx *sql.Tx, username string) (entity.User, error) {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.FindByUsernameWithDeleted")
+	res, err := r.Next.FindByUsernameWithDeleted(ctx, tx, username)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (r *UserRepositoryTracing) FindAllWithPagination(ctx context.Context, tx *sql.Tx, limit int, offset int) ([]entity.User, error) {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.FindAllWithPagination", trace.WithAttributes(attribute.Int("limit", limit), attribute.Int("offset", offset)))
+	res, err := r.Next.FindAllWithPagination(ctx, tx, limit, offset)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (r *UserRepositoryTracing) FindTotal(ctx context.Context, tx *sql.Tx) (int, error) {
+	ctx, span := r.Tracer.Start(ctx, "UserRepository.FindTotal")
+	res, err := r.Next.FindTotal(ctx, tx)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func userIdAttribute(id int) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.Int("user.id", id))
+}
+
+// endSpan record the error and end the span, sql.ErrNoRows is an expected result and not an error
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -36,7 +36,57 @@ func (h *AuthController) Login(c *fiber.Ctx) error {
 		}
 	}
 
-	token, err := h.authService.LoginUser(c.Context(), loginInput)
+	loginInput.IP = c.IP()
+
+	token, err := h.authService.LoginUser(c.UserContext(), loginInput)
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if token.TwoFactorRequired {
+		return helper.RespondWithData(c, fiber.StatusOK, "two factor authentication required", token)
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success login", token)
+}
+
+func (h *AuthController) LoginTwoFactor(c *fiber.Ctx) error {
+	loginInput := new(dto.LoginTwoFactorInput)
+	if err := c.BodyParser(loginInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := helper.ValidateStruct(loginInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Challenge token and code are required")
+	}
+
+	loginInput.IP = c.IP()
+
+	token, err := h.authService.LoginTwoFactor(c.UserContext(), loginInput)
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success login", token)
+}
+
+func (h *AuthController) RefreshToken(c *fiber.Ctx) error {
+	refreshInput := new(dto.RefreshTokenInput)
+	if err := c.BodyParser(refreshInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := helper.ValidateStruct(refreshInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Refresh token is required")
+	}
+
+	token, err := h.authService.RefreshToken(c.UserContext(), refreshInput)
 	if err != nil {
 		if e, ok := err.(helper.AppError); ok {
 			return helper.RespondError(c, e.Code, e.Message)
@@ -44,9 +94,26 @@ func (h *AuthController) Login(c *fiber.Ctx) error {
 		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
 	}
 
-	return helper.RespondWithData(c, fiber.StatusOK, "success login", fiber.Map{
-		"token":        token.Token,
-		"token_type":   "Bearer",
-		"expired_time": "8h",
-	})
+	return helper.RespondWithData(c, fiber.StatusOK, "success refresh token", token)
+}
+
+func (h *AuthController) Logout(c *fiber.Ctx) error {
+	user := c.Locals("user").(dto.UserSession)
+
+	// body is optional, refresh token only needed to end the refresh token family too
+	logoutInput := new(dto.LogoutInput)
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(logoutInput); err != nil {
+			return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+		}
+	}
+
+	if err := h.authService.Logout(c.UserContext(), user, logoutInput); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success logout")
 }
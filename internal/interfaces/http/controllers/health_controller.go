@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"gofiber-cleanarch-test/pkg/health"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthController struct {
+	registry *health.Registry
+}
+
+func NewHealthController(registry *health.Registry) *HealthController {
+	return &HealthController{registry}
+}
+
+// Liveness only tell the process is able to serve, dependencies are not checked so a database
+// outage does not restart every instance
+func (h *HealthController) Liveness(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"status": health.StatusUp,
+	})
+}
+
+// Readiness run every registered check, response use the plain report (not wrapped) for probes
+func (h *HealthController) Readiness(c *fiber.Ctx) error {
+	report := h.registry.Check(c.UserContext())
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	if !report.Healthy() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(report)
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"gofiber-cleanarch-test/pkg/tokensigner"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type JWKSController struct {
+	tokenSigner *tokensigner.Signer
+}
+
+func NewJWKSController(tokenSigner *tokensigner.Signer) *JWKSController {
+	return &JWKSController{tokenSigner}
+}
+
+// GetJWKS publish public verification keys, response use the plain JWKS format (not wrapped) so standard JWT libraries can read it
+func (h *JWKSController) GetJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+
+	return c.Status(fiber.StatusOK).JSON(h.tokenSigner.JWKS())
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/internal/service"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/password"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+)
+
+type PasswordController struct {
+	passwordResetService service.PasswordResetService
+}
+
+func NewPasswordController(passwordResetService service.PasswordResetService) *PasswordController {
+	return &PasswordController{
+		passwordResetService: passwordResetService,
+	}
+}
+
+func (h *PasswordController) Forgot(c *fiber.Ctx) error {
+	forgotInput := new(dto.PasswordForgotInput)
+	if err := c.BodyParser(forgotInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := helper.ValidateStruct(forgotInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Username is required")
+	}
+
+	if err := h.passwordResetService.Forgot(c.UserContext(), forgotInput); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	// same response for unknown usernames
+	return helper.RespondMessage(c, fiber.StatusOK, "if the account exist, a password reset link has been sent")
+}
+
+func (h *PasswordController) Reset(c *fiber.Ctx) error {
+	resetInput := new(dto.PasswordResetInput)
+	if err := c.BodyParser(resetInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := helper.ValidateStruct(resetInput); err != nil {
+		for _, err := range err.(validator.ValidationErrors) {
+			switch err.Field() {
+			case "Token":
+				return helper.RespondError(c, fiber.StatusBadRequest, "Token is required")
+			case "Password":
+				return helper.RespondError(c, fiber.StatusBadRequest, "Password is required")
+			default:
+				return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+			}
+		}
+	}
+
+	if err := h.passwordResetService.Reset(c.UserContext(), resetInput); err != nil {
+		if e, ok := err.(*password.PolicyError); ok {
+			return helper.RespondErrorWithDetails(c, fiber.StatusBadRequest, "Password does not meet the password policy", e.Violations)
+		}
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success reset password, please login again")
+}
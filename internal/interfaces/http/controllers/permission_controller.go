@@ -0,0 +1,75 @@
+package controllers
+
+import (
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/internal/service"
+	"gofiber-cleanarch-test/pkg/helper"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PermissionController struct {
+	permissionService service.PermissionService
+}
+
+func NewPermissionController(permissionService service.PermissionService) *PermissionController {
+	return &PermissionController{
+		permissionService: permissionService,
+	}
+}
+
+func (h *PermissionController) GetAllPermissions(c *fiber.Ctx) error {
+	permissions, err := h.permissionService.FindAll(c.UserContext())
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success get permissions data", permissions)
+}
+
+func (h *PermissionController) GetRolePermissions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid role id")
+	}
+
+	permissions, err := h.permissionService.FindByRoleId(c.UserContext(), id)
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success get role permissions data", permissions)
+}
+
+func (h *PermissionController) SetRolePermissions(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid role id")
+	}
+
+	permissionInput := new(dto.RolePermissionsUpdate)
+	if err = c.BodyParser(permissionInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+	permissionInput.RoleId = id
+
+	if err = helper.ValidateStruct(permissionInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Permissions is required, send empty list to remove all permissions")
+	}
+
+	if err = h.permissionService.SetRolePermissions(c.UserContext(), permissionInput); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success edit role permissions")
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/internal/service"
+	"gofiber-cleanarch-test/pkg/helper"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RoleController struct {
+	roleService service.RoleService
+}
+
+func NewRoleController(roleService service.RoleService) *RoleController {
+	return &RoleController{
+		roleService: roleService,
+	}
+}
+
+func (h *RoleController) GetAllRoles(c *fiber.Ctx) error {
+	roles, err := h.roleService.FindAll(c.UserContext())
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success get roles data", roles)
+}
+
+func (h *RoleController) GetRoleById(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid role id")
+	}
+
+	role, err := h.roleService.FindById(c.UserContext(), id)
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success get role data", role)
+}
+
+func (h *RoleController) CreateRole(c *fiber.Ctx) error {
+	roleInput := new(dto.RoleCreate)
+	if err := c.BodyParser(roleInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := helper.ValidateStruct(roleInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Name minimum 3 and maximum 50 characters")
+	}
+
+	role, err := h.roleService.Create(c.UserContext(), roleInput)
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success create role", role)
+}
+
+func (h *RoleController) EditRole(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid role id")
+	}
+
+	roleInput := new(dto.RoleUpdate)
+	if err = c.BodyParser(roleInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+	roleInput.Id = id
+
+	if err = helper.ValidateStruct(roleInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Name minimum 3 and maximum 50 characters")
+	}
+
+	if err = h.roleService.Update(c.UserContext(), roleInput); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success edit role")
+}
+
+func (h *RoleController) DeleteRole(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid role id")
+	}
+
+	if err = h.roleService.Delete(c.UserContext(), id); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success delete role")
+}
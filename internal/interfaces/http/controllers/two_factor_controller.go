@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/internal/service"
+	"gofiber-cleanarch-test/pkg/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TwoFactorController struct {
+	twoFactorService service.TwoFactorService
+}
+
+func NewTwoFactorController(twoFactorService service.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{
+		twoFactorService: twoFactorService,
+	}
+}
+
+func (h *TwoFactorController) Enroll(c *fiber.Ctx) error {
+	user := c.Locals("user").(dto.UserSession)
+
+	enrollment, err := h.twoFactorService.Enroll(c.UserContext(), user)
+	if err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondWithData(c, fiber.StatusOK, "success enroll two factor authentication, confirm it with a code", enrollment)
+}
+
+func (h *TwoFactorController) Confirm(c *fiber.Ctx) error {
+	user := c.Locals("user").(dto.UserSession)
+
+	confirmInput := new(dto.TwoFactorConfirmInput)
+	if err := c.BodyParser(confirmInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := helper.ValidateStruct(confirmInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Code must be 6 digits")
+	}
+
+	if err := h.twoFactorService.Confirm(c.UserContext(), user, confirmInput); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success enable two factor authentication")
+}
+
+func (h *TwoFactorController) Disable(c *fiber.Ctx) error {
+	user := c.Locals("user").(dto.UserSession)
+
+	disableInput := new(dto.TwoFactorDisableInput)
+	if err := c.BodyParser(disableInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if err := helper.ValidateStruct(disableInput); err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Password and code are required")
+	}
+
+	if err := h.twoFactorService.Disable(c.UserContext(), user, disableInput); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success disable two factor authentication")
+}
@@ -4,6 +4,7 @@ import (
 	"gofiber-cleanarch-test/internal/interfaces/http/dto"
 	"gofiber-cleanarch-test/internal/service"
 	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/password"
 	"strconv"
 
 	"github.com/go-playground/validator/v10"
@@ -32,7 +33,7 @@ func (h *UserController) GetAllUsers(c *fiber.Ctx) error {
 	}
 	offset := (page - 1) * per_page
 
-	users, err := h.userService.FindAllWithPagination(c.Context(), per_page, offset)
+	users, err := h.userService.FindAllWithPagination(c.UserContext(), per_page, offset)
 	if err != nil {
 		if e, ok := err.(helper.AppError); ok {
 			return helper.RespondError(c, e.Code, e.Message)
@@ -49,7 +50,7 @@ func (h *UserController) GetUserById(c *fiber.Ctx) error {
 		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid user id")
 	}
 
-	user, err := h.userService.FindById(c.Context(), id)
+	user, err := h.userService.FindById(c.UserContext(), id)
 	if err != nil {
 		if e, ok := err.(helper.AppError); ok {
 			return helper.RespondError(c, e.Code, e.Message)
@@ -70,16 +71,19 @@ func (h *UserController) CreateUser(c *fiber.Ctx) error {
 		for _, err := range err.(validator.ValidationErrors) {
 			switch err.Field() {
 			case "Username":
-				return helper.RespondError(c, fiber.StatusBadRequest, "Username minimal 5 karakter, merupakan alphanumerik")
+				return helper.RespondError(c, fiber.StatusBadRequest, "Username must be 5 to 50 alphanumeric characters")
 			case "Password":
-				return helper.RespondError(c, fiber.StatusBadRequest, "Password minimal 6 karakter, mengandung angka dan huruf besar")
+				return helper.RespondError(c, fiber.StatusBadRequest, "Password is required")
 			default:
 				return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
 			}
 		}
 	}
 
-	if _, err := h.userService.Create(c.Context(), userInput); err != nil {
+	if _, err := h.userService.Create(c.UserContext(), userInput); err != nil {
+		if e, ok := err.(*password.PolicyError); ok {
+			return helper.RespondErrorWithDetails(c, fiber.StatusBadRequest, "Password does not meet the password policy", e.Violations)
+		}
 		if e, ok := err.(helper.AppError); ok {
 			return helper.RespondError(c, e.Code, e.Message)
 		}
@@ -105,18 +109,21 @@ func (h *UserController) EditUserPassword(c *fiber.Ctx) error {
 		for _, err := range err.(validator.ValidationErrors) {
 			switch err.Field() {
 			case "Id":
-				return helper.RespondError(c, fiber.StatusBadRequest, "Id tidak boleh kosong")
+				return helper.RespondError(c, fiber.StatusBadRequest, "Id is required")
 			case "OldPassword":
-				return helper.RespondError(c, fiber.StatusBadRequest, "Old Password tidak boleh kosong")
+				return helper.RespondError(c, fiber.StatusBadRequest, "Old password is required")
 			case "Password":
-				return helper.RespondError(c, fiber.StatusBadRequest, "Password minimal 6 karakter, mengandung angka dan huruf besar")
+				return helper.RespondError(c, fiber.StatusBadRequest, "Password is required")
 			default:
 				return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
 			}
 		}
 	}
 
-	if err = h.userService.ChangePassword(c.Context(), userInput); err != nil {
+	if err = h.userService.ChangePassword(c.UserContext(), userInput); err != nil {
+		if e, ok := err.(*password.PolicyError); ok {
+			return helper.RespondErrorWithDetails(c, fiber.StatusBadRequest, "Password does not meet the password policy", e.Violations)
+		}
 		if e, ok := err.(helper.AppError); ok {
 			return helper.RespondError(c, e.Code, e.Message)
 		}
@@ -142,16 +149,16 @@ func (h *UserController) EditUser(c *fiber.Ctx) error {
 		for _, err := range err.(validator.ValidationErrors) {
 			switch err.Field() {
 			case "Id":
-				return helper.RespondError(c, fiber.StatusBadRequest, "Id tidak boleh kosong")
+				return helper.RespondError(c, fiber.StatusBadRequest, "Id is required")
 			case "Username":
-				return helper.RespondError(c, fiber.StatusBadRequest, "Username minimal 5 karakter, merupakan alphanumerik")
+				return helper.RespondError(c, fiber.StatusBadRequest, "Username must be 5 to 50 alphanumeric characters")
 			default:
 				return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
 			}
 		}
 	}
 
-	if err = h.userService.Update(c.Context(), userInput); err != nil {
+	if err = h.userService.Update(c.UserContext(), userInput); err != nil {
 		if e, ok := err.(helper.AppError); ok {
 			return helper.RespondError(c, e.Code, e.Message)
 		}
@@ -167,7 +174,19 @@ func (h *UserController) DeleteUser(c *fiber.Ctx) error {
 		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid user id")
 	}
 
-	if err = h.userService.Delete(c.Context(), id); err != nil {
+	// purge remove the user permanently, the service check the purge policy on top of the route policy
+	if c.QueryBool("purge") {
+		if err = h.userService.Purge(c.UserContext(), id); err != nil {
+			if e, ok := err.(helper.AppError); ok {
+				return helper.RespondError(c, e.Code, e.Message)
+			}
+			return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+		}
+
+		return helper.RespondMessage(c, fiber.StatusOK, "success purge user")
+	}
+
+	if err = h.userService.Delete(c.UserContext(), id); err != nil {
 		if e, ok := err.(helper.AppError); ok {
 			return helper.RespondError(c, e.Code, e.Message)
 		}
@@ -176,3 +195,35 @@ func (h *UserController) DeleteUser(c *fiber.Ctx) error {
 
 	return helper.RespondMessage(c, fiber.StatusOK, "success delete user")
 }
+
+func (h *UserController) UnlockUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid user id")
+	}
+
+	if err = h.userService.Unlock(c.UserContext(), id); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success unlock user login")
+}
+
+func (h *UserController) RestoreUser(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusBadRequest, "Invalid user id")
+	}
+
+	if err = h.userService.Restore(c.UserContext(), id); err != nil {
+		if e, ok := err.(helper.AppError); ok {
+			return helper.RespondError(c, e.Code, e.Message)
+		}
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	return helper.RespondMessage(c, fiber.StatusOK, "success restore user")
+}
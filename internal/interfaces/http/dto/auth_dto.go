@@ -1,10 +1,81 @@
 package dto
 
+import "github.com/golang-jwt/jwt/v5"
+
+// TokenPurposeTwoFactor mark the challenge token returned by a login that still need a second factor
+const TokenPurposeTwoFactor = "2fa"
+
+// TokenClaims is the access token payload, sub hold the user id
+type TokenClaims struct {
+	Role int `json:"role"`
+	// MFA is set when the login completed a second factor
+	MFA bool `json:"mfa,omitempty"`
+	// Purpose is only set on tokens that are not access tokens, IsAuth refuse them
+	Purpose string `json:"purpose,omitempty"`
+	jwt.RegisteredClaims
+}
+
 type LoginInput struct {
-	Username string `json:"username" validate:"required,alphanum"`
+	Username string `json:"username" validate:"required,max=50,alphanum"`
 	Password string `json:"password" validate:"required"`
+	// IP of the client, set by the controller for the lockout of the ip
+	IP string `json:"-" form:"-"`
+}
+
+type LoginTwoFactorInput struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	// Code is the current authenticator code or a recovery code
+	Code string `json:"code" validate:"required"`
+	IP   string `json:"-" form:"-"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type PasswordForgotInput struct {
+	Username string `json:"username" validate:"required"`
 }
 
+type PasswordResetInput struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// LoginResponse either hold the tokens or, when TwoFactorRequired, the challenge to send to /login/2fa
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token                   string `json:"token,omitempty"`
+	TokenType               string `json:"token_type,omitempty"`
+	ExpiredTime             string `json:"expired_time,omitempty"`
+	RefreshToken            string `json:"refresh_token,omitempty"`
+	RefreshTokenExpiredTime string `json:"refresh_token_expired_time,omitempty"`
+
+	TwoFactorRequired    bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken       string `json:"challenge_token,omitempty"`
+	ChallengeExpiredTime string `json:"challenge_expired_time,omitempty"`
+	// TwoFactorEnrollmentRequired is set when the role require two factor and the user did not
+	// enroll yet, the token is only accepted to enroll and logout
+	TwoFactorEnrollmentRequired bool `json:"two_factor_enrollment_required,omitempty"`
+}
+
+type TwoFactorEnrollResponse struct {
+	Secret string `json:"secret"`
+	// URI is the otpauth:// uri to show as QR code
+	URI string `json:"uri"`
+	// RecoveryCodes are shown only once, each one replace a code a single time
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type TwoFactorConfirmInput struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type TwoFactorDisableInput struct {
+	Password string `json:"password" validate:"required"`
+	// Code is the current authenticator code or a recovery code
+	Code string `json:"code" validate:"required"`
 }
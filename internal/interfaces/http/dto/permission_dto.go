@@ -0,0 +1,12 @@
+package dto
+
+type PermissionResponse struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type RolePermissionsUpdate struct {
+	RoleId      int      `json:"role_id" validate:"required"`
+	Permissions []string `json:"permissions" validate:"required"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+type RoleCreate struct {
+	Name       string `json:"name" validate:"required,min=3,max=50"`
+	Require2FA bool   `json:"require_2fa"`
+}
+
+type RoleUpdate struct {
+	Id   int    `json:"id" validate:"required"`
+	Name string `json:"name" validate:"required,min=3,max=50"`
+	// Require2FA keep the current value when omitted
+	Require2FA *bool `json:"require_2fa"`
+}
+
+type RoleResponse struct {
+	Id         int    `json:"id"`
+	Name       string `json:"name"`
+	Require2FA bool   `json:"require_2fa"`
+}
@@ -1,8 +1,11 @@
 package dto
 
+import "time"
+
 type UserCreate struct {
 	Username string `json:"username" validate:"required,min=5,max=50,alphanum"`
-	Password string `json:"password" validate:"required,min=6,max=50,containsany=1234567890,containsany=QWERTYUIOPASDFGHJKLZXCVBNM"`
+	// Password rules are checked by the password policy of the service, they are configurable
+	Password string `json:"password" validate:"required"`
 	Role     int    `json:"role"`
 }
 
@@ -15,19 +18,43 @@ type UserUpdate struct {
 type UserChangePassword struct {
 	Id          int    `json:"id" validate:"required"`
 	OldPassword string `json:"old_password" validate:"required"`
-	Password    string `json:"password" validate:"required,min=6,max=50,containsany=1234567890,containsany=QWERTYUIOPASDFGHJKLZXCVBNM"`
+	Password    string `json:"password" validate:"required"`
+}
+
+// UserResetPassword set a new password without the old one, used by admin tooling
+type UserResetPassword struct {
+	Id       int    `json:"id" validate:"required"`
+	Password string `json:"password" validate:"required"`
 }
 
 type UserResponse struct {
 	Id        int    `json:"id"`
 	Username  string `json:"username"`
 	Role      int    `json:"role"`
+	RoleName  string `json:"role_name"`
 	CreatedAt string `json:"created_at"`
 	UpdatedAt string `json:"updated_at"`
+	// TwoFactorRequired is set when the role of the user require two factor authentication
+	TwoFactorRequired bool `json:"two_factor_required"`
 }
 
 type UserSession struct {
-	Id       int    `json:"id"`
-	Username string `json:"username"`
-	Role     int    `json:"role"`
+	Id             int       `json:"id"`
+	Username       string    `json:"username"`
+	Role           int       `json:"role"`
+	Permissions    []string  `json:"permissions"`
+	TokenId        string    `json:"-"`
+	TokenExpiresAt time.Time `json:"-"`
+	// MFA is set when the token come from a login that completed a second factor
+	MFA bool `json:"mfa"`
+}
+
+func (s UserSession) HasPermission(permission string) bool {
+	for _, p := range s.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
 }
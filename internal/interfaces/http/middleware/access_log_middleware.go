@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"gofiber-cleanarch-test/pkg/helper"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AccessLog write one structured line per request, must run after RequestID so the line carry the
+// request id. 5xx are logged as error and 4xx as warn with the message returned to the client.
+func AccessLog(logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		message, _ := c.Locals(helper.ErrorMessageLocal).(string)
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+			message = err.Error()
+		}
+
+		route := c.Route().Path
+		if route == "/" && c.Path() != "/" {
+			route = unmatchedRoute
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("route", route),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("ip", c.IP()),
+		}
+		if message != "" {
+			attrs = append(attrs, slog.String("error", message))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= fiber.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= fiber.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		logger.LogAttrs(c.UserContext(), level, "http request", attrs...)
+
+		return err
+	}
+}
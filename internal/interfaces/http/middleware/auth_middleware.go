@@ -1,57 +1,124 @@
 package middleware
 
 import (
-	"gofiber-cleanarch-test/internal/infrastructure/database"
-	"gofiber-cleanarch-test/internal/infrastructure/repository"
+	"gofiber-cleanarch-test/internal/domain/repository"
 	"gofiber-cleanarch-test/internal/interfaces/http/dto"
 	"gofiber-cleanarch-test/internal/service"
+	"gofiber-cleanarch-test/pkg/authz"
 	"gofiber-cleanarch-test/pkg/helper"
-	"os"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"strconv"
 	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func IsAuth(c *fiber.Ctx) error {
+type AuthMiddleware struct {
+	userService          service.UserService
+	permissionService    service.PermissionService
+	tokenRevocationStore repository.TokenRevocationStore
+	tokenSigner          *tokensigner.Signer
+}
+
+func NewAuthMiddleware(userService service.UserService, permissionService service.PermissionService, tokenRevocationStore repository.TokenRevocationStore, tokenSigner *tokensigner.Signer) *AuthMiddleware {
+	return &AuthMiddleware{
+		userService:          userService,
+		permissionService:    permissionService,
+		tokenRevocationStore: tokenRevocationStore,
+		tokenSigner:          tokenSigner,
+	}
+}
+
+// IsAuth also refuse users whose role require two factor authentication when the login did not
+// complete it
+func (m *AuthMiddleware) IsAuth(c *fiber.Ctx) error {
+	return m.authenticate(c, false)
+}
+
+// IsAuthFor2FASetup is IsAuth for the routes a user need before completing two factor
+// authentication required by its role: enrolling an authenticator and logging out
+func (m *AuthMiddleware) IsAuthFor2FASetup(c *fiber.Ctx) error {
+	return m.authenticate(c, true)
+}
+
+func (m *AuthMiddleware) authenticate(c *fiber.Ctx, twoFactorSetup bool) error {
 	header := c.Get("Authorization")
 	if header == "" {
 		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized")
 	}
 
-	headerSplit := strings.Split(header, "Bearer ")
-	if len(headerSplit) != 2 {
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
 		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized")
 	}
 
-	token := headerSplit[1]
-	if token == "" {
+	// decode token, signature, algorithm, exp/nbf/iat and issuer/audience are checked by the signer
+	claims := new(dto.TokenClaims)
+	if _, err := m.tokenSigner.Parse(token, claims); err != nil {
 		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized")
 	}
 
-	// decode token
-	decode_token, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+	// two factor challenge and other special tokens are not access tokens
+	if claims.Purpose != "" {
+		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized")
+	}
+
+	id, err := strconv.Atoi(claims.Subject)
 	if err != nil {
 		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized")
 	}
 
-	id := decode_token.Claims.(jwt.MapClaims)["id"].(float64)
+	// token without jti or iat can not be revoked, so it is not accepted
+	if claims.ID == "" || claims.IssuedAt == nil {
+		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized")
+	}
 
-	user_service := service.NewUserService(repository.NewUserRepository(), database.DB)
-	user, err := user_service.FindById(c.Context(), int(id))
+	// check revocation
+	revoked, err := m.tokenRevocationStore.IsTokenRevoked(c.UserContext(), claims.ID)
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	if revoked {
+		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized: token revoked")
+	}
+
+	revokedBefore, err := m.tokenRevocationStore.UserTokensRevokedBefore(c.UserContext(), id)
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+	// iat has second precision, a token issued in the revocation second is revoked too
+	if !claims.IssuedAt.After(revokedBefore) {
+		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized: token revoked")
+	}
+
+	user, err := m.userService.FindById(c.UserContext(), id)
 	if err != nil {
 		return helper.RespondError(c, fiber.StatusUnauthorized, "Unauthorized")
 	}
 
+	// the requirement is read from the (cached) user, a role change apply within the cache ttl
+	if user.TwoFactorRequired && !claims.MFA && !twoFactorSetup {
+		return helper.RespondError(c, fiber.StatusForbidden, "Two factor authentication required, enroll an authenticator and login again")
+	}
+
+	permissions, err := m.permissionService.PermissionNamesForRole(c.UserContext(), user.Role)
+	if err != nil {
+		return helper.RespondError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
 	userSession := dto.UserSession{
-		Id:       user.Id,
-		Username: user.Username,
-		Role:     user.Role,
+		Id:             user.Id,
+		Username:       user.Username,
+		Role:           user.Role,
+		Permissions:    permissions,
+		TokenId:        claims.ID,
+		TokenExpiresAt: claims.ExpiresAt.Time,
+		MFA:            claims.MFA,
 	}
 
 	c.Locals("user", userSession)
+	// services read the subject from context to run their own policy checks
+	c.SetUserContext(authz.WithSubject(c.UserContext(), SubjectFromSession(userSession)))
 
 	return c.Next()
 }
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// unmatchedRoute label requests answered before a route matched (404, rate limited, ...), the
+// raw path is never used as label so ids in the url can not explode the metric cardinality
+const unmatchedRoute = "unmatched"
+
+type HTTPMetrics interface {
+	ObserveHTTPRequest(method string, route string, status int, duration time.Duration)
+}
+
+// Metrics record every request labeled by the route template, e.g. /api/v1/users/:id
+func Metrics(metrics HTTPMetrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+
+		err := c.Next()
+
+		// error not handled yet is turned into a response by the error handler, use its status
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+		}
+
+		// middleware registered with app.Use report "/" as route
+		route := c.Route().Path
+		if route == "/" && c.Path() != "/" {
+			route = unmatchedRoute
+		}
+
+		metrics.ObserveHTTPRequest(c.Method(), route, status, time.Since(start))
+
+		return err
+	}
+}
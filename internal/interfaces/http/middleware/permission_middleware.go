@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequirePermission only allow session that has the permission, permissions are loaded by IsAuth.
+// The session is already authenticated here, a missing permission is a 403.
+func RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(dto.UserSession)
+
+		if !user.HasPermission(permission) {
+			return helper.RespondError(c, fiber.StatusForbidden, "Forbidden: missing permission "+permission)
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/policy"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/authz"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAccessStatus(t *testing.T) {
+	signer, err := tokensigner.New(tokensigner.ValidationConfig{Issuer: "test", Audience: "test"},
+		tokensigner.Key{Id: "hs", Algorithm: tokensigner.AlgorithmHS256, Secret: []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	engine := authz.NewEngine()
+	policy.RegisterAll(engine)
+	policyMiddleware := NewPolicyMiddleware(engine)
+	authMiddleware := NewAuthMiddleware(nil, nil, nil, signer)
+
+	// stand in for IsAuth, the session come from the X-Test-Permission header
+	session := func(c *fiber.Ctx) error {
+		user := dto.UserSession{Id: 1, Role: entity.RoleUser}
+		if permission := c.Get("X-Test-Permission"); permission != "" {
+			user.Permissions = []string{permission}
+		}
+		c.Locals("user", user)
+		return c.Next()
+	}
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+
+	app := fiber.New()
+	app.Post("/roles", session, RequirePermission(entity.PermissionRolesWrite), ok)
+	app.Delete("/users/:id", session, policyMiddleware.Authorize(policy.ActionUserDelete, UserResource), ok)
+	app.Get("/me", authMiddleware.IsAuth, ok)
+
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		permission    string
+		authorization string
+		want          int
+	}{
+		{name: "permission granted", method: fiber.MethodPost, path: "/roles", permission: entity.PermissionRolesWrite, want: fiber.StatusOK},
+		{name: "permission missing", method: fiber.MethodPost, path: "/roles", want: fiber.StatusForbidden},
+		{name: "policy allow", method: fiber.MethodDelete, path: "/users/2", permission: entity.PermissionUsersDelete, want: fiber.StatusOK},
+		{name: "policy deny", method: fiber.MethodDelete, path: "/users/2", want: fiber.StatusForbidden},
+		{name: "policy bad resource", method: fiber.MethodDelete, path: "/users/x", permission: entity.PermissionUsersDelete, want: fiber.StatusBadRequest},
+		{name: "no credentials", method: fiber.MethodGet, path: "/me", want: fiber.StatusUnauthorized},
+		{name: "not a bearer token", method: fiber.MethodGet, path: "/me", authorization: "Basic dXNlcjpwYXNz", want: fiber.StatusUnauthorized},
+		{name: "invalid token", method: fiber.MethodGet, path: "/me", authorization: "Bearer not.a.token", want: fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.permission != "" {
+				req.Header.Set("X-Test-Permission", tt.permission)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
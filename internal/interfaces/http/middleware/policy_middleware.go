@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"gofiber-cleanarch-test/internal/domain/policy"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/authz"
+	"gofiber-cleanarch-test/pkg/helper"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ResourceResolver build the resource of the request, usually from the route params
+type ResourceResolver func(c *fiber.Ctx) (authz.Resource, error)
+
+type PolicyMiddleware struct {
+	engine *authz.Engine
+}
+
+func NewPolicyMiddleware(engine *authz.Engine) *PolicyMiddleware {
+	return &PolicyMiddleware{
+		engine: engine,
+	}
+}
+
+// Authorize ask the policy engine if the session user can do the action on the resolved resource
+func (m *PolicyMiddleware) Authorize(action string, resolve ResourceResolver) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user := c.Locals("user").(dto.UserSession)
+
+		resource, err := resolve(c)
+		if err != nil {
+			return helper.RespondError(c, fiber.StatusBadRequest, err.Error())
+		}
+
+		decision := m.engine.Authorize(c.UserContext(), SubjectFromSession(user), action, resource)
+		if !decision.Allowed {
+			return helper.RespondError(c, fiber.StatusForbidden, "Forbidden: "+decision.Reason)
+		}
+
+		return c.Next()
+	}
+}
+
+// UserResource resolve user from :id param, route without :id target the users collection
+func UserResource(c *fiber.Ctx) (authz.Resource, error) {
+	if c.Params("id") == "" {
+		return authz.Resource{Type: policy.ResourceUser}, nil
+	}
+
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return authz.Resource{}, fiber.NewError(fiber.StatusBadRequest, "Invalid user id")
+	}
+
+	return policy.NewUserResource(id), nil
+}
+
+func SubjectFromSession(user dto.UserSession) authz.Subject {
+	return authz.Subject{
+		Id:          user.Id,
+		Role:        user.Role,
+		Permissions: user.Permissions,
+	}
+}
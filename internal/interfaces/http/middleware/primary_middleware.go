@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"gofiber-cleanarch-test/pkg/dbcluster"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// primaryCookie hold the end (unix ms) of the time a client read from the primary after a write,
+// followed by its signature
+const primaryCookie = "db_primary_until"
+
+// PinPrimaryForWrites route every transaction of unsafe requests (POST, PATCH, DELETE, ...) to the
+// primary, so the checks done before a write, like loading the user in IsAuth, never read stale
+// replica data. GET, HEAD and OPTIONS can read from replicas.
+//
+// After an unsafe request the client keep reading from the primary for stickiness with a short
+// lived cookie, so a GET sent right after a write see it even when the replica lag. The cookie is
+// signed with secret, a client can not forge one to pin its reads to the primary, and one ending
+// further than stickiness is ignored. An empty secret use a random key, the cookie then only work
+// on the instance which set it. 0 disable the cookie, e.g. without replicas.
+func PinPrimaryForWrites(stickiness time.Duration, secret []byte) fiber.Handler {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		switch c.Method() {
+		case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions:
+			if stickiness > 0 && pinnedAfterWrite(c, stickiness, secret) {
+				c.SetUserContext(dbcluster.PinPrimary(c.UserContext()))
+			}
+		default:
+			c.SetUserContext(dbcluster.PinPrimary(c.UserContext()))
+			if stickiness > 0 {
+				until := strconv.FormatInt(time.Now().Add(stickiness).UnixMilli(), 10)
+				c.Cookie(&fiber.Cookie{
+					Name:     primaryCookie,
+					Value:    until + "." + signPrimaryCookie(until, secret),
+					Path:     "/",
+					MaxAge:   ceilSeconds(stickiness),
+					Secure:   c.Protocol() == "https",
+					HTTPOnly: true,
+					SameSite: fiber.CookieSameSiteLaxMode,
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
+
+func pinnedAfterWrite(c *fiber.Ctx, stickiness time.Duration, secret []byte) bool {
+	value, signature, ok := strings.Cut(c.Cookies(primaryCookie), ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(signPrimaryCookie(value, secret))) {
+		return false
+	}
+
+	until, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	now := time.Now()
+	end := time.UnixMilli(until)
+
+	return now.Before(end) && !end.After(now.Add(stickiness))
+}
+
+func signPrimaryCookie(value string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"gofiber-cleanarch-test/pkg/dbcluster"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestPinPrimaryForWrites(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	app := fiber.New()
+	app.Use(PinPrimaryForWrites(5*time.Second, secret))
+	app.All("/", func(c *fiber.Ctx) error {
+		return c.SendString(strconv.FormatBool(dbcluster.IsPrimaryPinned(c.UserContext())))
+	})
+
+	request := func(method, cookie string) (pinned string, setCookie string) {
+		t.Helper()
+
+		req := httptest.NewRequest(method, "/", nil)
+		if cookie != "" {
+			req.Header.Set("Cookie", primaryCookie+"="+cookie)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		body := make([]byte, 5)
+		n, _ := resp.Body.Read(body)
+		for _, c := range resp.Cookies() {
+			if c.Name == primaryCookie {
+				setCookie = c.Value
+			}
+		}
+
+		return string(body[:n]), setCookie
+	}
+
+	pinned, cookie := request(fiber.MethodPost, "")
+	if pinned != "true" || cookie == "" {
+		t.Fatalf("write pinned = %s, cookie = %q, want pinned with a cookie", pinned, cookie)
+	}
+
+	sign := func(until time.Time) string {
+		value := strconv.FormatInt(until.UnixMilli(), 10)
+		return value + "." + signPrimaryCookie(value, secret)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		cookie string
+		want   string
+	}{
+		{name: "cookie of the write", cookie: cookie, want: "true"},
+		{name: "no cookie", want: "false"},
+		{name: "unsigned", cookie: strconv.FormatInt(now.Add(time.Second).UnixMilli(), 10), want: "false"},
+		{name: "forged signature", cookie: strconv.FormatInt(now.Add(time.Second).UnixMilli(), 10) + ".AAAA", want: "false"},
+		{name: "signed with another secret", cookie: func() string {
+			value := strconv.FormatInt(now.Add(time.Second).UnixMilli(), 10)
+			return value + "." + signPrimaryCookie(value, []byte("another"))
+		}(), want: "false"},
+		{name: "expired", cookie: sign(now.Add(-time.Second)), want: "false"},
+		{name: "longer than the stickiness", cookie: sign(now.Add(time.Hour)), want: "false"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if pinned, _ := request(fiber.MethodGet, tt.cookie); pinned != tt.want {
+				t.Errorf("read pinned = %s, want %s", pinned, tt.want)
+			}
+		})
+	}
+}
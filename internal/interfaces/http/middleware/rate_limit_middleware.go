@@ -0,0 +1,139 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/ratelimit"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"log/slog"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// what requests of a rate limit policy are counted by
+const (
+	RateLimitByIP     = "ip"
+	RateLimitByUser   = "user"
+	RateLimitByAPIKey = "api_key"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// RateLimitRule apply a policy to every path starting with Prefix
+type RateLimitRule struct {
+	Prefix string
+	Policy ratelimit.Policy
+	// By is ip, user (subject of a valid bearer token) or api_key (X-API-Key header found in
+	// RateLimitConfig.APIKeys). Anonymous requests fall back to the ip, and so do unknown api keys: a
+	// random key per request must not get a fresh counter.
+	By string
+}
+
+type RateLimitConfig struct {
+	Limiter *ratelimit.Limiter
+	// Rules are matched by the longest prefix, requests matching no rule are not limited
+	Rules []RateLimitRule
+	// TokenSigner verify bearer tokens of the user key, the rest of IsAuth (revocation, ...) is
+	// skipped so a revoked but well signed token still count for its user
+	TokenSigner *tokensigner.Signer
+	// APIKeys are the hex SHA-256 of the accepted api keys, the keys themselves are not configured
+	APIKeys []string
+	// LimitReached is called for every rejected request, e.g. to count them
+	LimitReached func(c *fiber.Ctx, policy string)
+	Logger       *slog.Logger
+}
+
+// RateLimit count every request against the policy of its route group and answer 429 over the
+// limit. Responses carry the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+// (IETF draft), rejected ones also Retry-After.
+func RateLimit(cfg RateLimitConfig) fiber.Handler {
+	rules := append([]RateLimitRule(nil), cfg.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].Prefix) > len(rules[j].Prefix)
+	})
+	apiKeys := make(map[string]bool, len(cfg.APIKeys))
+	for _, hash := range cfg.APIKeys {
+		apiKeys[strings.ToLower(hash)] = true
+	}
+
+	return func(c *fiber.Ctx) error {
+		// routing is case insensitive, so is the matching
+		rule, ok := matchRateLimitRule(rules, strings.ToLower(c.Path()))
+		if !ok {
+			return c.Next()
+		}
+
+		result, err := cfg.Limiter.Allow(c.UserContext(), rule.Policy, rateLimitKey(c, rule.By, cfg.TokenSigner, apiKeys))
+		if err != nil {
+			// an unavailable store must not take the api down with it
+			cfg.Logger.ErrorContext(c.UserContext(), "rate limit store failed, request allowed", "policy", rule.Policy.Name, "error", err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		c.Set("RateLimit-Policy", strconv.Itoa(rule.Policy.Limit)+";w="+strconv.Itoa(ceilSeconds(rule.Policy.Window)))
+
+		if !result.Allowed {
+			if cfg.LimitReached != nil {
+				cfg.LimitReached(c, rule.Policy.Name)
+			}
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			return helper.RespondError(c, fiber.StatusTooManyRequests, "Too many requests, please try again later.")
+		}
+
+		return c.Next()
+	}
+}
+
+func matchRateLimitRule(rules []RateLimitRule, path string) (RateLimitRule, bool) {
+	for _, rule := range rules {
+		if !strings.HasPrefix(path, rule.Prefix) {
+			continue
+		}
+		// /api/v1/login match /api/v1/login/2fa but not /api/v1/loginx
+		rest := path[len(rule.Prefix):]
+		if rest == "" || strings.HasSuffix(rule.Prefix, "/") || rest[0] == '/' {
+			return rule, true
+		}
+	}
+
+	return RateLimitRule{}, false
+}
+
+func rateLimitKey(c *fiber.Ctx, by string, signer *tokensigner.Signer, apiKeys map[string]bool) string {
+	if by == RateLimitByAPIKey {
+		if key := c.Get(apiKeyHeader); key != "" {
+			sum := sha256.Sum256([]byte(key))
+			if hash := hex.EncodeToString(sum[:]); apiKeys[hash] {
+				return "api_key:" + hash
+			}
+		}
+
+		return "ip:" + c.IP()
+	}
+
+	if by == RateLimitByUser {
+		if token, found := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer "); found && token != "" && signer != nil {
+			claims := new(dto.TokenClaims)
+			if _, err := signer.Parse(token, claims); err == nil && claims.Subject != "" {
+				return "user:" + claims.Subject
+			}
+		}
+	}
+
+	return "ip:" + c.IP()
+}
+
+// ceilSeconds round up so clients never retry too early
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
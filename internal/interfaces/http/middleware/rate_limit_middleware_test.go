@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/ratelimit"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/valyala/fasthttp"
+)
+
+func TestMatchRateLimitRule(t *testing.T) {
+	rules := []RateLimitRule{
+		{Prefix: "/api/v1/login", Policy: ratelimit.Policy{Name: "login"}},
+		{Prefix: "/api", Policy: ratelimit.Policy{Name: "api"}},
+		{Prefix: "/", Policy: ratelimit.Policy{Name: "default"}},
+	}
+
+	tests := []struct {
+		path   string
+		want   string
+		wantOk bool
+	}{
+		{path: "/api/v1/login", want: "login", wantOk: true},
+		{path: "/api/v1/login/2fa", want: "login", wantOk: true},
+		{path: "/api/v1/loginx", want: "api", wantOk: true},
+		{path: "/api/v1/users", want: "api", wantOk: true},
+		{path: "/api", want: "api", wantOk: true},
+		{path: "/apix", want: "default", wantOk: true},
+		{path: "/healthz", want: "default", wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rule, ok := matchRateLimitRule(rules, tt.path)
+			if ok != tt.wantOk || rule.Policy.Name != tt.want {
+				t.Errorf("matchRateLimitRule(%q) = %q, %v, want %q, %v", tt.path, rule.Policy.Name, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+
+	if _, ok := matchRateLimitRule(rules[:2], "/healthz"); ok {
+		t.Error("path outside every prefix should not match")
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	signer, err := tokensigner.New(tokensigner.ValidationConfig{Issuer: "test", Audience: "test"},
+		tokensigner.Key{Id: "hs", Algorithm: tokensigner.AlgorithmHS256, Secret: []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := dto.TokenClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Subject:   "7",
+		Issuer:    "test",
+		Audience:  jwt.ClaimStrings{"test"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	token, err := signer.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256([]byte("known-key"))
+	knownHash := hex.EncodeToString(sum[:])
+	apiKeys := map[string]bool{knownHash: true}
+
+	tests := []struct {
+		name    string
+		by      string
+		headers map[string]string
+		want    string
+	}{
+		{name: "ip", by: RateLimitByIP, headers: map[string]string{"Authorization": "Bearer " + token}, want: "ip:10.0.0.1"},
+		{name: "user with valid token", by: RateLimitByUser, headers: map[string]string{"Authorization": "Bearer " + token}, want: "user:7"},
+		{name: "user with invalid token", by: RateLimitByUser, headers: map[string]string{"Authorization": "Bearer " + token + "x"}, want: "ip:10.0.0.1"},
+		{name: "user anonymous", by: RateLimitByUser, want: "ip:10.0.0.1"},
+		{name: "known api key", by: RateLimitByAPIKey, headers: map[string]string{apiKeyHeader: "known-key"}, want: "api_key:" + knownHash},
+		{name: "unknown api key", by: RateLimitByAPIKey, headers: map[string]string{apiKeyHeader: "random-key"}, want: "ip:10.0.0.1"},
+		{name: "unknown api key with token", by: RateLimitByAPIKey, headers: map[string]string{apiKeyHeader: "random-key", "Authorization": "Bearer " + token}, want: "ip:10.0.0.1"},
+		{name: "api key missing", by: RateLimitByAPIKey, want: "ip:10.0.0.1"},
+	}
+
+	app := fiber.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fctx := &fasthttp.RequestCtx{}
+			var req fasthttp.Request
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			fctx.Init(&req, &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 1234}, nil)
+
+			c := app.AcquireCtx(fctx)
+			defer app.ReleaseCtx(c)
+
+			if got := rateLimitKey(c, tt.by, signer, apiKeys); got != tt.want {
+				t.Errorf("rateLimitKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
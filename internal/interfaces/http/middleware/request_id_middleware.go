@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"gofiber-cleanarch-test/pkg/logging"
+	"regexp"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// incoming id is only reused when it is short and safe to put in logs and headers
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID propagate X-Request-ID or generate one, it is set on the response header and in the
+// user context so every log line and error response of the request carry it
+func RequestID() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID.MatchString(id) {
+			id = uuid.NewString()
+		}
+
+		c.Set(fiber.HeaderXRequestID, id)
+		c.SetUserContext(logging.WithRequestID(c.UserContext(), id))
+
+		return c.Next()
+	}
+}
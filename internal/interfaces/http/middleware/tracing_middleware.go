@@ -0,0 +1,81 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "gofiber-cleanarch-test/internal/interfaces/http"
+
+// Tracing start a server span per request, continuing the trace of an incoming traceparent header.
+// The span is stored in the user context, so handlers must pass c.UserContext() to services.
+func Tracing() fiber.Handler {
+	tracer := otel.Tracer(tracerName)
+
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestHeaderCarrier{&c.Request().Header})
+
+		ctx, span := tracer.Start(ctx, "HTTP "+c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Method()),
+				semconv.URLPath(c.Path()),
+				semconv.ClientAddress(c.IP()),
+				semconv.UserAgentOriginal(c.Get(fiber.HeaderUserAgent)),
+			),
+		)
+		defer span.End()
+
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			if e, ok := err.(*fiber.Error); ok {
+				status = e.Code
+			}
+			span.RecordError(err)
+		}
+
+		// route template is only known after routing
+		route := c.Route().Path
+		if route == "/" && c.Path() != "/" {
+			route = unmatchedRoute
+		}
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fasthttp.StatusMessage(status))
+		}
+
+		return err
+	}
+}
+
+// requestHeaderCarrier adapt fasthttp request headers to the otel propagation carrier
+type requestHeaderCarrier struct {
+	header *fasthttp.RequestHeader
+}
+
+func (h requestHeaderCarrier) Get(key string) string {
+	return string(h.header.Peek(key))
+}
+
+func (h requestHeaderCarrier) Set(key string, value string) {
+	h.header.Set(key, value)
+}
+
+func (h requestHeaderCarrier) Keys() []string {
+	keys := make([]string, 0, h.header.Len())
+	h.header.VisitAll(func(key, value []byte) {
+		keys = append(keys, string(key))
+	})
+
+	return keys
+}
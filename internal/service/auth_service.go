@@ -3,37 +3,133 @@ package service
 import (
 	"context"
 	"database/sql"
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/internal/domain/entity"
 	"gofiber-cleanarch-test/internal/domain/repository"
 	"gofiber-cleanarch-test/internal/interfaces/http/dto"
 	"gofiber-cleanarch-test/pkg/helper"
-	"os"
+	"gofiber-cleanarch-test/pkg/password"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"log/slog"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 type AuthService interface {
+	// LoginUser return the tokens, or a two factor challenge when the user enabled it
 	LoginUser(ctx context.Context, req *dto.LoginInput) (dto.LoginResponse, error)
+	// LoginTwoFactor exchange the challenge of LoginUser and a code for the tokens
+	LoginTwoFactor(ctx context.Context, req *dto.LoginTwoFactorInput) (dto.LoginResponse, error)
+	RefreshToken(ctx context.Context, req *dto.RefreshTokenInput) (dto.LoginResponse, error)
+	Logout(ctx context.Context, session dto.UserSession, req *dto.LogoutInput) error
+}
+
+// AuthMetrics count auth events, implemented by the metrics package
+type AuthMetrics interface {
+	LoginSucceeded()
+	LoginFailed(reason string)
 }
 
 type AuthServiceImpl struct {
-	UserRepository repository.UserRepository
-	DB             *sql.DB
+	UserRepository         repository.UserRepository
+	RefreshTokenRepository repository.RefreshTokenRepository
+	TokenRevocationStore   repository.TokenRevocationStore
+	TokenSigner            *tokensigner.Signer
+	TwoFactorService       TwoFactorService
+	LoginGuard             *LoginGuard
+	PasswordHasher         password.Hasher
+	Metrics                AuthMetrics
+	DB                     helper.TxBeginner
+	Logger                 *slog.Logger
+	AccessTokenTTL         time.Duration
+	RefreshTokenTTL        time.Duration
+	ChallengeTTL           time.Duration
 }
 
-func NewAuthService(userRepository repository.UserRepository, db *sql.DB) AuthService {
+func NewAuthService(userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository, tokenRevocationStore repository.TokenRevocationStore, tokenSigner *tokensigner.Signer, twoFactorService TwoFactorService, loginGuard *LoginGuard, passwordHasher password.Hasher, metrics AuthMetrics, cfg config.JWTConfig, twoFactorCfg config.TwoFactorConfig, db helper.TxBeginner, logger *slog.Logger) AuthService {
 	return &AuthServiceImpl{
-		UserRepository: userRepository,
-		DB:             db,
+		UserRepository:         userRepository,
+		RefreshTokenRepository: refreshTokenRepository,
+		TokenRevocationStore:   tokenRevocationStore,
+		TokenSigner:            tokenSigner,
+		TwoFactorService:       twoFactorService,
+		LoginGuard:             loginGuard,
+		PasswordHasher:         passwordHasher,
+		Metrics:                metrics,
+		DB:                     db,
+		Logger:                 logger,
+		AccessTokenTTL:         cfg.AccessTokenTTL,
+		RefreshTokenTTL:        cfg.RefreshTokenTTL,
+		ChallengeTTL:           twoFactorCfg.ChallengeTTL,
 	}
 }
 
 func (s *AuthServiceImpl) LoginUser(ctx context.Context, req *dto.LoginInput) (dto.LoginResponse, error) {
-	res, err := helper.WithTransaction(ctx, s.DB, func(tx *sql.Tx) (interface{}, error) {
+	// counted as failed until the password is verified, parallel guesses can not pass the lockout
+	attempt, err := s.LoginGuard.Begin(ctx, req.Username, req.IP)
+	if err != nil {
+		s.observeLogin(err)
+		return dto.LoginResponse{}, err
+	}
+
+	res, user, err := s.login(ctx, req)
+	// the login is only complete once the challenge is answered, a correct password alone does not
+	// reset the failures or the code could be guessed forever
+	if err != nil || !res.TwoFactorRequired {
+		s.observeLogin(err)
+	}
+	switch {
+	case err == helper.NewErrorAuthLoginUnauthorized():
+		s.LoginGuard.Fail(ctx, attempt)
+	case err == nil && !res.TwoFactorRequired:
+		s.LoginGuard.Succeed(ctx, attempt)
+	default:
+		s.LoginGuard.Abort(ctx, attempt)
+	}
+
+	// the password was correct, that is enough to upgrade its hash even before the second factor
+	if err == nil && s.PasswordHasher.NeedsRehash(user.Password) {
+		s.rehashPassword(ctx, user, req.Password)
+	}
+
+	return res, err
+}
+
+// login verify the password and issue the tokens, or the challenge when two factor is enabled.
+// The slow hash compare must not hold a pooled connection, so the user is read first and the
+// password checked outside of any transaction.
+func (s *AuthServiceImpl) login(ctx context.Context, req *dto.LoginInput) (dto.LoginResponse, entity.User, error) {
+	user, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (entity.User, error) {
+		return s.UserRepository.FindByUsername(ctx, tx, req.Username)
+	}, helper.ReadOnly())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return dto.LoginResponse{}, user, helper.NewErrorAuthLoginUnauthorized()
+		}
+
+		return dto.LoginResponse{}, user, err
+	}
+
+	// check password
+	if err = comparePassword(ctx, s.PasswordHasher, user.Password, req.Password); err != nil {
+		return dto.LoginResponse{}, user, helper.NewErrorAuthLoginUnauthorized()
+	}
+
+	// with two factor enabled the tokens are only issued by LoginTwoFactor
+	enabled, err := s.TwoFactorService.Enabled(ctx, user.Id)
+	if err != nil {
+		return dto.LoginResponse{}, user, err
+	}
+	if enabled {
+		res, err := s.issueChallenge(user)
+		return res, user, err
+	}
 
-		// check user username
-		user, err := s.UserRepository.FindByUsername(ctx, tx, req.Username)
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.LoginResponse, error) {
+		// the replica can lag, the password verified must still be the current one
+		current, err := s.UserRepository.FindByID(ctx, tx, user.Id)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return dto.LoginResponse{}, helper.NewErrorAuthLoginUnauthorized()
@@ -41,25 +137,360 @@ func (s *AuthServiceImpl) LoginUser(ctx context.Context, req *dto.LoginInput) (d
 
 			return dto.LoginResponse{}, err
 		}
-
-		// check password
-		if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		if current.Password != user.Password {
 			return dto.LoginResponse{}, helper.NewErrorAuthLoginUnauthorized()
 		}
 
-		// create token
-		sign := jwt.New(jwt.SigningMethodHS256)
-		claims := sign.Claims.(jwt.MapClaims)
-		claims["id"] = user.Id
-		claims["exp"] = time.Now().Add(time.Hour * 8).Unix()
+		// new login always start a new refresh token family
+		familyId, err := helper.GenerateRandomToken(16)
+		if err != nil {
+			return dto.LoginResponse{}, err
+		}
+
+		res, err := s.issueTokens(ctx, tx, current, familyId, false)
+		res.TwoFactorEnrollmentRequired = current.RoleRequire2FA
+
+		return res, err
+	})
+
+	return res, user, err
+}
+
+// rehashPassword replace the hash of the user with one of the configured algorithm and parameters,
+// a failure only keep the old hash so the login is not refused for it
+func (s *AuthServiceImpl) rehashPassword(ctx context.Context, user entity.User, plain string) {
+	oldHash := user.Password
+
+	hashed, err := hashPassword(ctx, s.PasswordHasher, plain)
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "password rehash failed", "user_id", user.Id, "error", err)
+		return
+	}
+	user.Password = hashed
+
+	err = helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		return s.UserRepository.UpdatePasswordHash(ctx, tx, &user, oldHash)
+	})
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "password rehash failed", "user_id", user.Id, "error", err)
+		return
+	}
+
+	s.Logger.InfoContext(ctx, "password rehashed", "user_id", user.Id, "from", password.Algorithm(oldHash), "to", password.Algorithm(hashed))
+}
+
+func (s *AuthServiceImpl) LoginTwoFactor(ctx context.Context, req *dto.LoginTwoFactorInput) (dto.LoginResponse, error) {
+	claims := new(dto.TokenClaims)
+	if _, err := s.TokenSigner.Parse(req.ChallengeToken, claims); err != nil || claims.Purpose != dto.TokenPurposeTwoFactor || claims.ID == "" || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		s.observeLogin(helper.NewErrorTwoFactorChallengeInvalid())
+		return dto.LoginResponse{}, helper.NewErrorTwoFactorChallengeInvalid()
+	}
+
+	userId, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		s.observeLogin(helper.NewErrorTwoFactorChallengeInvalid())
+		return dto.LoginResponse{}, helper.NewErrorTwoFactorChallengeInvalid()
+	}
+
+	// a challenge is revoked once answered
+	revoked, err := s.TokenRevocationStore.IsTokenRevoked(ctx, claims.ID)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	if revoked {
+		s.observeLogin(helper.NewErrorTwoFactorChallengeInvalid())
+		return dto.LoginResponse{}, helper.NewErrorTwoFactorChallengeInvalid()
+	}
+
+	// sessions revoked after the password was entered (password change, ...) end the challenge too
+	revokedBefore, err := s.TokenRevocationStore.UserTokensRevokedBefore(ctx, userId)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	if !claims.IssuedAt.After(revokedBefore) {
+		s.observeLogin(helper.NewErrorTwoFactorChallengeInvalid())
+		return dto.LoginResponse{}, helper.NewErrorTwoFactorChallengeInvalid()
+	}
+
+	// wrong codes count as failed logins of the account and of the challenge, reserved before the
+	// code is verified
+	user, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (entity.User, error) {
+		return s.UserRepository.FindByID(ctx, tx, userId)
+	}, helper.ReadOnly())
+	if err != nil {
+		if err == sql.ErrNoRows {
+			err = helper.NewErrorTwoFactorChallengeInvalid()
+		}
+		s.observeLogin(err)
+		return dto.LoginResponse{}, err
+	}
+
+	attempt, err := s.LoginGuard.BeginChallenge(ctx, user.Username, req.IP, claims.ID)
+	if err == helper.NewErrorTwoFactorChallengeInvalid() {
+		s.revokeChallenge(ctx, claims, userId)
+	}
+	if err != nil {
+		s.observeLogin(err)
+		return dto.LoginResponse{}, err
+	}
+
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.LoginResponse, error) {
+		user, err := s.UserRepository.FindByID(ctx, tx, userId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return dto.LoginResponse{}, helper.NewErrorTwoFactorChallengeInvalid()
+			}
+
+			return dto.LoginResponse{}, err
+		}
+
+		if err = s.TwoFactorService.Verify(ctx, user.Id, req.Code); err != nil {
+			return dto.LoginResponse{}, err
+		}
+
+		familyId, err := helper.GenerateRandomToken(16)
+		if err != nil {
+			return dto.LoginResponse{}, err
+		}
+
+		return s.issueTokens(ctx, tx, user, familyId, true)
+	})
+	s.observeLogin(err)
+	switch {
+	case err == helper.NewErrorTwoFactorCodeInvalid():
+		s.LoginGuard.Fail(ctx, attempt)
+	case err == nil:
+		s.LoginGuard.Succeed(ctx, attempt)
+	default:
+		s.LoginGuard.Abort(ctx, attempt)
+	}
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	// codes are single use already, revoking only stop the challenge from being answered again
+	s.revokeChallenge(ctx, claims, userId)
+
+	return res, nil
+}
+
+// revokeChallenge only log a failure, the challenge still expire and its attempts stay counted
+func (s *AuthServiceImpl) revokeChallenge(ctx context.Context, claims *dto.TokenClaims, userId int) {
+	if err := s.TokenRevocationStore.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		s.Logger.WarnContext(ctx, "two factor challenge revocation failed", "user_id", userId, "error", err)
+	}
+}
+
+func (s *AuthServiceImpl) RefreshToken(ctx context.Context, req *dto.RefreshTokenInput) (dto.LoginResponse, error) {
+	// reuse detection must commit the family revocation, so it is reported outside the transaction
+	reused := false
+	var reusedUserId int
+
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.LoginResponse, error) {
+		// reset, the transaction can run again after a serialization failure
+		reused = false
+
+		token, err := s.RefreshTokenRepository.FindByTokenHash(ctx, tx, helper.HashToken(req.RefreshToken))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return dto.LoginResponse{}, helper.NewErrorAuthRefreshTokenInvalid()
+			}
+
+			return dto.LoginResponse{}, err
+		}
+
+		if token.RevokedAt != nil || time.Now().After(token.ExpiresAt) {
+			return dto.LoginResponse{}, helper.NewErrorAuthRefreshTokenInvalid()
+		}
 
-		token, err := sign.SignedString([]byte(os.Getenv("JWT_SECRET")))
+		// token already rotated before, someone is replaying it so revoke the whole family
+		if token.RotatedAt != nil {
+			if err = s.RefreshTokenRepository.RevokeFamily(ctx, tx, token.FamilyId); err != nil {
+				return dto.LoginResponse{}, err
+			}
+
+			reused = true
+			reusedUserId = token.UserId
+			return dto.LoginResponse{}, nil
+		}
+
+		user, err := s.UserRepository.FindByID(ctx, tx, token.UserId)
 		if err != nil {
+			if err == sql.ErrNoRows {
+				return dto.LoginResponse{}, helper.NewErrorAuthRefreshTokenInvalid()
+			}
+
 			return dto.LoginResponse{}, err
 		}
 
-		return dto.LoginResponse{Token: token}, nil
+		if err = s.RefreshTokenRepository.MarkRotated(ctx, tx, &token); err != nil {
+			return dto.LoginResponse{}, err
+		}
+
+		return s.issueTokens(ctx, tx, user, token.FamilyId, token.MFA)
 	})
+	if err == nil && reused {
+		s.Logger.WarnContext(ctx, "refresh token reuse detected, token family revoked", "user_id", reusedUserId)
+		return dto.LoginResponse{}, helper.NewErrorAuthRefreshTokenReused()
+	}
+
+	return res, err
+}
+
+func (s *AuthServiceImpl) Logout(ctx context.Context, session dto.UserSession, req *dto.LogoutInput) error {
+	// revoke current access token until it expired by itself
+	if err := s.TokenRevocationStore.RevokeToken(ctx, session.TokenId, session.TokenExpiresAt); err != nil {
+		return err
+	}
+
+	if req.RefreshToken == "" {
+		return nil
+	}
+
+	// also end the refresh token family so the session can not be continued
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		token, err := s.RefreshTokenRepository.FindByTokenHash(ctx, tx, helper.HashToken(req.RefreshToken))
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return nil
+			}
+
+			return err
+		}
+
+		// ignore refresh token from other user
+		if token.UserId != session.Id {
+			return nil
+		}
+
+		if err = s.RefreshTokenRepository.RevokeFamily(ctx, tx, token.FamilyId); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func (s *AuthServiceImpl) observeLogin(err error) {
+	if s.Metrics == nil {
+		return
+	}
+
+	if err == nil {
+		s.Metrics.LoginSucceeded()
+		return
+	}
+
+	if e, ok := err.(helper.AppError); ok {
+		switch e {
+		case helper.NewErrorAuthLoginUnauthorized():
+			s.Metrics.LoginFailed("invalid_credentials")
+			return
+		case helper.NewErrorTwoFactorCodeInvalid():
+			s.Metrics.LoginFailed("invalid_2fa_code")
+			return
+		case helper.NewErrorTwoFactorChallengeInvalid():
+			s.Metrics.LoginFailed("invalid_2fa_challenge")
+			return
+		case helper.NewErrorAuthLoginLocked():
+			s.Metrics.LoginFailed("locked")
+			return
+		}
+	}
+
+	s.Metrics.LoginFailed("error")
+}
+
+// issueTokens create access token and save a new refresh token in the given family, mfa tell
+// whether the login of the family completed a second factor
+func (s *AuthServiceImpl) issueTokens(ctx context.Context, tx *sql.Tx, user entity.User, familyId string, mfa bool) (dto.LoginResponse, error) {
+	now := time.Now()
+	accessExp := now.Add(s.AccessTokenTTL)
+	refreshExp := now.Add(s.RefreshTokenTTL)
+
+	// create token
+	claims, err := s.newClaims(user, now, accessExp)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	claims.MFA = mfa
+
+	token, err := s.TokenSigner.Sign(claims)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	// create refresh token, only the hash is stored
+	refreshToken, err := helper.GenerateRandomToken(32)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	if _, err = s.RefreshTokenRepository.Save(ctx, tx, &entity.RefreshToken{
+		UserId:    user.Id,
+		FamilyId:  familyId,
+		TokenHash: helper.HashToken(refreshToken),
+		ExpiresAt: refreshExp,
+		MFA:       mfa,
+	}); err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	return dto.LoginResponse{
+		Token:                   token,
+		TokenType:               "Bearer",
+		ExpiredTime:             accessExp.UTC().Format(time.RFC3339),
+		RefreshToken:            refreshToken,
+		RefreshTokenExpiredTime: refreshExp.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// issueChallenge create the short lived token a login answer with LoginTwoFactor, IsAuth refuse it
+// because of its purpose
+func (s *AuthServiceImpl) issueChallenge(user entity.User) (dto.LoginResponse, error) {
+	now := time.Now()
+	exp := now.Add(s.ChallengeTTL)
+
+	claims, err := s.newClaims(user, now, exp)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	claims.Purpose = dto.TokenPurposeTwoFactor
+
+	token, err := s.TokenSigner.Sign(claims)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	return dto.LoginResponse{
+		TwoFactorRequired:    true,
+		ChallengeToken:       token,
+		ChallengeExpiredTime: exp.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+func (s *AuthServiceImpl) newClaims(user entity.User, now time.Time, exp time.Time) (dto.TokenClaims, error) {
+	jti, err := helper.GenerateRandomToken(16)
+	if err != nil {
+		return dto.TokenClaims{}, err
+	}
+
+	claims := dto.TokenClaims{
+		Role: user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.Itoa(user.Id),
+			Issuer:    s.TokenSigner.Issuer(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+	if aud := s.TokenSigner.Audience(); aud != "" {
+		claims.Audience = jwt.ClaimStrings{aud}
+	}
 
-	return res.(dto.LoginResponse), err
+	return claims, nil
 }
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/tokensigner"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func newTestAuthService(t *testing.T) (*AuthServiceImpl, *fakeDB, *fakeRefreshTokenRepository) {
+	t.Helper()
+
+	signer, err := tokensigner.New(tokensigner.ValidationConfig{Issuer: "test", Audience: "test"},
+		tokensigner.Key{Id: "hs", Algorithm: tokensigner.AlgorithmHS256, Secret: []byte("0123456789abcdef0123456789abcdef")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := newFakeDB(t)
+	refreshTokens := newFakeRefreshTokenRepository(db)
+
+	s := &AuthServiceImpl{
+		UserRepository: &fakeUserRepository{users: map[int]entity.User{
+			1: {Id: 1, Username: "alice", Role: entity.RoleUser},
+		}},
+		RefreshTokenRepository: refreshTokens,
+		TokenSigner:            signer,
+		DB:                     db,
+		Logger:                 slog.New(slog.NewTextHandler(io.Discard, nil)),
+		AccessTokenTTL:         time.Minute,
+		RefreshTokenTTL:        time.Hour,
+	}
+
+	return s, db, refreshTokens
+}
+
+// seedRefreshToken store a committed token of user 1 for the plain value
+func seedRefreshToken(refreshTokens *fakeRefreshTokenRepository, plain string, familyId string, expiresAt time.Time) {
+	refreshTokens.tokens[helper.HashToken(plain)] = entity.RefreshToken{
+		UserId:    1,
+		FamilyId:  familyId,
+		TokenHash: helper.HashToken(plain),
+		ExpiresAt: expiresAt,
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	s, _, refreshTokens := newTestAuthService(t)
+	ctx := context.Background()
+	seedRefreshToken(refreshTokens, "first", "family", time.Now().Add(time.Hour))
+
+	res, err := s.RefreshToken(ctx, &dto.RefreshTokenInput{RefreshToken: "first"})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if res.Token == "" || res.RefreshToken == "" || res.RefreshToken == "first" {
+		t.Fatalf("refresh did not issue new tokens: %+v", res)
+	}
+
+	rotated := refreshTokens.tokens[helper.HashToken("first")]
+	if rotated.RotatedAt == nil {
+		t.Error("used token not marked rotated")
+	}
+	next, ok := refreshTokens.tokens[helper.HashToken(res.RefreshToken)]
+	if !ok || next.FamilyId != "family" || next.UserId != 1 {
+		t.Errorf("new token = %+v, want one of the same family", next)
+	}
+
+	// the new token is valid until the old one is replayed
+	if _, err = s.RefreshToken(ctx, &dto.RefreshTokenInput{RefreshToken: res.RefreshToken}); err != nil {
+		t.Fatalf("refresh with the new token: %v", err)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	s, db, refreshTokens := newTestAuthService(t)
+	ctx := context.Background()
+	seedRefreshToken(refreshTokens, "first", "family", time.Now().Add(time.Hour))
+	seedRefreshToken(refreshTokens, "other", "other family", time.Now().Add(time.Hour))
+
+	res, err := s.RefreshToken(ctx, &dto.RefreshTokenInput{RefreshToken: "first"})
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	commits := db.commits
+	if _, err = s.RefreshToken(ctx, &dto.RefreshTokenInput{RefreshToken: "first"}); err != helper.NewErrorAuthRefreshTokenReused() {
+		t.Fatalf("reuse err = %v, want %v", err, helper.NewErrorAuthRefreshTokenReused())
+	}
+
+	// the error is returned after the commit, the revocation must not be rolled back with it
+	if db.commits != commits+1 {
+		t.Errorf("reuse did not commit its transaction")
+	}
+	for _, plain := range []string{"first", res.RefreshToken} {
+		if refreshTokens.tokens[helper.HashToken(plain)].RevokedAt == nil {
+			t.Errorf("token %q of the family not revoked", plain)
+		}
+	}
+	if refreshTokens.tokens[helper.HashToken("other")].RevokedAt != nil {
+		t.Error("token of another family revoked")
+	}
+
+	if _, err = s.RefreshToken(ctx, &dto.RefreshTokenInput{RefreshToken: res.RefreshToken}); err != helper.NewErrorAuthRefreshTokenInvalid() {
+		t.Errorf("refresh with the revoked token err = %v, want %v", err, helper.NewErrorAuthRefreshTokenInvalid())
+	}
+}
+
+func TestRefreshTokenInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "expired", token: "expired"},
+		{name: "unknown", token: "unknown"},
+		{name: "user deleted", token: "orphan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, refreshTokens := newTestAuthService(t)
+			seedRefreshToken(refreshTokens, "expired", "family", time.Now().Add(-time.Second))
+			seedRefreshToken(refreshTokens, "orphan", "orphan family", time.Now().Add(time.Hour))
+			orphan := refreshTokens.tokens[helper.HashToken("orphan")]
+			orphan.UserId = 2
+			refreshTokens.tokens[helper.HashToken("orphan")] = orphan
+
+			_, err := s.RefreshToken(context.Background(), &dto.RefreshTokenInput{RefreshToken: tt.token})
+			if err != helper.NewErrorAuthRefreshTokenInvalid() {
+				t.Fatalf("err = %v, want %v", err, helper.NewErrorAuthRefreshTokenInvalid())
+			}
+
+			for hash, token := range refreshTokens.tokens {
+				if token.RotatedAt != nil || token.RevokedAt != nil {
+					t.Errorf("token %s changed by a refused refresh", hash)
+				}
+			}
+			if len(refreshTokens.tokens) != 2 {
+				t.Errorf("refused refresh issued a token")
+			}
+		})
+	}
+}
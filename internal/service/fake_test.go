@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDB is a database whose transactions only apply the changes staged by the fake repositories
+// once committed, so a test see what a rolled back transaction lost
+type fakeDB struct {
+	*sql.DB
+
+	mu        sync.Mutex
+	pending   []func()
+	commits   int
+	rollbacks int
+}
+
+var fakeDriverCount int
+
+func newFakeDB(t *testing.T) *fakeDB {
+	db := &fakeDB{}
+
+	fakeDriverCount++
+	name := fmt.Sprintf("service-fake-%d", fakeDriverCount)
+	sql.Register(name, &fakeDriver{db})
+
+	sqlDB, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	db.DB = sqlDB
+
+	return db
+}
+
+// stage keep a change until the transaction commit
+func (db *fakeDB) stage(apply func()) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.pending = append(db.pending, apply)
+}
+
+func (db *fakeDB) end(commit bool) {
+	db.mu.Lock()
+	pending := db.pending
+	db.pending = nil
+	if commit {
+		db.commits++
+	} else {
+		db.rollbacks++
+	}
+	db.mu.Unlock()
+
+	if commit {
+		for _, apply := range pending {
+			apply()
+		}
+	}
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{d.db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return &fakeTx{c.db}, nil
+}
+
+// ExecContext accept the savepoints of nested transactions
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (t *fakeTx) Commit() error {
+	t.db.end(true)
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.db.end(false)
+	return nil
+}
+
+// fakeUserRepository only implement the lookups, the other methods panic
+type fakeUserRepository struct {
+	repository.UserRepository
+	users map[int]entity.User
+}
+
+func (r *fakeUserRepository) FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return entity.User{}, sql.ErrNoRows
+	}
+
+	return user, nil
+}
+
+type fakeRefreshTokenRepository struct {
+	db     *fakeDB
+	tokens map[string]entity.RefreshToken
+	nextId int
+}
+
+func newFakeRefreshTokenRepository(db *fakeDB) *fakeRefreshTokenRepository {
+	return &fakeRefreshTokenRepository{db: db, tokens: make(map[string]entity.RefreshToken)}
+}
+
+func (r *fakeRefreshTokenRepository) Save(ctx context.Context, tx *sql.Tx, token *entity.RefreshToken) (entity.RefreshToken, error) {
+	r.nextId++
+	saved := *token
+	saved.Id = r.nextId
+	saved.CreatedAt = time.Now()
+
+	r.db.stage(func() { r.tokens[saved.TokenHash] = saved })
+	return saved, nil
+}
+
+func (r *fakeRefreshTokenRepository) FindByTokenHash(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.RefreshToken, error) {
+	token, ok := r.tokens[tokenHash]
+	if !ok {
+		return entity.RefreshToken{}, sql.ErrNoRows
+	}
+
+	return token, nil
+}
+
+func (r *fakeRefreshTokenRepository) MarkRotated(ctx context.Context, tx *sql.Tx, token *entity.RefreshToken) error {
+	hash := token.TokenHash
+	r.db.stage(func() {
+		current := r.tokens[hash]
+		now := time.Now()
+		current.RotatedAt = &now
+		r.tokens[hash] = current
+	})
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeFamily(ctx context.Context, tx *sql.Tx, familyId string) error {
+	r.revokeWhere(func(token entity.RefreshToken) bool { return token.FamilyId == familyId })
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) RevokeByUser(ctx context.Context, tx *sql.Tx, userId int) error {
+	r.revokeWhere(func(token entity.RefreshToken) bool { return token.UserId == userId })
+	return nil
+}
+
+func (r *fakeRefreshTokenRepository) revokeWhere(match func(token entity.RefreshToken) bool) {
+	r.db.stage(func() {
+		now := time.Now()
+		for hash, token := range r.tokens {
+			if match(token) && token.RevokedAt == nil {
+				token.RevokedAt = &now
+				r.tokens[hash] = token
+			}
+		}
+	})
+}
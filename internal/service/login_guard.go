@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/lockout"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// LoginGuard refuse the logins of accounts and ips with too many recent failures. Every attempt is
+// counted as failed by Begin before the password is checked, so a refused login tell nothing about
+// the password and a burst of parallel guesses is refused like sequential ones.
+type LoginGuard struct {
+	Store   repository.LoginAttemptStore
+	Account lockout.Policy
+	IP      lockout.Policy
+	// Challenge bound the codes tried with one two factor challenge
+	Challenge lockout.Policy
+	Window    time.Duration
+	// Retention must cover the longest lockout, older keys are pruned every PruneInterval
+	Retention     time.Duration
+	PruneInterval time.Duration
+	Logger        *slog.Logger
+
+	now  func() time.Time
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewLoginGuard(store repository.LoginAttemptStore, cfg config.LoginLockoutConfig, twoFactorCfg config.TwoFactorConfig, logger *slog.Logger) *LoginGuard {
+	return &LoginGuard{
+		Store:         store,
+		Account:       cfg.AccountPolicy(),
+		IP:            cfg.IPPolicy(),
+		Challenge:     twoFactorCfg.ChallengePolicy(),
+		Window:        cfg.Window,
+		Retention:     cfg.Retention(),
+		PruneInterval: cfg.PruneInterval,
+		Logger:        logger,
+		now:           time.Now,
+	}
+}
+
+// accountLoginKey count the failures by username, unknown usernames included so a lockout does not
+// tell whether the account exist
+func accountLoginKey(username string) string {
+	return "account:" + username
+}
+
+func ipLoginKey(ip string) string {
+	return "ip:" + ip
+}
+
+func challengeLoginKey(jti string) string {
+	return "challenge:" + jti
+}
+
+type guardedKey struct {
+	key       string
+	policy    lockout.Policy
+	account   bool
+	challenge bool
+}
+
+// keys skip the ip when unknown (login outside of a request)
+func (g *LoginGuard) keys(username, ip string) []guardedKey {
+	keys := []guardedKey{{key: accountLoginKey(username), policy: g.Account, account: true}}
+	if ip != "" {
+		keys = append(keys, guardedKey{key: ipLoginKey(ip), policy: g.IP})
+	}
+
+	return keys
+}
+
+// LoginAttempt is a login counted as failed by Begin, ended by Fail, Succeed or Abort
+type LoginAttempt struct {
+	keys     []guardedKey
+	failures []entity.LoginFailure
+}
+
+// Begin reserve the attempt on the account and the ip, NewErrorAuthLoginLocked while one of them
+// must wait
+func (g *LoginGuard) Begin(ctx context.Context, username, ip string) (*LoginAttempt, error) {
+	return g.begin(ctx, g.keys(username, ip))
+}
+
+// BeginChallenge reserve a code attempt on the account, the ip and the challenge, a challenge that
+// used its attempts return NewErrorTwoFactorChallengeInvalid and must be revoked
+func (g *LoginGuard) BeginChallenge(ctx context.Context, username, ip, jti string) (*LoginAttempt, error) {
+	keys := append(g.keys(username, ip), guardedKey{key: challengeLoginKey(jti), policy: g.Challenge, challenge: true})
+
+	return g.begin(ctx, keys)
+}
+
+func (g *LoginGuard) begin(ctx context.Context, keys []guardedKey) (*LoginAttempt, error) {
+	now := g.now()
+	attempt := &LoginAttempt{}
+
+	for _, k := range keys {
+		failure, allowed, err := g.Store.Reserve(ctx, k.key, now, g.Window, k.policy)
+		if err == nil && !allowed {
+			g.Logger.DebugContext(ctx, "login refused, too many failures", "key", k.key, "failures", failure.Count)
+			if k.challenge {
+				err = helper.NewErrorTwoFactorChallengeInvalid()
+			} else {
+				err = helper.NewErrorAuthLoginLocked()
+			}
+		}
+		if err != nil {
+			// the keys already reserved are given back, the attempt is not made
+			g.Abort(ctx, attempt)
+			return nil, err
+		}
+
+		attempt.keys = append(attempt.keys, k)
+		attempt.failures = append(attempt.failures, failure)
+	}
+
+	return attempt, nil
+}
+
+// Fail keep the attempt counted, it is logged once when it reach the lockout threshold
+func (g *LoginGuard) Fail(ctx context.Context, attempt *LoginAttempt) {
+	if attempt == nil {
+		return
+	}
+
+	for i, k := range attempt.keys {
+		failure := attempt.failures[i]
+		if k.policy.Locked(failure.Count) && !k.policy.Locked(failure.Count-1) {
+			g.Logger.WarnContext(ctx, "login locked after too many failures", "key", k.key, "failures", failure.Count, "until", k.policy.BlockedUntil(failure.Count, failure.LastFailureAt))
+		}
+	}
+}
+
+// Succeed forget the failures of the account, not of the ip: a valid account must not let an ip
+// keep guessing the others, only this attempt is given back to the ip (and to the challenge)
+func (g *LoginGuard) Succeed(ctx context.Context, attempt *LoginAttempt) {
+	if attempt == nil {
+		return
+	}
+
+	for _, k := range attempt.keys {
+		var err error
+		if k.account {
+			err = g.Store.Reset(ctx, k.key)
+		} else {
+			err = g.Store.Release(ctx, k.key)
+		}
+		if err != nil {
+			g.Logger.ErrorContext(ctx, "login failures not reset", "key", k.key, "error", err)
+		}
+	}
+}
+
+// Abort give the attempt back, for a login that neither failed nor succeeded (correct password
+// waiting for its second factor, error of the database, ...). An error of the store is only logged
+// so the caller still answer.
+func (g *LoginGuard) Abort(ctx context.Context, attempt *LoginAttempt) {
+	if attempt == nil {
+		return
+	}
+
+	for _, k := range attempt.keys {
+		if err := g.Store.Release(ctx, k.key); err != nil {
+			g.Logger.ErrorContext(ctx, "login attempt not released", "key", k.key, "error", err)
+		}
+	}
+}
+
+// Unlock forget the failures of the account
+func (g *LoginGuard) Unlock(ctx context.Context, username string) error {
+	return g.Store.Reset(ctx, accountLoginKey(username))
+}
+
+// Start prune the expired keys in background every PruneInterval until Stop
+func (g *LoginGuard) Start(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stop != nil {
+		return errors.New("login guard: already started")
+	}
+
+	g.stop = make(chan struct{})
+	g.done = make(chan struct{})
+	go g.loop(g.stop, g.done)
+
+	return nil
+}
+
+func (g *LoginGuard) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(g.PruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.prune(context.Background())
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (g *LoginGuard) prune(ctx context.Context) {
+	pruned, err := g.Store.Prune(ctx, g.now().Add(-g.Retention))
+	if err != nil {
+		g.Logger.ErrorContext(ctx, "login failures not pruned", "error", err)
+		return
+	}
+
+	g.Logger.DebugContext(ctx, "login failures pruned", "keys", pruned)
+}
+
+// Stop end the background pruning
+func (g *LoginGuard) Stop(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.stop == nil {
+		return nil
+	}
+
+	close(g.stop)
+	select {
+	case <-g.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	g.stop = nil
+
+	return nil
+}
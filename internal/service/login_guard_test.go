@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"gofiber-cleanarch-test/internal/infrastructure/repository"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/lockout"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestLoginGuard() (*LoginGuard, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)}
+
+	return &LoginGuard{
+		Store:     repository.NewMemoryLoginAttemptStore(),
+		Account:   lockout.Policy{DelayAfter: 2, Delay: time.Second, MaxDelay: 2 * time.Second, Threshold: 4, Duration: time.Minute},
+		IP:        lockout.Policy{Threshold: 3, Duration: time.Minute},
+		Challenge: lockout.Policy{Threshold: 2, Duration: 5 * time.Minute},
+		Window:    10 * time.Minute,
+		Retention: 10 * time.Minute,
+		Logger:    slog.New(slog.NewTextHandler(io.Discard, nil)),
+		now:       clock.Now,
+	}, clock
+}
+
+// failLogin begin an attempt and count it as failed, it return the error of Begin
+func failLogin(g *LoginGuard, username, ip string) error {
+	ctx := context.Background()
+
+	attempt, err := g.Begin(ctx, username, ip)
+	if err != nil {
+		return err
+	}
+	g.Fail(ctx, attempt)
+
+	return nil
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	g, clock := newTestLoginGuard()
+
+	steps := []struct {
+		advance time.Duration
+		wantErr error
+	}{
+		{0, nil},
+		{0, nil},
+		// 2 failures wait a second
+		{0, helper.NewErrorAuthLoginLocked()},
+		{time.Second, nil},
+		// 3 failures wait the max delay
+		{time.Second, helper.NewErrorAuthLoginLocked()},
+		{time.Second, nil},
+		// 4 failures lock for a minute
+		{30 * time.Second, helper.NewErrorAuthLoginLocked()},
+		{30 * time.Second, nil},
+	}
+
+	for i, step := range steps {
+		clock.Advance(step.advance)
+		if err := failLogin(g, "alice", ""); err != step.wantErr {
+			t.Fatalf("step %d: err = %v, want %v", i+1, err, step.wantErr)
+		}
+	}
+}
+
+func TestLoginGuardWindow(t *testing.T) {
+	g, clock := newTestLoginGuard()
+
+	for i := 0; i < 2; i++ {
+		if err := failLogin(g, "alice", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// failures older than the window are forgotten, no delay left
+	clock.Advance(g.Window + time.Second)
+	for i := 0; i < 2; i++ {
+		if err := failLogin(g, "alice", ""); err != nil {
+			t.Fatalf("attempt %d after the window: %v", i+1, err)
+		}
+	}
+}
+
+func TestLoginGuardSucceed(t *testing.T) {
+	g, _ := newTestLoginGuard()
+	ctx := context.Background()
+
+	if err := failLogin(g, "alice", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := failLogin(g, "bob", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	attempt, err := g.Begin(ctx, "alice", "10.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Succeed(ctx, attempt)
+
+	// the account start again without delay
+	for i := 0; i < 2; i++ {
+		if err = failLogin(g, "alice", ""); err != nil {
+			t.Fatalf("alice attempt %d after success: %v", i+1, err)
+		}
+	}
+
+	// the ip only got back the successful attempt, its earlier failure still count
+	if err = failLogin(g, "carol", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = failLogin(g, "dave", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = failLogin(g, "erin", "10.0.0.1"); err != helper.NewErrorAuthLoginLocked() {
+		t.Errorf("ip after 3 failures err = %v, want locked", err)
+	}
+}
+
+func TestLoginGuardAbort(t *testing.T) {
+	g, _ := newTestLoginGuard()
+	ctx := context.Background()
+
+	// attempts given back never lock
+	for i := 0; i < 10; i++ {
+		attempt, err := g.Begin(ctx, "alice", "10.0.0.1")
+		if err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+		g.Abort(ctx, attempt)
+	}
+
+	// attempts in progress count like failures, parallel guesses are refused too
+	for i := 0; i < 2; i++ {
+		if _, err := g.Begin(ctx, "alice", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := g.Begin(ctx, "alice", ""); err != helper.NewErrorAuthLoginLocked() {
+		t.Errorf("third parallel attempt err = %v, want locked", err)
+	}
+}
+
+func TestLoginGuardAbortRefusedKey(t *testing.T) {
+	g, _ := newTestLoginGuard()
+
+	for _, username := range []string{"alice", "bob", "carol"} {
+		if err := failLogin(g, username, "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the ip is locked, the account reserved before it must be given back
+	for i := 0; i < 3; i++ {
+		if err := failLogin(g, "dave", "10.0.0.1"); err != helper.NewErrorAuthLoginLocked() {
+			t.Fatalf("locked ip err = %v", err)
+		}
+	}
+	if err := failLogin(g, "dave", ""); err != nil {
+		t.Errorf("account counted by attempts refused for its ip: %v", err)
+	}
+}
+
+func TestLoginGuardUnlock(t *testing.T) {
+	g, _ := newTestLoginGuard()
+	g.Account.DelayAfter = 0
+
+	for i := 0; i < 4; i++ {
+		if err := failLogin(g, "alice", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := failLogin(g, "alice", ""); err != helper.NewErrorAuthLoginLocked() {
+		t.Fatalf("err = %v, want locked", err)
+	}
+
+	if err := g.Unlock(context.Background(), "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := failLogin(g, "alice", ""); err != nil {
+		t.Errorf("after unlock: %v", err)
+	}
+}
+
+func TestLoginGuardChallenge(t *testing.T) {
+	g, _ := newTestLoginGuard()
+	g.Account = lockout.Policy{}
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		attempt, err := g.BeginChallenge(ctx, "alice", "", "jti")
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.Fail(ctx, attempt)
+	}
+
+	if _, err := g.BeginChallenge(ctx, "alice", "", "jti"); err != helper.NewErrorTwoFactorChallengeInvalid() {
+		t.Errorf("err = %v, want challenge invalid", err)
+	}
+	if _, err := g.BeginChallenge(ctx, "alice", "", "other"); err != nil {
+		t.Errorf("other challenge: %v", err)
+	}
+}
+
+func TestLoginGuardPrune(t *testing.T) {
+	g, clock := newTestLoginGuard()
+	ctx := context.Background()
+
+	if err := failLogin(g, "alice", "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(g.Retention / 2)
+	if err := failLogin(g, "bob", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the keys whose last failure is older than the retention are pruned
+	clock.Advance(g.Retention/2 + time.Second)
+	g.prune(ctx)
+
+	pruned, err := g.Store.Prune(ctx, clock.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Errorf("keys left after prune = %d, want 1", pruned)
+	}
+}
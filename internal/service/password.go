@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"gofiber-cleanarch-test/pkg/password"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// hashPassword is traced on its own, hashing is usually the slowest part of a request
+func hashPassword(ctx context.Context, hasher password.Hasher, plain string) (string, error) {
+	_, span := otel.Tracer(tracerName).Start(ctx, "password.Hash")
+	defer span.End()
+
+	hashed, err := hasher.Hash(plain)
+	if err != nil {
+		return "", err
+	}
+
+	span.SetAttributes(attribute.String("password.algorithm", password.Algorithm(hashed)))
+
+	return hashed, nil
+}
+
+func comparePassword(ctx context.Context, hasher password.Hasher, hashedPassword string, plain string) error {
+	_, span := otel.Tracer(tracerName).Start(ctx, "password.Verify",
+		trace.WithAttributes(attribute.String("password.algorithm", password.Algorithm(hashedPassword))))
+	defer span.End()
+
+	return hasher.Verify(hashedPassword, plain)
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/notify"
+	"gofiber-cleanarch-test/pkg/password"
+	"gofiber-cleanarch-test/pkg/workqueue"
+	"log/slog"
+	"net/url"
+	"time"
+)
+
+// passwordResetTokenSize random bytes, the token is only stored hashed
+const passwordResetTokenSize = 32
+
+type PasswordResetService interface {
+	// Forgot send a reset token to the user in background, it succeed the same way whether or not
+	// the username exist
+	Forgot(ctx context.Context, req *dto.PasswordForgotInput) error
+	// Reset set the new password with an unused token, revoke every session of the user and lift a
+	// login lockout
+	Reset(ctx context.Context, req *dto.PasswordResetInput) error
+}
+
+type PasswordResetServiceImpl struct {
+	PasswordResetRepository repository.PasswordResetRepository
+	UserRepository          repository.UserRepository
+	RefreshTokenRepository  repository.RefreshTokenRepository
+	TokenRevocationStore    repository.TokenRevocationStore
+	LoginGuard              *LoginGuard
+	PasswordHasher          password.Hasher
+	PasswordPolicy          *password.Policy
+	Notifier                notify.Notifier
+	// Queue run the token work of Forgot, it is drained on shutdown before the database is closed
+	Queue    *workqueue.Queue
+	DB       helper.TxBeginner
+	Logger   *slog.Logger
+	TokenTTL time.Duration
+	// URL of the reset page, empty send the bare token
+	URL string
+}
+
+func NewPasswordResetService(passwordResetRepository repository.PasswordResetRepository, userRepository repository.UserRepository, refreshTokenRepository repository.RefreshTokenRepository, tokenRevocationStore repository.TokenRevocationStore, loginGuard *LoginGuard, passwordHasher password.Hasher, passwordPolicy *password.Policy, notifier notify.Notifier, queue *workqueue.Queue, cfg config.PasswordResetConfig, db helper.TxBeginner, logger *slog.Logger) PasswordResetService {
+	return &PasswordResetServiceImpl{
+		PasswordResetRepository: passwordResetRepository,
+		UserRepository:          userRepository,
+		RefreshTokenRepository:  refreshTokenRepository,
+		TokenRevocationStore:    tokenRevocationStore,
+		LoginGuard:              loginGuard,
+		PasswordHasher:          passwordHasher,
+		PasswordPolicy:          passwordPolicy,
+		Notifier:                notifier,
+		Queue:                   queue,
+		DB:                      db,
+		Logger:                  logger,
+		TokenTTL:                cfg.TokenTTL,
+		URL:                     cfg.URL,
+	}
+}
+
+// Forgot answer before the username is even looked up, known and unknown usernames would otherwise
+// take a different time (token saved or nothing done) and tell whether the account exist
+func (s *PasswordResetServiceImpl) Forgot(ctx context.Context, req *dto.PasswordForgotInput) error {
+	username := req.Username
+	err := s.Queue.Submit(ctx, func(ctx context.Context) {
+		s.forgot(ctx, username)
+	})
+	if err != nil {
+		// the same for every username, the queue does not depend on it
+		s.Logger.WarnContext(ctx, "password reset request refused", "error", err)
+		return helper.NewErrorPasswordResetBusy()
+	}
+
+	return nil
+}
+
+// forgot create and send the token, the caller already answered so errors are only logged
+func (s *PasswordResetServiceImpl) forgot(ctx context.Context, username string) {
+	err := helper.RunTx(ctx, s.DB, func(txCtx context.Context, tx *sql.Tx) error {
+		user, err := s.UserRepository.FindByUsername(txCtx, tx, username)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				s.Logger.DebugContext(ctx, "password reset requested for unknown username")
+				return nil
+			}
+
+			return err
+		}
+
+		token, err := helper.GenerateRandomToken(passwordResetTokenSize)
+		if err != nil {
+			return err
+		}
+
+		resetToken := entity.PasswordResetToken{
+			UserId:    user.Id,
+			TokenHash: helper.HashToken(token),
+			ExpiresAt: time.Now().Add(s.TokenTTL),
+		}
+		if err = s.PasswordResetRepository.Save(txCtx, tx, &resetToken); err != nil {
+			return err
+		}
+
+		s.Logger.InfoContext(ctx, "password reset requested", "user_id", user.Id)
+
+		msg := s.message(user, token, resetToken.ExpiresAt)
+		helper.AfterCommit(txCtx, func() {
+			s.notify(ctx, user.Id, msg)
+		})
+
+		return nil
+	})
+	if err != nil {
+		s.Logger.ErrorContext(ctx, "password reset request failed", "error", err)
+	}
+}
+
+func (s *PasswordResetServiceImpl) Reset(ctx context.Context, req *dto.PasswordResetInput) error {
+	tokenHash := helper.HashToken(req.Token)
+
+	// check the token in a read only transaction without locking, the hash is done before the write
+	// transaction so a retried transaction does not hash again
+	user, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (entity.User, error) {
+		_, user, err := s.findToken(ctx, tx, tokenHash, s.PasswordResetRepository.FindByTokenHash)
+		return user, err
+	}, helper.ReadOnly())
+	if err != nil {
+		return err
+	}
+
+	if err = s.PasswordPolicy.Validate(req.Password, user.Username); err != nil {
+		return err
+	}
+
+	hashed, err := hashPassword(ctx, s.PasswordHasher, req.Password)
+	if err != nil {
+		return err
+	}
+
+	err = helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		// checked again under the row lock, the token may have been used meanwhile
+		token, user, err := s.findToken(ctx, tx, tokenHash, s.PasswordResetRepository.FindByTokenHashForUpdate)
+		if err != nil {
+			return err
+		}
+
+		user.Password = hashed
+		if err = s.UserRepository.ChangePassword(ctx, tx, &user); err != nil {
+			return err
+		}
+
+		if err = s.PasswordResetRepository.MarkUsed(ctx, tx, &token); err != nil {
+			return err
+		}
+
+		// whoever knew the old password must login again
+		if err = revokeUserSessions(ctx, tx, s.RefreshTokenRepository, s.TokenRevocationStore, user.Id); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.Logger.InfoContext(ctx, "user password reset with token", "user_id", user.Id)
+
+	// the account was proven by the token, failures of whoever guessed the old password do not matter anymore
+	if err = s.LoginGuard.Unlock(ctx, user.Username); err != nil {
+		s.Logger.ErrorContext(ctx, "login failures not reset", "user_id", user.Id, "error", err)
+	}
+
+	return nil
+}
+
+// findToken return a usable token and its user, find lock the token or not
+func (s *PasswordResetServiceImpl) findToken(ctx context.Context, tx *sql.Tx, tokenHash string, find func(ctx context.Context, tx *sql.Tx, tokenHash string) (entity.PasswordResetToken, error)) (entity.PasswordResetToken, entity.User, error) {
+	token, err := find(ctx, tx, tokenHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return token, entity.User{}, helper.NewErrorPasswordResetTokenInvalid()
+		}
+
+		return token, entity.User{}, err
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return token, entity.User{}, helper.NewErrorPasswordResetTokenInvalid()
+	}
+
+	// the user may have been deleted since the token was sent
+	user, err := s.UserRepository.FindByID(ctx, tx, token.UserId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return token, user, helper.NewErrorPasswordResetTokenInvalid()
+		}
+
+		return token, user, err
+	}
+
+	return token, user, nil
+}
+
+func (s *PasswordResetServiceImpl) message(user entity.User, token string, expiresAt time.Time) notify.Message {
+	data := map[string]string{
+		"token":      token,
+		"expires_at": expiresAt.UTC().Format(time.RFC3339),
+	}
+
+	// the url is validated with the config
+	link := token
+	if u, err := url.Parse(s.URL); err == nil && s.URL != "" {
+		query := u.Query()
+		query.Set("token", token)
+		u.RawQuery = query.Encode()
+
+		link = u.String()
+		data["url"] = link
+	}
+
+	return notify.Message{
+		Recipient: user.Username,
+		Subject:   "Reset your password",
+		Body:      fmt.Sprintf("Use %s to set a new password before %s. Ignore this message if you did not ask for it.", link, data["expires_at"]),
+		Data:      data,
+	}
+}
+
+func (s *PasswordResetServiceImpl) notify(ctx context.Context, userId int, msg notify.Message) {
+	if err := s.Notifier.Notify(ctx, msg); err != nil {
+		s.Logger.ErrorContext(ctx, "password reset notification failed", "user_id", userId, "error", err)
+	}
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/cache"
+	"gofiber-cleanarch-test/pkg/helper"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+type PermissionService interface {
+	FindAll(ctx context.Context) ([]dto.PermissionResponse, error)
+	FindByRoleId(ctx context.Context, roleId int) ([]dto.PermissionResponse, error)
+	SetRolePermissions(ctx context.Context, req *dto.RolePermissionsUpdate) error
+	// PermissionNamesForRole is cached, it is called on every authenticated request
+	PermissionNamesForRole(ctx context.Context, roleId int) ([]string, error)
+}
+
+type PermissionServiceImpl struct {
+	PermissionRepository repository.PermissionRepository
+	RoleRepository       repository.RoleRepository
+	DB                   helper.TxBeginner
+	Logger               *slog.Logger
+	// Cache keep the permission names of the roles, nil disable it
+	Cache cache.Cache
+	// CacheTTL bound how long other instances keep stale permissions after a change
+	CacheTTL time.Duration
+}
+
+func NewPermissionService(permissionRepository repository.PermissionRepository, roleRepository repository.RoleRepository, cache cache.Cache, cacheTTL time.Duration, db helper.TxBeginner, logger *slog.Logger) PermissionService {
+	return &PermissionServiceImpl{
+		PermissionRepository: permissionRepository,
+		RoleRepository:       roleRepository,
+		DB:                   db,
+		Logger:               logger,
+		Cache:                cache,
+		CacheTTL:             cacheTTL,
+	}
+}
+
+func (s *PermissionServiceImpl) FindAll(ctx context.Context) ([]dto.PermissionResponse, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) ([]dto.PermissionResponse, error) {
+		permissions, err := s.PermissionRepository.FindAll(ctx, tx)
+		if err != nil {
+			return []dto.PermissionResponse{}, err
+		}
+
+		return helper.ToPermissionResponses(permissions), nil
+	}, helper.ReadOnly())
+
+	return res, err
+}
+
+func (s *PermissionServiceImpl) FindByRoleId(ctx context.Context, roleId int) ([]dto.PermissionResponse, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) ([]dto.PermissionResponse, error) {
+		if _, err := s.RoleRepository.FindByID(ctx, tx, roleId); err != nil {
+			if err == sql.ErrNoRows {
+				return []dto.PermissionResponse{}, helper.NewErrorRoleNotFound()
+			}
+
+			return []dto.PermissionResponse{}, err
+		}
+
+		permissions, err := s.PermissionRepository.FindByRoleID(ctx, tx, roleId)
+		if err != nil {
+			return []dto.PermissionResponse{}, err
+		}
+
+		return helper.ToPermissionResponses(permissions), nil
+	}, helper.ReadOnly())
+
+	return res, err
+}
+
+func (s *PermissionServiceImpl) SetRolePermissions(ctx context.Context, req *dto.RolePermissionsUpdate) error {
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := s.RoleRepository.FindByID(ctx, tx, req.RoleId); err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorRoleNotFound()
+			}
+
+			return err
+		}
+
+		var permissionIds []int
+		if len(req.Permissions) > 0 {
+			permissions, err := s.PermissionRepository.FindByNames(ctx, tx, req.Permissions)
+			if err != nil {
+				return err
+			}
+
+			// every requested permission must exist
+			found := make(map[string]bool)
+			for _, permission := range permissions {
+				found[permission.Name] = true
+				permissionIds = append(permissionIds, permission.Id)
+			}
+
+			for _, name := range req.Permissions {
+				if !found[name] {
+					return helper.NewErrorPermissionInvalid()
+				}
+			}
+		}
+
+		if err := s.PermissionRepository.ReplaceRolePermissions(ctx, tx, req.RoleId, permissionIds); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.Cache != nil {
+		if err = s.Cache.Delete(ctx, rolePermissionsCacheKey(req.RoleId)); err != nil {
+			s.Logger.ErrorContext(ctx, "role permissions cache invalidation failed, entry stay stale until it expire", "role_id", req.RoleId, "error", err)
+		}
+	}
+
+	s.Logger.InfoContext(ctx, "role permissions changed", "role_id", req.RoleId, "permissions", req.Permissions)
+
+	return nil
+}
+
+func (s *PermissionServiceImpl) PermissionNamesForRole(ctx context.Context, roleId int) ([]string, error) {
+	key := rolePermissionsCacheKey(roleId)
+
+	// cache failure only cost a database read
+	if s.Cache != nil {
+		value, found, err := s.Cache.Get(ctx, key)
+		if err != nil {
+			s.Logger.WarnContext(ctx, "role permissions cache get failed", "error", err)
+		}
+		if found {
+			var names []string
+			if err = json.Unmarshal(value, &names); err == nil {
+				return names, nil
+			}
+			s.Logger.WarnContext(ctx, "role permissions cache entry invalid", "error", err)
+		}
+	}
+
+	names, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) ([]string, error) {
+		permissions, err := s.PermissionRepository.FindByRoleID(ctx, tx, roleId)
+		if err != nil {
+			return []string{}, err
+		}
+
+		names := []string{}
+		for _, permission := range permissions {
+			names = append(names, permission.Name)
+		}
+
+		return names, nil
+	}, helper.ReadOnly())
+	if err != nil {
+		return nil, err
+	}
+
+	if s.Cache != nil {
+		value, err := json.Marshal(names)
+		if err == nil {
+			err = s.Cache.Set(ctx, key, value, s.CacheTTL)
+		}
+		if err != nil {
+			s.Logger.WarnContext(ctx, "role permissions cache set failed", "error", err)
+		}
+	}
+
+	return names, nil
+}
+
+func rolePermissionsCacheKey(roleId int) string {
+	return "role_permissions:" + strconv.Itoa(roleId)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/cache"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakePermissionRepository struct {
+	permissions []entity.Permission
+	// roles hold the permission ids of each role
+	roles map[int][]int
+	reads int
+}
+
+func (r *fakePermissionRepository) FindAll(ctx context.Context, tx *sql.Tx) ([]entity.Permission, error) {
+	return r.permissions, nil
+}
+
+func (r *fakePermissionRepository) FindByNames(ctx context.Context, tx *sql.Tx, names []string) ([]entity.Permission, error) {
+	var found []entity.Permission
+	for _, permission := range r.permissions {
+		for _, name := range names {
+			if permission.Name == name {
+				found = append(found, permission)
+			}
+		}
+	}
+
+	return found, nil
+}
+
+func (r *fakePermissionRepository) FindByRoleID(ctx context.Context, tx *sql.Tx, roleId int) ([]entity.Permission, error) {
+	r.reads++
+
+	var found []entity.Permission
+	for _, id := range r.roles[roleId] {
+		found = append(found, r.permissions[id-1])
+	}
+
+	return found, nil
+}
+
+func (r *fakePermissionRepository) ReplaceRolePermissions(ctx context.Context, tx *sql.Tx, roleId int, permissionIds []int) error {
+	r.roles[roleId] = permissionIds
+	return nil
+}
+
+type fakeRoleRepository struct {
+	repository.RoleRepository
+}
+
+func (r *fakeRoleRepository) FindByID(ctx context.Context, tx *sql.Tx, id int) (entity.Role, error) {
+	return entity.Role{Id: id}, nil
+}
+
+// failingCache fail every call, like an unreachable redis
+type failingCache struct{}
+
+func (failingCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, errors.New("unreachable")
+}
+
+func (failingCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return errors.New("unreachable")
+}
+
+func (failingCache) Delete(ctx context.Context, keys ...string) error {
+	return errors.New("unreachable")
+}
+
+func TestPermissionNamesForRole(t *testing.T) {
+	tests := []struct {
+		name  string
+		cache cache.Cache
+		// wantReads after two lookups, a permission change and a third lookup
+		wantReads []int
+	}{
+		{name: "cached", cache: cache.NewMemory(10), wantReads: []int{1, 1, 2}},
+		{name: "without cache", cache: nil, wantReads: []int{1, 2, 3}},
+		{name: "cache failing", cache: failingCache{}, wantReads: []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			permissions := &fakePermissionRepository{
+				permissions: []entity.Permission{{Id: 1, Name: entity.PermissionUsersRead}, {Id: 2, Name: entity.PermissionUsersWrite}},
+				roles:       map[int][]int{entity.RoleAdmin: {1}},
+			}
+			s := NewPermissionService(permissions, &fakeRoleRepository{}, tt.cache, time.Minute, newFakeDB(t), slog.New(slog.NewTextHandler(io.Discard, nil)))
+			ctx := context.Background()
+
+			for i, want := range [][]string{{entity.PermissionUsersRead}, {entity.PermissionUsersRead}} {
+				names, err := s.PermissionNamesForRole(ctx, entity.RoleAdmin)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(names, want) {
+					t.Errorf("lookup %d = %v, want %v", i+1, names, want)
+				}
+				if permissions.reads != tt.wantReads[i] {
+					t.Errorf("lookup %d reads = %d, want %d", i+1, permissions.reads, tt.wantReads[i])
+				}
+			}
+
+			// a change is seen right away by this instance
+			err := s.SetRolePermissions(ctx, &dto.RolePermissionsUpdate{RoleId: entity.RoleAdmin, Permissions: []string{entity.PermissionUsersRead, entity.PermissionUsersWrite}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names, err := s.PermissionNamesForRole(ctx, entity.RoleAdmin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []string{entity.PermissionUsersRead, entity.PermissionUsersWrite}; !reflect.DeepEqual(names, want) {
+				t.Errorf("lookup after change = %v, want %v", names, want)
+			}
+			if permissions.reads != tt.wantReads[2] {
+				t.Errorf("lookup after change reads = %d, want %d", permissions.reads, tt.wantReads[2])
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+	"log/slog"
+)
+
+type RoleService interface {
+	FindAll(ctx context.Context) ([]dto.RoleResponse, error)
+	FindById(ctx context.Context, Id int) (dto.RoleResponse, error)
+	FindByName(ctx context.Context, name string) (dto.RoleResponse, error)
+	Create(ctx context.Context, req *dto.RoleCreate) (dto.RoleResponse, error)
+	Update(ctx context.Context, req *dto.RoleUpdate) error
+	Delete(ctx context.Context, Id int) error
+}
+
+type RoleServiceImpl struct {
+	RoleRepository repository.RoleRepository
+	DB             helper.TxBeginner
+	Logger         *slog.Logger
+}
+
+func NewRoleService(roleRepository repository.RoleRepository, db helper.TxBeginner, logger *slog.Logger) RoleService {
+	return &RoleServiceImpl{
+		RoleRepository: roleRepository,
+		DB:             db,
+		Logger:         logger,
+	}
+}
+
+func (s *RoleServiceImpl) FindAll(ctx context.Context) ([]dto.RoleResponse, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) ([]dto.RoleResponse, error) {
+		roles, err := s.RoleRepository.FindAll(ctx, tx)
+		if err != nil {
+			return []dto.RoleResponse{}, err
+		}
+
+		return helper.ToRoleResponses(roles), nil
+	}, helper.ReadOnly())
+
+	return res, err
+}
+
+func (s *RoleServiceImpl) FindById(ctx context.Context, Id int) (dto.RoleResponse, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.RoleResponse, error) {
+		role, err := s.RoleRepository.FindByID(ctx, tx, Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return dto.RoleResponse{}, helper.NewErrorRoleNotFound()
+			}
+
+			return dto.RoleResponse{}, err
+		}
+
+		return helper.ToRoleResponse(role), nil
+	}, helper.ReadOnly())
+
+	return res, err
+}
+
+func (s *RoleServiceImpl) FindByName(ctx context.Context, name string) (dto.RoleResponse, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.RoleResponse, error) {
+		role, err := s.RoleRepository.FindByName(ctx, tx, name)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return dto.RoleResponse{}, helper.NewErrorRoleNotFound()
+			}
+
+			return dto.RoleResponse{}, err
+		}
+
+		return helper.ToRoleResponse(role), nil
+	}, helper.ReadOnly())
+
+	return res, err
+}
+
+func (s *RoleServiceImpl) Create(ctx context.Context, req *dto.RoleCreate) (dto.RoleResponse, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.RoleResponse, error) {
+		// check role name if available
+		role_check, err := s.RoleRepository.FindByName(ctx, tx, req.Name)
+		if err != nil && err != sql.ErrNoRows {
+			return dto.RoleResponse{}, err
+		}
+
+		if role_check.Id != 0 {
+			return dto.RoleResponse{}, helper.NewErrorRoleNameExist()
+		}
+
+		role, err := s.RoleRepository.Save(ctx, tx, &entity.Role{Name: req.Name, Require2FA: req.Require2FA})
+		if err != nil {
+			return dto.RoleResponse{}, err
+		}
+
+		return helper.ToRoleResponse(role), nil
+	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "role created", "role_id", res.Id, "name", req.Name, "require_2fa", req.Require2FA)
+	}
+
+	return res, err
+}
+
+func (s *RoleServiceImpl) Update(ctx context.Context, req *dto.RoleUpdate) error {
+	// audit when the two factor requirement change, reported after commit
+	var require2FAChanged bool
+
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		// reset, the transaction can run again after a serialization failure
+		require2FAChanged = false
+
+		role, err := s.RoleRepository.FindByID(ctx, tx, req.Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorRoleNotFound()
+			}
+
+			return err
+		}
+
+		// check if name used by another role
+		role_check, err := s.RoleRepository.FindByName(ctx, tx, req.Name)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if (role_check.Id != 0) && (role_check.Id != role.Id) {
+			return helper.NewErrorRoleNameExist()
+		}
+
+		role.Name = req.Name
+		if req.Require2FA != nil && *req.Require2FA != role.Require2FA {
+			role.Require2FA = *req.Require2FA
+			require2FAChanged = true
+		}
+
+		if err = s.RoleRepository.Update(ctx, tx, &role); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err == nil && require2FAChanged {
+		s.Logger.InfoContext(ctx, "role two factor requirement changed", "role_id", req.Id, "require_2fa", *req.Require2FA)
+	}
+
+	return err
+}
+
+func (s *RoleServiceImpl) Delete(ctx context.Context, Id int) error {
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		role, err := s.RoleRepository.FindByID(ctx, tx, Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorRoleNotFound()
+			}
+
+			return err
+		}
+
+		// default roles are referenced by the authorization code
+		if role.Id == entity.RoleAdmin || role.Id == entity.RoleUser || role.Id == entity.RoleSuperAdmin {
+			return helper.NewErrorRoleDefault()
+		}
+
+		// role still referenced by users can not be deleted
+		total, err := s.RoleRepository.CountUsers(ctx, tx, role.Id)
+		if err != nil {
+			return err
+		}
+
+		if total > 0 {
+			return helper.NewErrorRoleInUse()
+		}
+
+		if err = s.RoleRepository.Delete(ctx, tx, &role); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "role deleted", "role_id", Id)
+	}
+
+	return err
+}
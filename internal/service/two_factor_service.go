@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"gofiber-cleanarch-test/internal/config"
+	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/repository"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/password"
+	"gofiber-cleanarch-test/pkg/secretbox"
+	"gofiber-cleanarch-test/pkg/totp"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeSize random bytes give 16 base32 characters
+	recoveryCodeSize = 10
+	// totpSkew accept the code of the previous and next step
+	totpSkew = 1
+)
+
+type TwoFactorService interface {
+	// Enroll start (or restart) an enrollment, it is only active after Confirm
+	Enroll(ctx context.Context, session dto.UserSession) (dto.TwoFactorEnrollResponse, error)
+	Confirm(ctx context.Context, session dto.UserSession, req *dto.TwoFactorConfirmInput) error
+	Disable(ctx context.Context, session dto.UserSession, req *dto.TwoFactorDisableInput) error
+	// Enabled report whether the user has a confirmed authenticator
+	Enabled(ctx context.Context, userId int) (bool, error)
+	// Verify accept a current authenticator code or an unused recovery code, every code is accepted
+	// only once. It join the transaction of ctx, so the caller decide what the code unlock.
+	Verify(ctx context.Context, userId int, code string) error
+}
+
+type TwoFactorServiceImpl struct {
+	TwoFactorRepository repository.TwoFactorRepository
+	UserRepository      repository.UserRepository
+	// SecretBox seal the authenticator secrets, nil store them in plain text
+	SecretBox      *secretbox.Box
+	PasswordHasher password.Hasher
+	DB             helper.TxBeginner
+	Logger         *slog.Logger
+	Issuer         string
+}
+
+func NewTwoFactorService(twoFactorRepository repository.TwoFactorRepository, userRepository repository.UserRepository, secretBox *secretbox.Box, passwordHasher password.Hasher, cfg config.TwoFactorConfig, db helper.TxBeginner, logger *slog.Logger) TwoFactorService {
+	return &TwoFactorServiceImpl{
+		TwoFactorRepository: twoFactorRepository,
+		UserRepository:      userRepository,
+		SecretBox:           secretBox,
+		PasswordHasher:      passwordHasher,
+		DB:                  db,
+		Logger:              logger,
+		Issuer:              cfg.Issuer,
+	}
+}
+
+func (s *TwoFactorServiceImpl) Enroll(ctx context.Context, session dto.UserSession) (dto.TwoFactorEnrollResponse, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	sealed, err := s.SecretBox.Seal(secret)
+	if err != nil {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = generateRecoveryCode(); err != nil {
+			return dto.TwoFactorEnrollResponse{}, err
+		}
+		hashes[i] = helper.HashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	err = helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		current, err := s.TwoFactorRepository.FindByUserIDForUpdate(ctx, tx, session.Id)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		// replacing a confirmed authenticator must go through Disable, which ask for a code
+		if err == nil && current.ConfirmedAt != nil {
+			return helper.NewErrorTwoFactorAlreadyEnabled()
+		}
+
+		if err = s.TwoFactorRepository.Save(ctx, tx, &entity.UserTOTP{UserId: session.Id, Secret: sealed}); err != nil {
+			return err
+		}
+
+		return s.TwoFactorRepository.ReplaceRecoveryCodes(ctx, tx, session.Id, hashes)
+	})
+	if err != nil {
+		return dto.TwoFactorEnrollResponse{}, err
+	}
+
+	return dto.TwoFactorEnrollResponse{
+		Secret:        secret,
+		URI:           totp.URI(s.Issuer, session.Username, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+func (s *TwoFactorServiceImpl) Confirm(ctx context.Context, session dto.UserSession, req *dto.TwoFactorConfirmInput) error {
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		enrollment, err := s.TwoFactorRepository.FindByUserIDForUpdate(ctx, tx, session.Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorTwoFactorNotEnrolled()
+			}
+
+			return err
+		}
+
+		if enrollment.ConfirmedAt != nil {
+			return helper.NewErrorTwoFactorAlreadyEnabled()
+		}
+
+		step, err := s.validateCode(enrollment, req.Code)
+		if err != nil {
+			return err
+		}
+
+		enrollment.LastUsedStep = step
+		return s.TwoFactorRepository.Confirm(ctx, tx, &enrollment)
+	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "two factor enabled", "user_id", session.Id)
+	}
+
+	return err
+}
+
+func (s *TwoFactorServiceImpl) Disable(ctx context.Context, session dto.UserSession, req *dto.TwoFactorDisableInput) error {
+	// the password is verified before the transaction, a retried transaction must not repeat the
+	// slow hash while holding its locks. A read only lookup would hit the user cache, which never
+	// contain the hash, so it use a short transaction of its own.
+	user, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (entity.User, error) {
+		return s.UserRepository.FindByID(ctx, tx, session.Id)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return helper.NewErrorUserNotFound()
+		}
+
+		return err
+	}
+
+	if user.RoleRequire2FA {
+		return helper.NewErrorTwoFactorRequiredByRole()
+	}
+
+	if err = comparePassword(ctx, s.PasswordHasher, user.Password, req.Password); err != nil {
+		return helper.NewErrorUserPasswordIncorrect()
+	}
+
+	err = helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		current, err := s.UserRepository.FindByID(ctx, tx, session.Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorUserNotFound()
+			}
+
+			return err
+		}
+
+		// changed since it was verified, the password given may not be valid anymore
+		if current.Password != user.Password {
+			return helper.NewErrorUserPasswordIncorrect()
+		}
+
+		if current.RoleRequire2FA {
+			return helper.NewErrorTwoFactorRequiredByRole()
+		}
+
+		enrollment, err := s.TwoFactorRepository.FindByUserIDForUpdate(ctx, tx, session.Id)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == sql.ErrNoRows || enrollment.ConfirmedAt == nil {
+			return helper.NewErrorTwoFactorNotEnrolled()
+		}
+
+		if err = s.Verify(ctx, session.Id, req.Code); err != nil {
+			return err
+		}
+
+		return s.TwoFactorRepository.Delete(ctx, tx, session.Id)
+	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "two factor disabled", "user_id", session.Id)
+	}
+
+	return err
+}
+
+// Enabled does not lock the enrollment, it is read on every password login
+func (s *TwoFactorServiceImpl) Enabled(ctx context.Context, userId int) (bool, error) {
+	return helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (bool, error) {
+		enrollment, err := s.TwoFactorRepository.FindByUserID(ctx, tx, userId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		return enrollment.ConfirmedAt != nil, nil
+	})
+}
+
+func (s *TwoFactorServiceImpl) Verify(ctx context.Context, userId int, code string) error {
+	usedRecoveryCode := false
+
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		// reset, the transaction can run again after a serialization failure
+		usedRecoveryCode = false
+
+		enrollment, err := s.TwoFactorRepository.FindByUserIDForUpdate(ctx, tx, userId)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorTwoFactorCodeInvalid()
+			}
+
+			return err
+		}
+
+		if enrollment.ConfirmedAt == nil {
+			return helper.NewErrorTwoFactorCodeInvalid()
+		}
+
+		code = strings.TrimSpace(code)
+		if len(code) == totp.Digits {
+			step, err := s.validateCode(enrollment, code)
+			if err != nil {
+				return err
+			}
+
+			// the row is locked, a concurrent login with the same code wait and then fail here
+			if step <= enrollment.LastUsedStep {
+				return helper.NewErrorTwoFactorCodeInvalid()
+			}
+
+			enrollment.LastUsedStep = step
+			return s.TwoFactorRepository.UpdateLastUsedStep(ctx, tx, &enrollment)
+		}
+
+		used, err := s.TwoFactorRepository.UseRecoveryCode(ctx, tx, userId, helper.HashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return err
+		}
+		if !used {
+			return helper.NewErrorTwoFactorCodeInvalid()
+		}
+
+		usedRecoveryCode = true
+		return nil
+	})
+	if err == nil && usedRecoveryCode {
+		s.Logger.InfoContext(ctx, "two factor recovery code used", "user_id", userId)
+	}
+
+	return err
+}
+
+// validateCode return the time step of a valid authenticator code
+func (s *TwoFactorServiceImpl) validateCode(enrollment entity.UserTOTP, code string) (int64, error) {
+	secret, err := s.SecretBox.Open(enrollment.Secret)
+	if err != nil {
+		return 0, err
+	}
+
+	step, ok, err := totp.Validate(secret, code, time.Now(), totpSkew)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, helper.NewErrorTwoFactorCodeInvalid()
+	}
+
+	return step, nil
+}
+
+// generateRecoveryCode return a code like abcd-efgh-ijkl-mnop
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+
+	return raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16], nil
+}
+
+// normalizeRecoveryCode accept the code typed without dashes or in upper case
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	code = strings.ReplaceAll(code, " ", "")
+
+	return code
+}
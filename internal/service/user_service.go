@@ -3,14 +3,16 @@ package service
 import (
 	"context"
 	"database/sql"
-	"errors"
-	"fmt"
+	"gofiber-cleanarch-test/internal/config"
 	"gofiber-cleanarch-test/internal/domain/entity"
+	"gofiber-cleanarch-test/internal/domain/policy"
 	"gofiber-cleanarch-test/internal/domain/repository"
 	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/authz"
 	"gofiber-cleanarch-test/pkg/helper"
-
-	"golang.org/x/crypto/bcrypt"
+	"gofiber-cleanarch-test/pkg/password"
+	"log/slog"
+	"time"
 )
 
 type UserService interface {
@@ -20,23 +22,50 @@ type UserService interface {
 	Create(ctx context.Context, req *dto.UserCreate) (dto.UserResponse, error)
 	Update(ctx context.Context, req *dto.UserUpdate) error
 	ChangePassword(ctx context.Context, req *dto.UserChangePassword) error
+	ResetPassword(ctx context.Context, req *dto.UserResetPassword) error
 	Delete(ctx context.Context, Id int) error
+	Restore(ctx context.Context, Id int) error
+	Purge(ctx context.Context, Id int) error
+	// Unlock forget the failed logins of the user, lifting a login lockout
+	Unlock(ctx context.Context, Id int) error
 }
 
 type UserServiceImpl struct {
-	UserRepository repository.UserRepository
-	DB             *sql.DB
+	UserRepository         repository.UserRepository
+	RoleRepository         repository.RoleRepository
+	RefreshTokenRepository repository.RefreshTokenRepository
+	// PasswordResetRepository drop the pending reset links once the password is set
+	PasswordResetRepository repository.PasswordResetRepository
+	TokenRevocationStore    repository.TokenRevocationStore
+	LoginGuard              *LoginGuard
+	PasswordHasher          password.Hasher
+	PasswordPolicy          *password.Policy
+	Authorizer              *authz.Engine
+	DB                      helper.TxBeginner
+	Logger                  *slog.Logger
+	// ReuseDeletedUsername allow new user to take username of soft deleted user, otherwise the username stay reserved
+	ReuseDeletedUsername bool
 }
 
-func NewUserService(userRepository repository.UserRepository, db *sql.DB) UserService {
+func NewUserService(userRepository repository.UserRepository, roleRepository repository.RoleRepository, refreshTokenRepository repository.RefreshTokenRepository, passwordResetRepository repository.PasswordResetRepository, tokenRevocationStore repository.TokenRevocationStore, loginGuard *LoginGuard, passwordHasher password.Hasher, passwordPolicy *password.Policy, authorizer *authz.Engine, cfg config.UserConfig, db helper.TxBeginner, logger *slog.Logger) UserService {
 	return &UserServiceImpl{
-		UserRepository: userRepository,
-		DB:             db,
+		UserRepository:          userRepository,
+		RoleRepository:          roleRepository,
+		RefreshTokenRepository:  refreshTokenRepository,
+		PasswordResetRepository: passwordResetRepository,
+		TokenRevocationStore:    tokenRevocationStore,
+		LoginGuard:              loginGuard,
+		PasswordHasher:          passwordHasher,
+		PasswordPolicy:          passwordPolicy,
+		Authorizer:              authorizer,
+		DB:                      db,
+		Logger:                  logger,
+		ReuseDeletedUsername:    cfg.ReuseDeletedUsername,
 	}
 }
 
 func (s *UserServiceImpl) FindAllWithPagination(ctx context.Context, limit int, offset int) (dto.PaginationData, error) {
-	res, err := helper.WithTransaction(ctx, s.DB, func(tx *sql.Tx) (interface{}, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.PaginationData, error) {
 		users, err := s.UserRepository.FindAllWithPagination(ctx, tx, limit, offset)
 		if err != nil {
 			return dto.PaginationData{}, err
@@ -51,13 +80,13 @@ func (s *UserServiceImpl) FindAllWithPagination(ctx context.Context, limit int,
 			TotalData: totalData,
 			Data:      helper.ToUserResponses(users),
 		}, nil
-	})
+	}, helper.ReadOnly())
 
-	return res.(dto.PaginationData), err
+	return res, err
 }
 
 func (s *UserServiceImpl) FindById(ctx context.Context, Id int) (dto.UserResponse, error) {
-	res, err := helper.WithTransaction(ctx, s.DB, func(tx *sql.Tx) (interface{}, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.UserResponse, error) {
 		user, err := s.UserRepository.FindByID(ctx, tx, Id)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -68,13 +97,13 @@ func (s *UserServiceImpl) FindById(ctx context.Context, Id int) (dto.UserRespons
 		}
 
 		return helper.ToUserResponse(user), nil
-	})
+	}, helper.ReadOnly())
 
-	return res.(dto.UserResponse), err
+	return res, err
 }
 
 func (s *UserServiceImpl) FindByUsername(ctx context.Context, username string) (dto.UserResponse, error) {
-	res, err := helper.WithTransaction(ctx, s.DB, func(tx *sql.Tx) (interface{}, error) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.UserResponse, error) {
 		user, err := s.UserRepository.FindByUsername(ctx, tx, username)
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -85,158 +114,431 @@ func (s *UserServiceImpl) FindByUsername(ctx context.Context, username string) (
 		}
 
 		return helper.ToUserResponse(user), nil
-	})
+	}, helper.ReadOnly())
 
-	return res.(dto.UserResponse), err
+	return res, err
 }
 
 func (s *UserServiceImpl) Create(ctx context.Context, req *dto.UserCreate) (dto.UserResponse, error) {
-	tx, err := s.DB.Begin()
-	if err != nil {
+	if err := s.PasswordPolicy.Validate(req.Password, req.Username); err != nil {
 		return dto.UserResponse{}, err
 	}
-	defer func() {
-		if err != nil {
-			if rbErr := tx.Rollback(); rbErr != nil {
-				err = fmt.Errorf("rollback error: %v (original error: %w)", rbErr, err)
-			}
-		}
-	}()
 
-	user := entity.User{
-		Username: req.Username,
-		Password: req.Password,
-		Role:     req.Role,
+	// hash before the transaction, hashing is slow and a retried transaction must not hash again
+	hashed, err := hashPassword(ctx, s.PasswordHasher, req.Password)
+	if err != nil {
+		return dto.UserResponse{}, err
 	}
 
-	// check username if available
-	user_check, err := s.UserRepository.FindByUsername(ctx, tx, user.Username)
-	if (err != nil) && (err != sql.ErrNoRows) {
+	res, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (dto.UserResponse, error) {
+		user := entity.User{
+			Username: req.Username,
+			Password: hashed,
+			Role:     req.Role,
+		}
 
-		return dto.UserResponse{}, err
-	}
+		if user.Role == 0 {
+			user.Role = entity.RoleUser
+		}
 
-	if user_check.Id != 0 {
-		return dto.UserResponse{}, helper.NewErrorUserUsernameExist()
-	}
+		// any other role is an assignment, creating a user must not be a way around it
+		if user.Role != entity.RoleUser {
+			if err := s.authorize(ctx, policy.ActionUserAssignRole, user); err != nil {
+				return dto.UserResponse{}, err
+			}
+		}
 
-	// hashed password
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(user.Password), 16)
-	if err != nil {
-		return dto.UserResponse{}, err
-	}
+		// check role if exist
+		role, err := s.findRole(ctx, tx, user.Role)
+		if err != nil {
+			return dto.UserResponse{}, err
+		}
+		user.RoleName = role.Name
 
-	user.Password = string(hashedPass)
+		// check username if available
+		user_check, err := s.findByUsernameForUniqueCheck(ctx, tx, user.Username)
+		if (err != nil) && (err != sql.ErrNoRows) {
+			return dto.UserResponse{}, err
+		}
 
-	// save user
-	user, err = s.UserRepository.Save(ctx, tx, &user)
-	if err != nil {
-		return dto.UserResponse{}, err
-	}
+		if user_check.Id != 0 {
+			return dto.UserResponse{}, helper.NewErrorUserUsernameExist()
+		}
+
+		// save user
+		user, err = s.UserRepository.Save(ctx, tx, &user)
+		if err != nil {
+			return dto.UserResponse{}, err
+		}
 
-	if err = tx.Commit(); err != nil {
-		return dto.UserResponse{}, errors.New("error commit transaction")
+		return helper.ToUserResponse(user), nil
+	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "user created", "user_id", res.Id, "role", res.Role)
 	}
 
-	return helper.ToUserResponse(user), nil
+	return res, err
 }
 
 func (s *UserServiceImpl) Update(ctx context.Context, req *dto.UserUpdate) error {
-	_, err := helper.WithTransaction(ctx, s.DB, func(tx *sql.Tx) (interface{}, error) {
+	// role change is audited once committed
+	previousRole := 0
+
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		previousRole = 0
 
 		// check user by id
 		user, err := s.UserRepository.FindByID(ctx, tx, req.Id)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return nil, helper.NewErrorUserNotFound()
+				return helper.NewErrorUserNotFound()
 			}
 
-			return nil, err
+			return err
 		}
 
 		// check username if exist or not
-		username_check, err := s.UserRepository.FindByUsername(ctx, tx, req.Username)
+		username_check, err := s.findByUsernameForUniqueCheck(ctx, tx, req.Username)
 		if err != nil && err != sql.ErrNoRows {
-			return nil, err
+			return err
 		}
 
 		// check if avail but not the same user
 		if (username_check.Id != 0) && (username_check.Id != user.Id) {
-			return nil, helper.NewErrorUserUsernameExist()
+			return helper.NewErrorUserUsernameExist()
+		}
+
+		// role not sent mean keep the current role
+		if req.Role != 0 && req.Role != user.Role {
+			if err = s.authorize(ctx, policy.ActionUserAssignRole, user); err != nil {
+				return err
+			}
+
+			if _, err = s.findRole(ctx, tx, req.Role); err != nil {
+				return err
+			}
+
+			previousRole = user.Role
+			user.Role = req.Role
 		}
 
 		user_update := entity.User{
 			Id:       req.Id,
 			Username: req.Username,
-			Role:     req.Role,
+			Role:     user.Role,
 		}
 
 		// update user data
 		if err = s.UserRepository.Update(ctx, tx, &user_update); err != nil {
-			return nil, err
+			return err
 		}
 
-		return nil, nil
+		return nil
 	})
+	if err == nil && previousRole != 0 {
+		s.Logger.InfoContext(ctx, "user role changed", "user_id", req.Id, "previous_role", previousRole, "role", req.Role)
+	}
 
 	return err
 }
 
 func (s *UserServiceImpl) ChangePassword(ctx context.Context, req *dto.UserChangePassword) error {
-	_, err := helper.WithTransaction(ctx, s.DB, func(tx *sql.Tx) (interface{}, error) {
-		// check user by id
-		user, err := s.UserRepository.FindByID(ctx, tx, req.Id)
+	// verify and hash outside the transaction, both are slow and a retried transaction must not
+	// repeat them while holding its locks
+	user, err := s.findUser(ctx, req.Id)
+	if err != nil {
+		return err
+	}
+
+	if err = comparePassword(ctx, s.PasswordHasher, user.Password, req.OldPassword); err != nil {
+		return helper.NewErrorUserPasswordIncorrect()
+	}
+
+	if err = s.PasswordPolicy.Validate(req.Password, user.Username); err != nil {
+		return err
+	}
+
+	hashed, err := hashPassword(ctx, s.PasswordHasher, req.Password)
+	if err != nil {
+		return err
+	}
+
+	err = helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		current, err := s.UserRepository.FindByID(ctx, tx, req.Id)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return nil, helper.NewErrorUserNotFound()
+				return helper.NewErrorUserNotFound()
 			}
 
-			return nil, err
+			return err
 		}
 
-		// compare password
-		if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.OldPassword)); err != nil {
-			return nil, helper.NewErrorUserPasswordIncorrect()
+		// changed since it was verified, the old password given may not be valid anymore
+		if current.Password != user.Password {
+			return helper.NewErrorUserPasswordIncorrect()
 		}
 
-		// hash new pass
-		hashedPass, err := bcrypt.GenerateFromPassword([]byte(req.Password), 16)
-		if err != nil {
-			return nil, err
+		current.Password = hashed
+		if err = s.UserRepository.ChangePassword(ctx, tx, &current); err != nil {
+			return err
 		}
 
-		user.Password = string(hashedPass)
+		// a reset link sent before must not set the password again
+		if err = s.PasswordResetRepository.DeleteByUser(ctx, tx, current.Id); err != nil {
+			return err
+		}
+
+		// every session created with the old password must login again
+		if err = revokeUserSessions(ctx, tx, s.RefreshTokenRepository, s.TokenRevocationStore, current.Id); err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+func (s *UserServiceImpl) ResetPassword(ctx context.Context, req *dto.UserResetPassword) error {
+	user, err := s.findUser(ctx, req.Id)
+	if err != nil {
+		return err
+	}
+
+	// checked before hashing, a denied caller must not cost a hash
+	if err = s.authorize(ctx, policy.ActionUserResetPassword, user); err != nil {
+		return err
+	}
+
+	if err = s.PasswordPolicy.Validate(req.Password, user.Username); err != nil {
+		return err
+	}
+
+	hashed, err := hashPassword(ctx, s.PasswordHasher, req.Password)
+	if err != nil {
+		return err
+	}
+
+	err = helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		user, err := s.UserRepository.FindByID(ctx, tx, req.Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorUserNotFound()
+			}
+
+			return err
+		}
 
-		// update user
+		user.Password = hashed
 		if err = s.UserRepository.ChangePassword(ctx, tx, &user); err != nil {
-			return nil, err
+			return err
 		}
 
-		return nil, nil
+		if err = s.PasswordResetRepository.DeleteByUser(ctx, tx, user.Id); err != nil {
+			return err
+		}
+
+		if err = revokeUserSessions(ctx, tx, s.RefreshTokenRepository, s.TokenRevocationStore, user.Id); err != nil {
+			return err
+		}
+
+		return nil
 	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "user password reset", "user_id", req.Id)
+	}
 
 	return err
 }
 
 func (s *UserServiceImpl) Delete(ctx context.Context, Id int) error {
-	_, err := helper.WithTransaction(ctx, s.DB, func(tx *sql.Tx) (interface{}, error) {
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
 		// check user by id
 		user, err := s.UserRepository.FindByID(ctx, tx, Id)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				return nil, helper.NewErrorUserNotFound()
+				return helper.NewErrorUserNotFound()
 			}
 
-			return nil, err
+			return err
 		}
 
 		// delete user
 		if err = s.UserRepository.Delete(ctx, tx, &user); err != nil {
-			return nil, err
+			return err
 		}
 
-		return nil, nil
+		if err = revokeUserSessions(ctx, tx, s.RefreshTokenRepository, s.TokenRevocationStore, user.Id); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "user deleted", "user_id", Id)
+	}
+
+	return err
+}
+
+func (s *UserServiceImpl) Restore(ctx context.Context, Id int) error {
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		user, err := s.UserRepository.FindByIDWithDeleted(ctx, tx, Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorUserNotFound()
+			}
+
+			return err
+		}
+
+		if !user.IsDeleted {
+			return helper.NewErrorUserNotDeleted()
+		}
+
+		// username can be taken by another user while deleted when reuse is allowed
+		username_check, err := s.UserRepository.FindByUsername(ctx, tx, user.Username)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		if username_check.Id != 0 {
+			return helper.NewErrorUserUsernameExist()
+		}
+
+		if err = s.UserRepository.Restore(ctx, tx, &user); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "user restored", "user_id", Id)
+	}
+
+	return err
+}
+
+func (s *UserServiceImpl) Purge(ctx context.Context, Id int) error {
+	err := helper.RunTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) error {
+		// purge work for both active and soft deleted user
+		user, err := s.UserRepository.FindByIDWithDeleted(ctx, tx, Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return helper.NewErrorUserNotFound()
+			}
+
+			return err
+		}
+
+		if err = s.authorize(ctx, policy.ActionUserPurge, user); err != nil {
+			return err
+		}
+
+		// revoke first, refresh tokens row are removed together with the user
+		if err = revokeUserSessions(ctx, tx, s.RefreshTokenRepository, s.TokenRevocationStore, user.Id); err != nil {
+			return err
+		}
+
+		if err = s.UserRepository.Purge(ctx, tx, &user); err != nil {
+			return err
+		}
+
+		return nil
 	})
+	if err == nil {
+		s.Logger.InfoContext(ctx, "user purged", "user_id", Id)
+	}
 
 	return err
 }
+
+func (s *UserServiceImpl) Unlock(ctx context.Context, Id int) error {
+	user, err := helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (entity.User, error) {
+		user, err := s.UserRepository.FindByID(ctx, tx, Id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return user, helper.NewErrorUserNotFound()
+			}
+
+			return user, err
+		}
+
+		return user, s.authorize(ctx, policy.ActionUserUnlock, user)
+	})
+	if err != nil {
+		return err
+	}
+
+	// failures are kept outside of the database transaction
+	if err = s.LoginGuard.Unlock(ctx, user.Username); err != nil {
+		return err
+	}
+
+	s.Logger.InfoContext(ctx, "user login unlocked", "user_id", Id)
+
+	return nil
+}
+
+// authorize only apply when the context carry a subject (http request), internal callers like the cli are trusted
+func (s *UserServiceImpl) authorize(ctx context.Context, action string, user entity.User) error {
+	subject, ok := authz.SubjectFromContext(ctx)
+	if !ok || s.Authorizer == nil {
+		return nil
+	}
+
+	decision := s.Authorizer.Authorize(ctx, subject, action, policy.NewUserResource(user.Id))
+	if !decision.Allowed {
+		return helper.NewErrorAccessDenied(decision.Reason)
+	}
+
+	return nil
+}
+
+// findUser read the user in its own transaction, for the checks done before a write transaction
+func (s *UserServiceImpl) findUser(ctx context.Context, id int) (entity.User, error) {
+	return helper.WithTx(ctx, s.DB, func(ctx context.Context, tx *sql.Tx) (entity.User, error) {
+		user, err := s.UserRepository.FindByID(ctx, tx, id)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return user, helper.NewErrorUserNotFound()
+			}
+
+			return user, err
+		}
+
+		return user, nil
+	})
+}
+
+func (s *UserServiceImpl) findRole(ctx context.Context, tx *sql.Tx, roleId int) (entity.Role, error) {
+	role, err := s.RoleRepository.FindByID(ctx, tx, roleId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return role, helper.NewErrorRoleInvalid()
+		}
+
+		return role, err
+	}
+
+	return role, nil
+}
+
+// findByUsernameForUniqueCheck include soft deleted users unless their username is allowed to be reused
+func (s *UserServiceImpl) findByUsernameForUniqueCheck(ctx context.Context, tx *sql.Tx, username string) (entity.User, error) {
+	if s.ReuseDeletedUsername {
+		return s.UserRepository.FindByUsername(ctx, tx, username)
+	}
+
+	return s.UserRepository.FindByUsernameWithDeleted(ctx, tx, username)
+}
+
+// revokeUserSessions revoke all refresh tokens and every access token issued until now
+func revokeUserSessions(ctx context.Context, tx *sql.Tx, refreshTokenRepository repository.RefreshTokenRepository, tokenRevocationStore repository.TokenRevocationStore, userId int) error {
+	if err := refreshTokenRepository.RevokeByUser(ctx, tx, userId); err != nil {
+		return err
+	}
+
+	// token iat only has second precision, tokens issued in the same second are revoked too
+	return tokenRevocationStore.RevokeUserTokens(ctx, userId, time.Now().Truncate(time.Second))
+}
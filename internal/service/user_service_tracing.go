@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"gofiber-cleanarch-test/internal/interfaces/http/dto"
+	"gofiber-cleanarch-test/pkg/helper"
+	"gofiber-cleanarch-test/pkg/password"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "gofiber-cleanarch-test/internal/service"
+
+// UserServiceTracing wrap a UserService with one span per call
+type UserServiceTracing struct {
+	Next   UserService
+	Tracer trace.Tracer
+}
+
+func NewUserServiceTracing(next UserService) UserService {
+	return &UserServiceTracing{
+		Next:   next,
+		Tracer: otel.Tracer(tracerName),
+	}
+}
+
+func (s *UserServiceTracing) FindAllWithPagination(ctx context.Context, limit int, offset int) (dto.PaginationData, error) {
+	ctx, span := s.Tracer.Start(ctx, "UserService.FindAllWithPagination", trace.WithAttributes(attribute.Int("limit", limit), attribute.Int("offset", offset)))
+	res, err := s.Next.FindAllWithPagination(ctx, limit, offset)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (s *UserServiceTracing) FindById(ctx context.Context, Id int) (dto.UserResponse, error) {
+	ctx, span := s.Tracer.Start(ctx, "UserService.FindById", userIdAttribute(Id))
+	res, err := s.Next.FindById(ctx, Id)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (s *UserServiceTracing) FindByUsername(ctx context.Context, username string) (dto.UserResponse, error) {
+	ctx, span := s.Tracer.Start(ctx, "UserService.FindByUsername")
+	res, err := s.Next.FindByUsername(ctx, username)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (s *UserServiceTracing) Create(ctx context.Context, req *dto.UserCreate) (dto.UserResponse, error) {
+	ctx, span := s.Tracer.Start(ctx, "UserService.Create")
+	res, err := s.Next.Create(ctx, req)
+	endSpan(span, err)
+
+	return res, err
+}
+
+func (s *UserServiceTracing) Update(ctx context.Context, req *dto.UserUpdate) error {
+	ctx, span := s.Tracer.Start(ctx, "UserService.Update", userIdAttribute(req.Id))
+	err := s.Next.Update(ctx, req)
+	endSpan(span, err)
+
+	return err
+}
+
+func (s *UserServiceTracing) ChangePassword(ctx context.Context, req *dto.UserChangePassword) error {
+	ctx, span := s.Tracer.Start(ctx, "UserService.ChangePassword", userIdAttribute(req.Id))
+	err := s.Next.ChangePassword(ctx, req)
+	endSpan(span, err)
+
+	return err
+}
+
+func (s *UserServiceTracing) ResetPassword(ctx context.Context, req *dto.UserResetPassword) error {
+	ctx, span := s.Tracer.Start(ctx, "UserService.ResetPassword", userIdAttribute(req.Id))
+	err := s.Next.ResetPassword(ctx, req)
+	endSpan(span, err)
+
+	return err
+}
+
+func (s *UserServiceTracing) Delete(ctx context.Context, Id int) error {
+	ctx, span := s.Tracer.Start(ctx, "UserService.Delete", userIdAttribute(Id))
+	err := s.Next.Delete(ctx, Id)
+	endSpan(span, err)
+
+	return err
+}
+
+func (s *UserServiceTracing) Restore(ctx context.Context, Id int) error {
+	ctx, span := s.Tracer.Start(ctx, "UserService.Restore", userIdAttribute(Id))
+	err := s.Next.Restore(ctx, Id)
+	endSpan(span, err)
+
+	return err
+}
+
+func (s *UserServiceTracing) Unlock(ctx context.Context, Id int) error {
+	ctx, span := s.Tracer.Start(ctx, "UserService.Unlock", userIdAttribute(Id))
+	err := s.Next.Unlock(ctx, Id)
+	endSpan(span, err)
+
+	return err
+}
+
+func (s *UserServiceTracing) Purge(ctx context.Context, Id int) error {
+	ctx, span := s.Tracer.Start(ctx, "UserService.Purge", userIdAttribute(Id))
+	err := s.Next.Purge(ctx, Id)
+	endSpan(span, err)
+
+	return err
+}
+
+// endSpan record the error and end the span, client errors (not found, validation, ...) are not span errors
+func endSpan(span trace.Span, err error) {
+	if e, ok := err.(helper.AppError); ok && e.Code < fiber.StatusInternalServerError {
+		span.SetAttributes(attribute.String("app.error", e.Message))
+	} else if e, ok := err.(*password.PolicyError); ok {
+		// a refused password is a client error too
+		span.SetAttributes(attribute.String("app.error", e.Error()))
+	} else if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func userIdAttribute(id int) trace.SpanStartOption {
+	return trace.WithAttributes(attribute.Int("user.id", id))
+}
@@ -0,0 +1,177 @@
+// Package authz is a small policy engine: a policy registered per resource type decide
+// if a subject can do an action on a resource. Resource type without policy is always denied.
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrDenied = errors.New("authz: access denied")
+
+type Subject struct {
+	Id          int
+	Role        int
+	Permissions []string
+}
+
+func (s Subject) HasPermission(permission string) bool {
+	for _, p := range s.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Resource describe the target of the action, Id empty mean the whole collection of the type
+type Resource struct {
+	Type       string
+	Id         string
+	OwnerId    int
+	Attributes map[string]interface{}
+}
+
+// IsOwnedBy report if the resource belong to the subject, resource without owner belong to nobody
+func (r Resource) IsOwnedBy(subject Subject) bool {
+	return r.OwnerId != 0 && r.OwnerId == subject.Id
+}
+
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+func Allow(reason string) Decision {
+	return Decision{Allowed: true, Reason: reason}
+}
+
+func Deny(reason string) Decision {
+	return Decision{Allowed: false, Reason: reason}
+}
+
+// Err return nil when allowed, otherwise ErrDenied with the reason
+func (d Decision) Err() error {
+	if d.Allowed {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrDenied, d.Reason)
+}
+
+type Policy interface {
+	Evaluate(ctx context.Context, subject Subject, action string, resource Resource) Decision
+}
+
+type PolicyFunc func(ctx context.Context, subject Subject, action string, resource Resource) Decision
+
+func (f PolicyFunc) Evaluate(ctx context.Context, subject Subject, action string, resource Resource) Decision {
+	return f(ctx, subject, action, resource)
+}
+
+type DecisionEntry struct {
+	Time     time.Time
+	Subject  Subject
+	Action   string
+	Resource Resource
+	Decision Decision
+}
+
+func (e DecisionEntry) String() string {
+	result := "deny"
+	if e.Decision.Allowed {
+		result = "allow"
+	}
+
+	resource := e.Resource.Type
+	if e.Resource.Id != "" {
+		resource += "/" + e.Resource.Id
+	}
+
+	return "authz " + result + ": subject=" + strconv.Itoa(e.Subject.Id) + " action=" + e.Action + " resource=" + resource + " reason=" + e.Decision.Reason
+}
+
+// DecisionLogger receive every decision made by the engine, used for debugging policies
+type DecisionLogger interface {
+	LogDecision(ctx context.Context, entry DecisionEntry)
+}
+
+type DecisionLoggerFunc func(ctx context.Context, entry DecisionEntry)
+
+func (f DecisionLoggerFunc) LogDecision(ctx context.Context, entry DecisionEntry) {
+	f(ctx, entry)
+}
+
+type Option func(*Engine)
+
+func WithDecisionLogger(logger DecisionLogger) Option {
+	return func(e *Engine) {
+		e.logger = logger
+	}
+}
+
+type Engine struct {
+	mu       sync.RWMutex
+	policies map[string]Policy
+	logger   DecisionLogger
+}
+
+func NewEngine(opts ...Option) *Engine {
+	e := &Engine{
+		policies: make(map[string]Policy),
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	return e
+}
+
+// Register set the policy of a resource type, registering the same type again replace the policy
+func (e *Engine) Register(resourceType string, policy Policy) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.policies[resourceType] = policy
+}
+
+func (e *Engine) Authorize(ctx context.Context, subject Subject, action string, resource Resource) Decision {
+	e.mu.RLock()
+	policy, ok := e.policies[resource.Type]
+	e.mu.RUnlock()
+
+	decision := Deny("no policy registered for resource type " + resource.Type)
+	if ok {
+		decision = policy.Evaluate(ctx, subject, action, resource)
+	}
+
+	if e.logger != nil {
+		e.logger.LogDecision(ctx, DecisionEntry{
+			Time:     time.Now(),
+			Subject:  subject,
+			Action:   action,
+			Resource: resource,
+			Decision: decision,
+		})
+	}
+
+	return decision
+}
+
+type subjectContextKey struct{}
+
+// WithSubject attach the subject to context so services can authorize without knowing the http layer
+func WithSubject(ctx context.Context, subject Subject) context.Context {
+	return context.WithValue(ctx, subjectContextKey{}, subject)
+}
+
+func SubjectFromContext(ctx context.Context) (Subject, bool) {
+	subject, ok := ctx.Value(subjectContextKey{}).(Subject)
+	return subject, ok
+}
@@ -0,0 +1,129 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestEngineAuthorize(t *testing.T) {
+	ownerOnly := PolicyFunc(func(ctx context.Context, subject Subject, action string, resource Resource) Decision {
+		if resource.IsOwnedBy(subject) {
+			return Allow("owner")
+		}
+		return Deny("not owner")
+	})
+
+	tests := []struct {
+		name     string
+		subject  Subject
+		resource Resource
+		allowed  bool
+		reason   string
+	}{
+		{
+			name:     "owner allowed",
+			subject:  Subject{Id: 1},
+			resource: Resource{Type: "note", Id: "10", OwnerId: 1},
+			allowed:  true,
+			reason:   "owner",
+		},
+		{
+			name:     "other subject denied",
+			subject:  Subject{Id: 2},
+			resource: Resource{Type: "note", Id: "10", OwnerId: 1},
+			allowed:  false,
+			reason:   "not owner",
+		},
+		{
+			name:     "resource without owner belong to nobody",
+			subject:  Subject{},
+			resource: Resource{Type: "note"},
+			allowed:  false,
+			reason:   "not owner",
+		},
+		{
+			name:     "unregistered type denied",
+			subject:  Subject{Id: 1},
+			resource: Resource{Type: "invoice", OwnerId: 1},
+			allowed:  false,
+			reason:   "no policy registered for resource type invoice",
+		},
+	}
+
+	engine := NewEngine()
+	engine.Register("note", ownerOnly)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision := engine.Authorize(context.Background(), tt.subject, "read", tt.resource)
+
+			if decision.Allowed != tt.allowed {
+				t.Errorf("Allowed = %v, want %v", decision.Allowed, tt.allowed)
+			}
+			if decision.Reason != tt.reason {
+				t.Errorf("Reason = %q, want %q", decision.Reason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestEngineDecisionLog(t *testing.T) {
+	var entries []DecisionEntry
+	engine := NewEngine(WithDecisionLogger(DecisionLoggerFunc(func(ctx context.Context, entry DecisionEntry) {
+		entries = append(entries, entry)
+	})))
+	engine.Register("note", PolicyFunc(func(ctx context.Context, subject Subject, action string, resource Resource) Decision {
+		return Allow("always")
+	}))
+
+	engine.Authorize(context.Background(), Subject{Id: 7}, "read", Resource{Type: "note", Id: "1"})
+	engine.Authorize(context.Background(), Subject{Id: 7}, "read", Resource{Type: "other"})
+
+	if len(entries) != 2 {
+		t.Fatalf("logged %d decisions, want 2", len(entries))
+	}
+
+	want := "authz allow: subject=7 action=read resource=note/1 reason=always"
+	if got := entries[0].String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if entries[1].Decision.Allowed {
+		t.Errorf("unregistered type logged as allowed")
+	}
+}
+
+func TestDecisionErr(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision Decision
+		wantErr  bool
+	}{
+		{name: "allow", decision: Allow("ok"), wantErr: false},
+		{name: "deny", decision: Deny("nope"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.decision.Err()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Err() = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDenied) {
+				t.Errorf("Err() = %v, want wrapped ErrDenied", err)
+			}
+		})
+	}
+}
+
+func TestSubjectContext(t *testing.T) {
+	if _, ok := SubjectFromContext(context.Background()); ok {
+		t.Fatal("empty context should not have subject")
+	}
+
+	ctx := WithSubject(context.Background(), Subject{Id: 3, Permissions: []string{"a"}})
+	subject, ok := SubjectFromContext(ctx)
+	if !ok || subject.Id != 3 || !subject.HasPermission("a") {
+		t.Errorf("SubjectFromContext() = %+v, %v", subject, ok)
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		run  func(m *Memory, clock *time.Time)
+		want map[string]string
+	}{
+		{
+			name: "get set value",
+			run: func(m *Memory, clock *time.Time) {
+				m.Set(ctx, "a", []byte("1"), time.Minute)
+			},
+			want: map[string]string{"a": "1", "b": ""},
+		},
+		{
+			name: "set replace value",
+			run: func(m *Memory, clock *time.Time) {
+				m.Set(ctx, "a", []byte("1"), time.Minute)
+				m.Set(ctx, "a", []byte("2"), time.Minute)
+			},
+			want: map[string]string{"a": "2"},
+		},
+		{
+			name: "expired entry is missing",
+			run: func(m *Memory, clock *time.Time) {
+				m.Set(ctx, "a", []byte("1"), time.Minute)
+				m.Set(ctx, "b", []byte("2"), 2*time.Minute)
+				*clock = clock.Add(time.Minute)
+			},
+			want: map[string]string{"a": "", "b": "2"},
+		},
+		{
+			name: "least recently used is evicted",
+			run: func(m *Memory, clock *time.Time) {
+				m.Set(ctx, "a", []byte("1"), time.Minute)
+				m.Set(ctx, "b", []byte("2"), time.Minute)
+				m.Get(ctx, "a")
+				m.Set(ctx, "c", []byte("3"), time.Minute)
+			},
+			want: map[string]string{"a": "1", "b": "", "c": "3"},
+		},
+		{
+			name: "delete",
+			run: func(m *Memory, clock *time.Time) {
+				m.Set(ctx, "a", []byte("1"), time.Minute)
+				m.Set(ctx, "b", []byte("2"), time.Minute)
+				m.Delete(ctx, "a", "b", "missing")
+			},
+			want: map[string]string{"a": "", "b": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			m := NewMemory(2)
+			m.now = func() time.Time { return clock }
+
+			tt.run(m, &clock)
+
+			for key, want := range tt.want {
+				value, found, err := m.Get(ctx, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if found != (want != "") || string(value) != want {
+					t.Errorf("%s = %q (found %v), want %q", key, value, found, want)
+				}
+			}
+			if m.Len() > 2 {
+				t.Errorf("len = %d, want at most 2", m.Len())
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Package dbcluster route read only transactions to healthy read replicas and everything else to
+// the primary. Replicas are checked in background, a replica failing its check or failing to begin
+// a transaction is skipped until a later check succeed, and reads fall back to the primary when
+// no replica is healthy.
+package dbcluster
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultCheckInterval = 5 * time.Second
+	defaultCheckTimeout  = 2 * time.Second
+)
+
+// Checker report whether a replica can serve reads, e.g. a ping or a replication lag check
+type Checker func(ctx context.Context, db *sql.DB) error
+
+type Option func(*Cluster)
+
+func WithCheckInterval(interval time.Duration) Option {
+	return func(c *Cluster) {
+		c.checkInterval = interval
+	}
+}
+
+func WithCheckTimeout(timeout time.Duration) Option {
+	return func(c *Cluster) {
+		c.checkTimeout = timeout
+	}
+}
+
+// WithChecker replace the default ping check
+func WithChecker(checker Checker) Option {
+	return func(c *Cluster) {
+		c.checker = checker
+	}
+}
+
+// WithStateListener is called when a replica become healthy or unhealthy, err is the cause. It can
+// be called concurrently for different replicas.
+func WithStateListener(listener func(replica int, healthy bool, err error)) Option {
+	return func(c *Cluster) {
+		c.listener = listener
+	}
+}
+
+type replica struct {
+	db      *sql.DB
+	healthy atomic.Bool
+}
+
+type Cluster struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint32
+
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+	checker       Checker
+	listener      func(replica int, healthy bool, err error)
+
+	mu   sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// New create a cluster, replicas are considered healthy until the first check
+func New(primary *sql.DB, replicas []*sql.DB, opts ...Option) *Cluster {
+	c := &Cluster{
+		primary:       primary,
+		checkInterval: defaultCheckInterval,
+		checkTimeout:  defaultCheckTimeout,
+		checker: func(ctx context.Context, db *sql.DB) error {
+			return db.PingContext(ctx)
+		},
+	}
+
+	for _, db := range replicas {
+		r := &replica{db: db}
+		r.healthy.Store(true)
+		c.replicas = append(c.replicas, r)
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+func (c *Cluster) Primary() *sql.DB {
+	return c.primary
+}
+
+// BeginTx start read only transactions on a healthy replica unless the context is pinned to the
+// primary, every other transaction start on the primary
+func (c *Cluster) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	if opts == nil || !opts.ReadOnly || IsPrimaryPinned(ctx) {
+		return c.primary.BeginTx(ctx, opts)
+	}
+
+	if index, r := c.pick(); r != nil {
+		tx, err := r.db.BeginTx(ctx, opts)
+		if err == nil {
+			return tx, nil
+		}
+		// canceled request say nothing about the replica
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		c.setHealthy(index, false, err)
+	}
+
+	return c.primary.BeginTx(ctx, opts)
+}
+
+// pick return the next healthy replica in round robin order
+func (c *Cluster) pick() (int, *replica) {
+	n := len(c.replicas)
+	if n == 0 {
+		return -1, nil
+	}
+
+	start := int(c.next.Add(1) - 1)
+	for i := 0; i < n; i++ {
+		index := (start + i) % n
+		if c.replicas[index].healthy.Load() {
+			return index, c.replicas[index]
+		}
+	}
+
+	return -1, nil
+}
+
+func (c *Cluster) setHealthy(index int, healthy bool, err error) {
+	if c.replicas[index].healthy.Swap(healthy) != healthy && c.listener != nil {
+		c.listener(index, healthy, err)
+	}
+}
+
+// Check run the checker on every replica concurrently and update their state
+func (c *Cluster) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i, r := range c.replicas {
+		wg.Add(1)
+		go func(index int, r *replica) {
+			defer wg.Done()
+
+			checkCtx, cancel := context.WithTimeout(ctx, c.checkTimeout)
+			defer cancel()
+
+			err := c.checker(checkCtx, r.db)
+			c.setHealthy(index, err == nil, err)
+		}(i, r)
+	}
+	wg.Wait()
+}
+
+// Healthy return the number of replicas currently used for reads
+func (c *Cluster) Healthy() int {
+	total := 0
+	for _, r := range c.replicas {
+		if r.healthy.Load() {
+			total++
+		}
+	}
+
+	return total
+}
+
+// Start check the replicas once then keep checking them in background until Stop
+func (c *Cluster) Start(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stop != nil {
+		return errors.New("dbcluster: already started")
+	}
+	if len(c.replicas) == 0 {
+		return nil
+	}
+
+	c.Check(ctx)
+
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go c.loop(c.stop, c.done)
+
+	return nil
+}
+
+func (c *Cluster) loop(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(c.checkInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Check(context.Background())
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Stop end the background checks, the databases are not closed
+func (c *Cluster) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stop == nil {
+		return nil
+	}
+
+	close(c.stop)
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	c.stop = nil
+
+	return nil
+}
+
+type pinPrimaryContextKey struct{}
+
+// PinPrimary make every transaction started with the context use the primary, for reads that must
+// see writes just made (replicas can lag behind)
+func PinPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, pinPrimaryContextKey{}, true)
+}
+
+func IsPrimaryPinned(ctx context.Context) bool {
+	pinned, _ := ctx.Value(pinPrimaryContextKey{}).(bool)
+	return pinned
+}
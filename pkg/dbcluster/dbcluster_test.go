@@ -0,0 +1,255 @@
+package dbcluster
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeDriver open one fake database per name, a database marked down fail pings and begins
+type fakeDriver struct {
+	mu    sync.Mutex
+	down  map[string]bool
+	begun []string
+}
+
+func (d *fakeDriver) setDown(name string, down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down[name] = down
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return &fakeConn{driver: d, name: name}, nil
+}
+
+type fakeConn struct {
+	driver *fakeDriver
+	name   string
+}
+
+func (c *fakeConn) err() error {
+	c.driver.mu.Lock()
+	defer c.driver.mu.Unlock()
+	if c.driver.down[c.name] {
+		return errors.New(c.name + " down")
+	}
+
+	return nil
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+
+	c.driver.mu.Lock()
+	c.driver.begun = append(c.driver.begun, c.name)
+	c.driver.mu.Unlock()
+
+	return fakeTx{}, nil
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	return c.err()
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+var registerOnce sync.Once
+
+var testDriver = &fakeDriver{down: make(map[string]bool)}
+
+func openFake(t *testing.T, name string) *sql.DB {
+	registerOnce.Do(func() { sql.Register("dbcluster-fake", testDriver) })
+
+	db, err := sql.Open("dbcluster-fake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a fresh connection per begin so the down flag is checked every time
+	db.SetMaxIdleConns(0)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func begin(t *testing.T, c *Cluster, ctx context.Context, readOnly bool) string {
+	t.Helper()
+
+	testDriver.mu.Lock()
+	testDriver.begun = nil
+	testDriver.mu.Unlock()
+
+	tx, err := c.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+
+	testDriver.mu.Lock()
+	defer testDriver.mu.Unlock()
+
+	return testDriver.begun[len(testDriver.begun)-1]
+}
+
+func TestClusterRouting(t *testing.T) {
+	primary := openFake(t, "primary")
+	replicaA := openFake(t, "replica-a")
+	replicaB := openFake(t, "replica-b")
+	t.Cleanup(func() {
+		testDriver.setDown("replica-a", false)
+		testDriver.setDown("replica-b", false)
+	})
+
+	var (
+		mu      sync.Mutex
+		changes []string
+	)
+	c := New(primary, []*sql.DB{replicaA, replicaB}, WithStateListener(func(replica int, healthy bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if healthy {
+			changes = append(changes, "up")
+		} else {
+			changes = append(changes, "down")
+		}
+	}))
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		setup    func()
+		ctx      context.Context
+		readOnly bool
+		want     []string
+		// anyOrder only compare which databases were used, round robin can start on any replica
+		anyOrder bool
+	}{
+		{
+			name: "write use primary",
+			ctx:  ctx,
+			want: []string{"primary", "primary"},
+		},
+		{
+			name:     "read use replicas in turn",
+			ctx:      ctx,
+			readOnly: true,
+			want:     []string{"replica-a", "replica-b", "replica-a"},
+		},
+		{
+			name:     "pinned read use primary",
+			ctx:      PinPrimary(ctx),
+			readOnly: true,
+			want:     []string{"primary", "primary"},
+		},
+		{
+			name: "unhealthy replica skipped after check",
+			setup: func() {
+				testDriver.setDown("replica-a", true)
+				c.Check(ctx)
+			},
+			ctx:      ctx,
+			readOnly: true,
+			want:     []string{"replica-b", "replica-b"},
+		},
+		{
+			name: "fallback to primary when no replica is healthy",
+			setup: func() {
+				testDriver.setDown("replica-b", true)
+				c.Check(ctx)
+			},
+			ctx:      ctx,
+			readOnly: true,
+			want:     []string{"primary", "primary"},
+		},
+		{
+			name: "recovered replica used again",
+			setup: func() {
+				testDriver.setDown("replica-a", false)
+				testDriver.setDown("replica-b", false)
+				c.Check(ctx)
+			},
+			ctx:      ctx,
+			readOnly: true,
+			want:     []string{"replica-a", "replica-b"},
+			anyOrder: true,
+		},
+		{
+			name: "failed begin mark replica unhealthy and fall back",
+			setup: func() {
+				testDriver.setDown("replica-a", true)
+				testDriver.setDown("replica-b", true)
+			},
+			ctx:      ctx,
+			readOnly: true,
+			want:     []string{"primary", "primary"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.setup != nil {
+				tt.setup()
+			}
+
+			var got []string
+			for range tt.want {
+				got = append(got, begin(t, c, tt.ctx, tt.readOnly))
+			}
+			if tt.anyOrder {
+				sort.Strings(got)
+			}
+
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("transactions on %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if c.Healthy() != 0 {
+		t.Errorf("healthy = %d, want 0", c.Healthy())
+	}
+
+	want := "down,down,up,up,down,down"
+	if got := strings.Join(changes, ","); got != want {
+		t.Errorf("state changes = %s, want %s", got, want)
+	}
+}
+
+func TestClusterStartStop(t *testing.T) {
+	c := New(openFake(t, "primary"), []*sql.DB{openFake(t, "replica-a")})
+
+	if err := c.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Start(context.Background()); err == nil {
+		t.Error("second start should fail")
+	}
+	if err := c.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Stop(context.Background()); err != nil {
+		t.Errorf("second stop should be a no-op, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+// Package health run the registered dependency checks for readiness probes. Every check get
+// its own timeout and all checks run concurrently, so one slow dependency does not hide the others.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+var ErrShuttingDown = errors.New("health: shutting down")
+
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+func (r Report) Healthy() bool {
+	return r.Status == StatusUp
+}
+
+type Registry struct {
+	mu           sync.RWMutex
+	checkers     map[string]Checker
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry create a registry, timeout is applied to every check separately
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		checkers: make(map[string]Checker),
+		timeout:  timeout,
+	}
+}
+
+// Register add a named check, registering the same name again replace the check
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkers[name] = checker
+}
+
+// SetShuttingDown make every following report unhealthy so the instance is taken out of rotation
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Check run every registered check concurrently, the report is down when any check fail
+// or the registry is shutting down
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := make(map[string]Checker, len(r.checkers))
+	for name, checker := range r.checkers {
+		checkers[name] = checker
+	}
+	r.mu.RUnlock()
+
+	report := Report{
+		Status: StatusUp,
+		Checks: make(map[string]CheckResult, len(checkers)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker Checker) {
+			defer wg.Done()
+
+			result := r.run(ctx, checker)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, checker)
+	}
+	wg.Wait()
+
+	if r.ShuttingDown() {
+		report.Status = StatusDown
+		report.Checks["shutdown"] = CheckResult{Status: StatusDown, Error: ErrShuttingDown.Error()}
+	}
+
+	return report
+}
+
+func (r *Registry) run(ctx context.Context, checker Checker) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.Check(ctx)
+	result := CheckResult{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	return result
+}
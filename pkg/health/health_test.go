@@ -0,0 +1,86 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRegistryCheck(t *testing.T) {
+	up := CheckerFunc(func(ctx context.Context) error { return nil })
+	down := CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+	slow := CheckerFunc(func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+
+	tests := []struct {
+		name         string
+		checkers     map[string]Checker
+		shuttingDown bool
+		wantStatus   string
+		wantChecks   map[string]string
+	}{
+		{
+			name:       "no checks",
+			wantStatus: StatusUp,
+			wantChecks: map[string]string{},
+		},
+		{
+			name:       "all up",
+			checkers:   map[string]Checker{"database": up, "cache": up},
+			wantStatus: StatusUp,
+			wantChecks: map[string]string{"database": StatusUp, "cache": StatusUp},
+		},
+		{
+			name:       "one down",
+			checkers:   map[string]Checker{"database": down, "cache": up},
+			wantStatus: StatusDown,
+			wantChecks: map[string]string{"database": StatusDown, "cache": StatusUp},
+		},
+		{
+			name:       "timeout",
+			checkers:   map[string]Checker{"database": slow},
+			wantStatus: StatusDown,
+			wantChecks: map[string]string{"database": StatusDown},
+		},
+		{
+			name:         "shutting down",
+			checkers:     map[string]Checker{"database": up},
+			shuttingDown: true,
+			wantStatus:   StatusDown,
+			wantChecks:   map[string]string{"database": StatusUp, "shutdown": StatusDown},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(20 * time.Millisecond)
+			for name, checker := range tt.checkers {
+				registry.Register(name, checker)
+			}
+			if tt.shuttingDown {
+				registry.SetShuttingDown()
+			}
+
+			report := registry.Check(context.Background())
+			if report.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", report.Status, tt.wantStatus)
+			}
+
+			if len(report.Checks) != len(tt.wantChecks) {
+				t.Fatalf("got %d checks, want %d", len(report.Checks), len(tt.wantChecks))
+			}
+			for name, want := range tt.wantChecks {
+				if got := report.Checks[name].Status; got != want {
+					t.Errorf("check %s = %s, want %s", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -20,6 +20,87 @@ func NewErrorAuthLoginUnauthorized() AppError {
 	}
 }
 
+// NewErrorAuthLoginLocked is the same for a delayed or locked account or ip, and whatever the password
+func NewErrorAuthLoginLocked() AppError {
+	return AppError{
+		Code:    fiber.StatusTooManyRequests,
+		Message: "Too many failed login attempts, please try again later",
+	}
+}
+
+func NewErrorAuthRefreshTokenInvalid() AppError {
+	return AppError{
+		Code:    fiber.StatusUnauthorized,
+		Message: "Refresh token invalid or expired",
+	}
+}
+
+func NewErrorAuthRefreshTokenReused() AppError {
+	return AppError{
+		Code:    fiber.StatusUnauthorized,
+		Message: "Refresh token already used, please login again",
+	}
+}
+
+// --------------------- password reset error
+func NewErrorPasswordResetTokenInvalid() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Password reset token invalid or expired",
+	}
+}
+
+func NewErrorPasswordResetBusy() AppError {
+	return AppError{
+		Code:    fiber.StatusServiceUnavailable,
+		Message: "Too many password reset requests, please try again later",
+	}
+}
+
+// --------------------- two factor error
+func NewErrorTwoFactorChallengeInvalid() AppError {
+	return AppError{
+		Code:    fiber.StatusUnauthorized,
+		Message: "Two factor challenge invalid or expired, please login again",
+	}
+}
+
+func NewErrorTwoFactorCodeInvalid() AppError {
+	return AppError{
+		Code:    fiber.StatusUnauthorized,
+		Message: "Two factor code incorrect",
+	}
+}
+
+func NewErrorTwoFactorAlreadyEnabled() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Two factor authentication already enabled",
+	}
+}
+
+func NewErrorTwoFactorNotEnrolled() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Two factor authentication not enrolled",
+	}
+}
+
+func NewErrorTwoFactorRequiredByRole() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Two factor authentication is required by your role",
+	}
+}
+
+// --------------------- authorization error
+func NewErrorAccessDenied(reason string) AppError {
+	return AppError{
+		Code:    fiber.StatusForbidden,
+		Message: "Forbidden: " + reason,
+	}
+}
+
 // ---------------------  user error
 func NewErrorUserNotFound() AppError {
 	return AppError{
@@ -41,3 +122,54 @@ func NewErrorUserPasswordIncorrect() AppError {
 		Message: "Password incorrect",
 	}
 }
+
+func NewErrorUserNotDeleted() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "User is not deleted",
+	}
+}
+
+// ---------------------  role error
+func NewErrorRoleNotFound() AppError {
+	return AppError{
+		Code:    fiber.StatusNotFound,
+		Message: "Role not found",
+	}
+}
+
+func NewErrorRoleNameExist() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Role name already exist",
+	}
+}
+
+func NewErrorRoleInUse() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Role still used by users",
+	}
+}
+
+func NewErrorRoleDefault() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Default role can not be deleted",
+	}
+}
+
+func NewErrorRoleInvalid() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Role not valid",
+	}
+}
+
+// ---------------------  permission error
+func NewErrorPermissionInvalid() AppError {
+	return AppError{
+		Code:    fiber.StatusBadRequest,
+		Message: "Permission not valid",
+	}
+}
@@ -11,8 +11,11 @@ func ToUserResponse(user entity.User) dto.UserResponse {
 		Id:        user.Id,
 		Username:  user.Username,
 		Role:      user.Role,
+		RoleName:  user.RoleName,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
+
+		TwoFactorRequired: user.RoleRequire2FA,
 	}
 }
 
@@ -29,3 +32,49 @@ func ToUserResponses(users []entity.User) []dto.UserResponse {
 
 	return usersRes
 }
+
+// for role domain response
+func ToRoleResponse(role entity.Role) dto.RoleResponse {
+	return dto.RoleResponse{
+		Id:         role.Id,
+		Name:       role.Name,
+		Require2FA: role.Require2FA,
+	}
+}
+
+func ToRoleResponses(roles []entity.Role) []dto.RoleResponse {
+	var rolesRes []dto.RoleResponse
+
+	if roles == nil {
+		return []dto.RoleResponse{}
+	}
+
+	for _, role := range roles {
+		rolesRes = append(rolesRes, ToRoleResponse(role))
+	}
+
+	return rolesRes
+}
+
+// for permission domain response
+func ToPermissionResponse(permission entity.Permission) dto.PermissionResponse {
+	return dto.PermissionResponse{
+		Id:          permission.Id,
+		Name:        permission.Name,
+		Description: permission.Description,
+	}
+}
+
+func ToPermissionResponses(permissions []entity.Permission) []dto.PermissionResponse {
+	var permissionsRes []dto.PermissionResponse
+
+	if permissions == nil {
+		return []dto.PermissionResponse{}
+	}
+
+	for _, permission := range permissions {
+		permissionsRes = append(permissionsRes, ToPermissionResponse(permission))
+	}
+
+	return permissionsRes
+}
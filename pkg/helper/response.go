@@ -30,9 +30,32 @@ func RespondWithPagination(c *fiber.Ctx, code int, message string, total int, pa
 	})
 }
 
+// ErrorMessageLocal is the c.Locals key holding the message of an error response, the access log read it
+const ErrorMessageLocal = "error_message"
+
 func RespondError(c *fiber.Ctx, statusCode int, message string) error {
-	return c.Status(statusCode).JSON(fiber.Map{
+	return c.Status(statusCode).JSON(errorBody(c, message))
+}
+
+// RespondErrorWithDetails add a list explaining the error, e.g. every violated password rule
+func RespondErrorWithDetails(c *fiber.Ctx, statusCode int, message string, details interface{}) error {
+	body := errorBody(c, message)
+	body["details"] = details
+
+	return c.Status(statusCode).JSON(body)
+}
+
+func errorBody(c *fiber.Ctx, message string) fiber.Map {
+	c.Locals(ErrorMessageLocal, message)
+
+	body := fiber.Map{
 		"error":   true,
 		"message": message,
-	})
+	}
+	// request id is set by the request id middleware, it let clients report the failing request
+	if requestId := c.GetRespHeader(fiber.HeaderXRequestID); requestId != "" {
+		body["request_id"] = requestId
+	}
+
+	return body
 }
@@ -3,15 +3,118 @@ package helper
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 )
 
-func WithTransaction(ctx context.Context, db *sql.DB, fn func(*sql.Tx) (interface{}, error)) (interface{}, error) {
-	tx, err := db.BeginTx(ctx, nil)
+// postgres error codes that are safe to retry by running the whole transaction again
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+const defaultTxMaxAttempts = 3
+
+type txOptions struct {
+	isolation   sql.IsolationLevel
+	readOnly    bool
+	maxAttempts int
+}
+
+type TxOption func(*txOptions)
+
+// WithIsolation set the isolation level, default is the database default (read committed)
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(o *txOptions) {
+		o.isolation = level
+	}
+}
+
+// ReadOnly start a read only transaction, postgres reject any write inside it
+func ReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.readOnly = true
+	}
+}
+
+// WithMaxAttempts bound how many times the transaction run when it fail with a serialization
+// failure or a deadlock, 1 disable the retry
+func WithMaxAttempts(attempts int) TxOption {
+	return func(o *txOptions) {
+		o.maxAttempts = attempts
+	}
+}
+
+// TxBeginner start transactions, implemented by *sql.DB and by the replica aware dbcluster.Cluster
+// which start read only transactions on a replica
+type TxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+type txContextKey struct{}
+
+type txState struct {
+	tx       *sql.Tx
+	depth    int
+	readOnly bool
+	// afterCommit is shared by the savepoints of the transaction
+	afterCommit *[]func()
+}
+
+// WithTx run fn in a transaction, commit when fn succeed and roll back on error or panic.
+//
+// The context given to fn carry the transaction: WithTx called again with it (e.g. a service
+// calling another service) run inside a savepoint of the same transaction instead of a new one,
+// options are ignored there and an error only roll back to the savepoint.
+//
+// Serialization failures and deadlocks retry the whole transaction, so fn must not have side
+// effects outside the database.
+func WithTx[T any](ctx context.Context, db TxBeginner, fn func(ctx context.Context, tx *sql.Tx) (T, error), opts ...TxOption) (T, error) {
+	if state, ok := ctx.Value(txContextKey{}).(*txState); ok {
+		return withSavepoint(ctx, state, fn)
+	}
+
+	options := txOptions{maxAttempts: defaultTxMaxAttempts}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var (
+		res T
+		err error
+	)
+	for attempt := 1; ; attempt++ {
+		res, err = runTx(ctx, db, &options, fn)
+		if err == nil || attempt >= options.maxAttempts || !IsRetryableTxError(err) {
+			return res, err
+		}
+
+		// small growing delay so the conflicting transaction can finish first
+		select {
+		case <-time.After(time.Duration(attempt) * 10 * time.Millisecond):
+		case <-ctx.Done():
+			return res, err
+		}
+	}
+}
+
+// RunTx is WithTx for transactions that only return an error
+func RunTx(ctx context.Context, db TxBeginner, fn func(ctx context.Context, tx *sql.Tx) error, opts ...TxOption) error {
+	_, err := WithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) (struct{}, error) {
+		return struct{}{}, fn(ctx, tx)
+	}, opts...)
+
+	return err
+}
+
+func runTx[T any](ctx context.Context, db TxBeginner, options *txOptions, fn func(ctx context.Context, tx *sql.Tx) (T, error)) (res T, err error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: options.isolation, ReadOnly: options.readOnly})
 	if err != nil {
-		return nil, err
+		return res, err
 	}
 
+	var afterCommit []func()
 	defer func() {
 		if p := recover(); p != nil {
 			_ = tx.Rollback()
@@ -20,15 +123,68 @@ func WithTransaction(ctx context.Context, db *sql.DB, fn func(*sql.Tx) (interfac
 			if rbErr := tx.Rollback(); rbErr != nil {
 				err = fmt.Errorf("error rolling back transaction: %v (original error : %w)", rbErr, err)
 			}
+		} else if cErr := tx.Commit(); cErr != nil {
+			err = fmt.Errorf("error committing transaction: %w", cErr)
 		} else {
-			cerr := tx.Commit()
-			if cerr != nil {
-				err = fmt.Errorf("error committing transaction: %v (original error : %w)", cerr, err)
+			for _, callback := range afterCommit {
+				callback()
 			}
 		}
 	}()
 
-	res, err := fn(tx)
+	return fn(context.WithValue(ctx, txContextKey{}, &txState{tx: tx, readOnly: options.readOnly, afterCommit: &afterCommit}), tx)
+}
+
+func withSavepoint[T any](ctx context.Context, parent *txState, fn func(ctx context.Context, tx *sql.Tx) (T, error)) (res T, err error) {
+	state := &txState{tx: parent.tx, depth: parent.depth + 1, readOnly: parent.readOnly, afterCommit: parent.afterCommit}
+	name := fmt.Sprintf("sp_%d", state.depth)
+
+	if _, err = state.tx.ExecContext(ctx, "savepoint "+name); err != nil {
+		return res, err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_, _ = state.tx.ExecContext(ctx, "rollback to savepoint "+name)
+			panic(p)
+		} else if err != nil {
+			if _, rbErr := state.tx.ExecContext(ctx, "rollback to savepoint "+name); rbErr != nil {
+				err = fmt.Errorf("error rolling back to savepoint: %v (original error : %w)", rbErr, err)
+			}
+		} else if _, rErr := state.tx.ExecContext(ctx, "release savepoint "+name); rErr != nil {
+			err = fmt.Errorf("error releasing savepoint: %w", rErr)
+		}
+	}()
+
+	return fn(context.WithValue(ctx, txContextKey{}, state), state.tx)
+}
+
+// AfterCommit run fn once the transaction carried by ctx is committed, right away when ctx carry
+// no transaction. Callbacks registered in a savepoint that was rolled back still run, so fn must
+// be harmless then, e.g. a cache invalidation.
+func AfterCommit(ctx context.Context, fn func()) {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	if !ok {
+		fn()
+		return
+	}
+
+	*state.afterCommit = append(*state.afterCommit, fn)
+}
+
+// InReadOnlyTx report whether ctx carry a read only transaction started by WithTx
+func InReadOnlyTx(ctx context.Context) bool {
+	state, ok := ctx.Value(txContextKey{}).(*txState)
+	return ok && state.readOnly
+}
+
+// IsRetryableTxError report whether err is a postgres serialization failure or deadlock
+func IsRetryableTxError(err error) bool {
+	var sqlErr interface{ SQLState() string }
+	if !errors.As(err, &sqlErr) {
+		return false
+	}
 
-	return res, err
+	code := sqlErr.SQLState()
+	return code == sqlStateSerializationFailure || code == sqlStateDeadlockDetected
 }
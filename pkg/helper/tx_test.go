@@ -0,0 +1,330 @@
+package helper
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordDriver log every transaction call so tests can check the statements sent to the database
+type recordDriver struct {
+	mu  sync.Mutex
+	log []string
+	// commitErrors are returned by the next commits, one per commit
+	commitErrors []error
+}
+
+func (d *recordDriver) record(entry string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, entry)
+}
+
+func (d *recordDriver) Open(name string) (driver.Conn, error) {
+	return &recordConn{d}, nil
+}
+
+type recordConn struct {
+	driver *recordDriver
+}
+
+func (c *recordConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (c *recordConn) Close() error {
+	return nil
+}
+
+func (c *recordConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *recordConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	entry := "begin"
+	if opts.ReadOnly {
+		entry += " read only"
+	}
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		entry += " " + sql.IsolationLevel(opts.Isolation).String()
+	}
+	c.driver.record(entry)
+
+	return &recordTx{c.driver}, nil
+}
+
+func (c *recordConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.driver.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+type recordTx struct {
+	driver *recordDriver
+}
+
+func (t *recordTx) Commit() error {
+	t.driver.record("commit")
+
+	t.driver.mu.Lock()
+	defer t.driver.mu.Unlock()
+	if len(t.driver.commitErrors) > 0 {
+		err := t.driver.commitErrors[0]
+		t.driver.commitErrors = t.driver.commitErrors[1:]
+		return err
+	}
+
+	return nil
+}
+
+func (t *recordTx) Rollback() error {
+	t.driver.record("rollback")
+	return nil
+}
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+var driverCount int
+
+func openRecordDB(t *testing.T, d *recordDriver) *sql.DB {
+	driverCount++
+	name := fmt.Sprintf("record-%d", driverCount)
+	sql.Register(name, d)
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestWithTx(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name         string
+		opts         []TxOption
+		commitErrors []error
+		fn           func(ctx context.Context, db *sql.DB) (int, error)
+		want         int
+		wantErr      error
+		wantLog      []string
+	}{
+		{
+			name: "commit",
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				return 1, nil
+			},
+			want:    1,
+			wantLog: []string{"begin", "commit"},
+		},
+		{
+			name: "rollback on error",
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				return 0, errFailed
+			},
+			wantErr: errFailed,
+			wantLog: []string{"begin", "rollback"},
+		},
+		{
+			name: "options",
+			opts: []TxOption{ReadOnly(), WithIsolation(sql.LevelSerializable)},
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				return 1, nil
+			},
+			want:    1,
+			wantLog: []string{"begin read only Serializable", "commit"},
+		},
+		{
+			name: "nested use savepoint",
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				return WithTx(ctx, db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+					return 2, nil
+				})
+			},
+			want:    2,
+			wantLog: []string{"begin", "savepoint sp_1", "release savepoint sp_1", "commit"},
+		},
+		{
+			name: "nested error only roll back the savepoint",
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				err := RunTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+					return RunTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+						return errFailed
+					})
+				})
+				if !errors.Is(err, errFailed) {
+					return 0, fmt.Errorf("unexpected error %v", err)
+				}
+
+				return 3, nil
+			},
+			want: 3,
+			wantLog: []string{
+				"begin",
+				"savepoint sp_1", "savepoint sp_2", "rollback to savepoint sp_2", "rollback to savepoint sp_1",
+				"commit",
+			},
+		},
+		{
+			name:         "retry serialization failure",
+			commitErrors: []error{sqlStateError(sqlStateSerializationFailure), sqlStateError(sqlStateDeadlockDetected)},
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				return 1, nil
+			},
+			want:    1,
+			wantLog: []string{"begin", "commit", "begin", "commit", "begin", "commit"},
+		},
+		{
+			name:         "retry stop after max attempts",
+			opts:         []TxOption{WithMaxAttempts(2)},
+			commitErrors: []error{sqlStateError(sqlStateSerializationFailure), sqlStateError(sqlStateSerializationFailure)},
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				return 1, nil
+			},
+			want:    1,
+			wantErr: sqlStateError(sqlStateSerializationFailure),
+			wantLog: []string{"begin", "commit", "begin", "commit"},
+		},
+		{
+			name:         "no retry on other error",
+			commitErrors: []error{sqlStateError("23505")},
+			fn: func(ctx context.Context, db *sql.DB) (int, error) {
+				return 1, nil
+			},
+			want:    1,
+			wantErr: sqlStateError("23505"),
+			wantLog: []string{"begin", "commit"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &recordDriver{commitErrors: tt.commitErrors}
+			db := openRecordDB(t, d)
+
+			got, err := WithTx(context.Background(), db, func(ctx context.Context, tx *sql.Tx) (int, error) {
+				return tt.fn(ctx, db)
+			}, tt.opts...)
+
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("result = %d, want %d", got, tt.want)
+			}
+			if strings.Join(d.log, ", ") != strings.Join(tt.wantLog, ", ") {
+				t.Errorf("log = %v, want %v", d.log, tt.wantLog)
+			}
+		})
+	}
+}
+
+func TestWithTxPanicRollback(t *testing.T) {
+	d := &recordDriver{}
+	db := openRecordDB(t, d)
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("panic not propagated")
+		}
+		if strings.Join(d.log, ", ") != "begin, rollback" {
+			t.Errorf("log = %v", d.log)
+		}
+	}()
+
+	_ = RunTx(context.Background(), db, func(ctx context.Context, tx *sql.Tx) error {
+		panic("boom")
+	})
+}
+
+func TestAfterCommit(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name      string
+		commitErr error
+		fnErr     error
+		wantRun   bool
+	}{
+		{name: "run after commit", wantRun: true},
+		{name: "skipped on rollback", fnErr: errFailed},
+		{name: "skipped when commit fail", commitErr: errFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &recordDriver{}
+			if tt.commitErr != nil {
+				d.commitErrors = []error{tt.commitErr}
+			}
+			db := openRecordDB(t, d)
+
+			ran := false
+			_ = RunTx(context.Background(), db, func(ctx context.Context, tx *sql.Tx) error {
+				// registered in a savepoint, still belong to the outer transaction
+				_ = RunTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+					AfterCommit(ctx, func() {
+						ran = true
+						d.record("callback")
+					})
+					return nil
+				})
+
+				return tt.fnErr
+			})
+
+			if ran != tt.wantRun {
+				t.Fatalf("callback ran = %v, want %v", ran, tt.wantRun)
+			}
+			if tt.wantRun && d.log[len(d.log)-1] != "callback" {
+				t.Errorf("callback must run after commit, log = %v", d.log)
+			}
+		})
+	}
+
+	ran := false
+	AfterCommit(context.Background(), func() { ran = true })
+	if !ran {
+		t.Error("callback without transaction must run right away")
+	}
+}
+
+func TestInReadOnlyTx(t *testing.T) {
+	db := openRecordDB(t, &recordDriver{})
+
+	if InReadOnlyTx(context.Background()) {
+		t.Error("context without transaction is not read only")
+	}
+
+	_ = RunTx(context.Background(), db, func(ctx context.Context, tx *sql.Tx) error {
+		if !InReadOnlyTx(ctx) {
+			t.Error("read only transaction not reported")
+		}
+		return RunTx(ctx, db, func(ctx context.Context, tx *sql.Tx) error {
+			if !InReadOnlyTx(ctx) {
+				t.Error("savepoint must inherit read only")
+			}
+			return nil
+		})
+	}, ReadOnly())
+
+	_ = RunTx(context.Background(), db, func(ctx context.Context, tx *sql.Tx) error {
+		if InReadOnlyTx(ctx) {
+			t.Error("read write transaction reported read only")
+		}
+		return nil
+	})
+}
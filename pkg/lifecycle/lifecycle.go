@@ -0,0 +1,91 @@
+// Package lifecycle start components in registration order and stop them in reverse order,
+// so a component is always stopped before the things it depends on.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrAlreadyStarted = errors.New("lifecycle: already started")
+
+// Hook of one component, both funcs are optional. OnStop is only called when OnStart succeeded.
+type Hook struct {
+	Name    string
+	OnStart func(ctx context.Context) error
+	OnStop  func(ctx context.Context) error
+}
+
+type Lifecycle struct {
+	mu      sync.Mutex
+	hooks   []Hook
+	started int
+	running bool
+}
+
+func New() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Append register a hook, hooks appended after Start are started on the next Start
+func (l *Lifecycle) Append(hook Hook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, hook)
+}
+
+// Start run every OnStart in order, when one fail the already started hooks are stopped
+// in reverse order and the start error is returned
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.running {
+		return ErrAlreadyStarted
+	}
+	l.running = true
+
+	for _, hook := range l.hooks {
+		if hook.OnStart != nil {
+			if err := hook.OnStart(ctx); err != nil {
+				startErr := fmt.Errorf("lifecycle: start %s: %w", hook.Name, err)
+				if stopErr := l.stop(ctx); stopErr != nil {
+					return errors.Join(startErr, stopErr)
+				}
+				return startErr
+			}
+		}
+		l.started++
+	}
+
+	return nil
+}
+
+// Stop run OnStop of every started hook in reverse order, every hook is stopped even when
+// an earlier one fail and all errors are returned together
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stop(ctx)
+}
+
+func (l *Lifecycle) stop(ctx context.Context) error {
+	var errs []error
+	for ; l.started > 0; l.started-- {
+		hook := l.hooks[l.started-1]
+		if hook.OnStop == nil {
+			continue
+		}
+
+		if err := hook.OnStop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("lifecycle: stop %s: %w", hook.Name, err))
+		}
+	}
+	l.running = false
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,77 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLifecycle(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	tests := []struct {
+		name      string
+		failStart string
+		failStop  string
+		wantErr   bool
+		want      []string
+	}{
+		{
+			name: "start in order and stop in reverse",
+			want: []string{"start db", "start worker", "start http", "stop http", "stop worker", "stop db"},
+		},
+		{
+			name:      "failed start stop only started hooks",
+			failStart: "http",
+			wantErr:   true,
+			want:      []string{"start db", "start worker", "start http", "stop worker", "stop db"},
+		},
+		{
+			name:     "failed stop still stop the rest",
+			failStop: "worker",
+			want:     []string{"start db", "start worker", "start http", "stop http", "stop worker", "stop db"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			lc := New()
+			for _, name := range []string{"db", "worker", "http"} {
+				name := name
+				lc.Append(Hook{
+					Name: name,
+					OnStart: func(ctx context.Context) error {
+						calls = append(calls, "start "+name)
+						if name == tt.failStart {
+							return errBoom
+						}
+						return nil
+					},
+					OnStop: func(ctx context.Context) error {
+						calls = append(calls, "stop "+name)
+						if name == tt.failStop {
+							return errBoom
+						}
+						return nil
+					},
+				})
+			}
+
+			err := lc.Start(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Start error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			stopErr := lc.Stop(context.Background())
+			if tt.failStop != "" && !errors.Is(stopErr, errBoom) {
+				t.Errorf("Stop error = %v, want %v", stopErr, errBoom)
+			}
+
+			if !reflect.DeepEqual(calls, tt.want) {
+				t.Errorf("calls = %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Package lockout decide how long attempts (logins, ...) are refused after failures: a delay that
+// double with every failure, then a lockout once a threshold is reached. Counting the failures is
+// left to the caller.
+package lockout
+
+import (
+	"math"
+	"time"
+)
+
+type Policy struct {
+	// DelayAfter failures are allowed without delay, 0 disable the delays
+	DelayAfter int
+	// Delay after the first delayed failure, doubled by each next one up to MaxDelay
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// Threshold failures lock for Duration, 0 disable the lockout
+	Threshold int
+	Duration  time.Duration
+}
+
+// BlockedUntil return when the next attempt is allowed after failures, the last one at lastFailure.
+// The result is before or equal to lastFailure when the attempt is allowed right away.
+func (p Policy) BlockedUntil(failures int, lastFailure time.Time) time.Time {
+	if p.Threshold > 0 && failures >= p.Threshold {
+		return lastFailure.Add(p.Duration)
+	}
+
+	if p.DelayAfter > 0 && failures >= p.DelayAfter && p.Delay > 0 {
+		return lastFailure.Add(p.delay(failures - p.DelayAfter))
+	}
+
+	return lastFailure
+}
+
+// Locked report whether failures reached the lockout threshold
+func (p Policy) Locked(failures int) bool {
+	return p.Threshold > 0 && failures >= p.Threshold
+}
+
+func (p Policy) delay(doublings int) time.Duration {
+	delay := p.Delay
+	// stop doubling before it overflow when there is no max delay
+	for i := 0; i < doublings && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+
+	return delay
+}
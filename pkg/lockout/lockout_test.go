@@ -0,0 +1,61 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlockedUntil(t *testing.T) {
+	last := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	policy := Policy{DelayAfter: 3, Delay: time.Second, MaxDelay: 10 * time.Second, Threshold: 10, Duration: 15 * time.Minute}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		failures int
+		want     time.Duration
+	}{
+		{name: "no failure", policy: policy, failures: 0, want: 0},
+		{name: "before delays", policy: policy, failures: 2, want: 0},
+		{name: "first delay", policy: policy, failures: 3, want: time.Second},
+		{name: "doubled delay", policy: policy, failures: 5, want: 4 * time.Second},
+		{name: "delay capped", policy: policy, failures: 9, want: 10 * time.Second},
+		{name: "locked at threshold", policy: policy, failures: 10, want: 15 * time.Minute},
+		{name: "still locked above threshold", policy: policy, failures: 40, want: 15 * time.Minute},
+		{name: "delays disabled", policy: Policy{Threshold: 5, Duration: time.Minute}, failures: 4, want: 0},
+		{name: "lockout disabled", policy: Policy{DelayAfter: 1, Delay: time.Second, MaxDelay: time.Minute}, failures: 100, want: time.Minute},
+		{name: "no max delay", policy: Policy{DelayAfter: 1, Delay: time.Second}, failures: 4, want: 8 * time.Second},
+		{name: "nothing enabled", policy: Policy{}, failures: 100, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.BlockedUntil(tt.failures, last).Sub(last); got != tt.want {
+				t.Errorf("BlockedUntil = last + %s, want last + %s", got, tt.want)
+			}
+		})
+	}
+
+	// doubling without max delay must not overflow into the past
+	if got := (Policy{DelayAfter: 1, Delay: time.Second}).BlockedUntil(1000, last); !got.After(last) {
+		t.Errorf("BlockedUntil without max delay = %s, want after %s", got, last)
+	}
+}
+
+func TestLocked(t *testing.T) {
+	tests := []struct {
+		policy   Policy
+		failures int
+		want     bool
+	}{
+		{Policy{Threshold: 3}, 2, false},
+		{Policy{Threshold: 3}, 3, true},
+		{Policy{}, 100, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.Locked(tt.failures); got != tt.want {
+			t.Errorf("Locked(%d) with threshold %d = %v, want %v", tt.failures, tt.policy.Threshold, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestContextHandler(t *testing.T) {
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	spanCtx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceId,
+		SpanID:  spanId,
+	}))
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want map[string]string
+	}{
+		{
+			name: "empty context",
+			ctx:  context.Background(),
+			want: map[string]string{"request_id": "", "trace_id": ""},
+		},
+		{
+			name: "request id",
+			ctx:  WithRequestID(context.Background(), "req-1"),
+			want: map[string]string{"request_id": "req-1", "trace_id": ""},
+		},
+		{
+			name: "request id and span",
+			ctx:  WithRequestID(spanCtx, "req-2"),
+			want: map[string]string{"request_id": "req-2", "trace_id": traceId.String(), "span_id": spanId.String()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger, err := New(&buf, FormatJSON, "info")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logger.InfoContext(tt.ctx, "hello")
+
+			var record map[string]interface{}
+			if err = json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("invalid json %q: %v", buf.String(), err)
+			}
+
+			for key, want := range tt.want {
+				got, _ := record[key].(string)
+				if got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("expected error for invalid format")
+	}
+	if _, err := New(&bytes.Buffer{}, FormatText, "verbose"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
@@ -0,0 +1,342 @@
+// Package migrate apply numbered SQL migrations from a fs.FS to postgres.
+//
+// Files are named NNNN_name.up.sql and NNNN_name.down.sql. Applied versions are recorded in the
+// schema_migrations table and every run hold a postgres advisory lock, so instances starting at
+// the same time apply each migration only once.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultLockKey is the advisory lock key used when none is set
+const DefaultLockKey int64 = 7240193548112011
+
+var (
+	ErrNoDownMigration = errors.New("migrate: down migration not found")
+	ErrUnknownVersion  = errors.New("migrate: unknown version")
+)
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	LockKey    int64
+	Table      string
+}
+
+// New read every migration in the root of fsys, a version without up file is an error
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Parse(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		LockKey:    DefaultLockKey,
+		Table:      "schema_migrations",
+	}, nil
+}
+
+// Parse read and sort the migrations found in the root of fsys
+func Parse(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: read migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNamePattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migrate: invalid version in %s", entry.Name())
+		}
+
+		content, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("migrate: read %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d used by %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrate: %s has no up migration", m)
+		}
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrations return the known migrations ordered by version
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Up apply every pending migration and return the applied ones
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	if len(m.migrations) == 0 {
+		return nil, nil
+	}
+
+	return m.To(ctx, m.migrations[len(m.migrations)-1].Version)
+}
+
+// Down roll back the latest applied migration, nothing is done when no migration is applied
+func (m *Migrator) Down(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		var latest int64
+		for version := range applied {
+			if version > latest {
+				latest = version
+			}
+		}
+		if latest == 0 {
+			return nil
+		}
+
+		migration, ok := m.find(latest)
+		if !ok {
+			return fmt.Errorf("%w: %d is applied but has no migration file", ErrUnknownVersion, latest)
+		}
+
+		if err = m.rollback(ctx, conn, migration); err != nil {
+			return err
+		}
+		done = append(done, migration)
+
+		return nil
+	})
+
+	return done, err
+}
+
+// To migrate up or down until version is the latest applied one, version 0 roll back everything
+func (m *Migrator) To(ctx context.Context, version int64) ([]Migration, error) {
+	if _, ok := m.find(version); !ok && version != 0 {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	var done []Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for version := range applied {
+			if _, ok := m.find(version); !ok {
+				return fmt.Errorf("%w: %d is applied but has no migration file", ErrUnknownVersion, version)
+			}
+		}
+
+		// roll back newest first, then apply pending oldest first
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			migration := m.migrations[i]
+			if _, ok := applied[migration.Version]; !ok || migration.Version <= version {
+				continue
+			}
+
+			if err = m.rollback(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := applied[migration.Version]; ok || migration.Version > version {
+				continue
+			}
+
+			if err = m.apply(ctx, conn, migration); err != nil {
+				return err
+			}
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Status list every known migration and when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := Status{Migration: migration}
+			if appliedAt, ok := applied[migration.Version]; ok {
+				status.Applied = true
+				status.AppliedAt = &appliedAt
+			}
+			statuses = append(statuses, status)
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+// withLock run fn on a single connection holding the advisory lock, the lock is session scoped
+// so every statement must use the same connection
+func (m *Migrator) withLock(ctx context.Context, fn func(*sql.Conn) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("migrate: get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err = conn.ExecContext(ctx, "select pg_advisory_lock($1)", m.LockKey); err != nil {
+		return fmt.Errorf("migrate: acquire lock: %w", err)
+	}
+	defer func() {
+		// use background context, the lock must be released even when ctx is cancelled
+		if _, unlockErr := conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", m.LockKey); unlockErr != nil && err == nil {
+			err = fmt.Errorf("migrate: release lock: %w", unlockErr)
+		}
+	}()
+
+	query := fmt.Sprintf("create table if not exists %s (version bigint not null primary key, name varchar(255) not null, applied_at timestamptz not null default current_timestamp)", m.Table)
+	if _, err = conn.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("migrate: create %s: %w", m.Table, err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("select version, applied_at from %s", m.Table))
+	if err != nil {
+		return nil, fmt.Errorf("migrate: read %s: %w", m.Table, err)
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err = rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	return m.inTx(ctx, conn, migration, migration.Up, fmt.Sprintf("insert into %s (version, name) values ($1, $2)", m.Table), migration.Version, migration.Name)
+}
+
+func (m *Migrator) rollback(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	if migration.Down == "" {
+		return fmt.Errorf("%w: %s", ErrNoDownMigration, migration)
+	}
+
+	return m.inTx(ctx, conn, migration, migration.Down, fmt.Sprintf("delete from %s where version = $1", m.Table), migration.Version)
+}
+
+// inTx run the migration script and the bookkeeping statement in one transaction
+func (m *Migrator) inTx(ctx context.Context, conn *sql.Conn, migration Migration, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, script); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migrate: %s: %w", migration, err)
+	}
+
+	if _, err = tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migrate: record %s: %w", migration, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("migrate: commit %s: %w", migration, err)
+	}
+
+	return nil
+}
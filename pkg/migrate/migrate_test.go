@@ -0,0 +1,74 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    fstest.MapFS
+		versions []int64
+		wantErr  bool
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"0002_add_index.up.sql":   {Data: []byte("create index")},
+				"0001_init.up.sql":        {Data: []byte("create table")},
+				"0001_init.down.sql":      {Data: []byte("drop table")},
+				"0010_later.up.sql":       {Data: []byte("select 1")},
+				"README.md":               {Data: []byte("ignored")},
+				"0002_add_index.down.sql": {Data: []byte("drop index")},
+			},
+			versions: []int64{1, 2, 10},
+		},
+		{
+			name: "down without up",
+			files: fstest.MapFS{
+				"0001_init.down.sql": {Data: []byte("drop table")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "same version different name",
+			files: fstest.MapFS{
+				"0001_init.up.sql":  {Data: []byte("create table")},
+				"0001_other.up.sql": {Data: []byte("create table")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "version zero",
+			files: fstest.MapFS{
+				"0000_init.up.sql": {Data: []byte("create table")},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Parse(tt.files)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %v", migrations)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(migrations) != len(tt.versions) {
+				t.Fatalf("got %d migrations, want %d", len(migrations), len(tt.versions))
+			}
+			for i, version := range tt.versions {
+				if migrations[i].Version != version {
+					t.Errorf("migration %d: got version %d, want %d", i, migrations[i].Version, version)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+// Package notify deliver messages to users (password reset links, ...) through a Notifier. The log
+// and file sinks are meant for local development, a real channel (email, sms, ...) implement the
+// same interface.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Message is channel agnostic, the sink decide how to reach the recipient
+type Message struct {
+	// Recipient identify the user, the username for now
+	Recipient string `json:"recipient"`
+	Subject   string `json:"subject"`
+	Body      string `json:"body"`
+	// Data hold the values of the message (link, expiry, ...) for sinks rendering their own template
+	Data map[string]string `json:"data,omitempty"`
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Log write every message to the application log, secrets in the body (reset tokens, ...) included
+type Log struct {
+	logger *slog.Logger
+}
+
+func NewLog(logger *slog.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (l *Log) Notify(ctx context.Context, msg Message) error {
+	attrs := []any{"recipient", msg.Recipient, "subject", msg.Subject, "body", msg.Body}
+	for key, value := range msg.Data {
+		attrs = append(attrs, "data."+key, value)
+	}
+
+	l.logger.InfoContext(ctx, "notification", attrs...)
+	return nil
+}
+
+// File append every message as one json line, the file is created with owner only permissions
+type File struct {
+	mu   sync.Mutex
+	path string
+	now  func() time.Time
+}
+
+func NewFile(path string) *File {
+	return &File{path: path, now: time.Now}
+}
+
+type fileEntry struct {
+	Time time.Time `json:"time"`
+	Message
+}
+
+func (f *File) Notify(ctx context.Context, msg Message) error {
+	// links stay readable, & is not escaped
+	var line bytes.Buffer
+	enc := json.NewEncoder(&line)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fileEntry{Time: f.now().UTC(), Message: msg}); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(line.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	clock := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+
+	f := NewFile(path)
+	f.now = func() time.Time { return clock }
+
+	messages := []Message{
+		{Recipient: "alice", Subject: "reset", Body: "token 1", Data: map[string]string{"token": "1", "url": "http://x/?a=1&token=1"}},
+		{Recipient: "bob", Subject: "reset", Body: "token 2"},
+	}
+	for _, msg := range messages {
+		if err := f.Notify(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("file permission = %o, want 600", perm)
+	}
+
+	var got []fileEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), `\u0026`) {
+			t.Errorf("line %q escape &", scanner.Text())
+		}
+		var entry fileEntry
+		if err = json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, entry)
+	}
+
+	if len(got) != len(messages) {
+		t.Fatalf("got %d lines, want %d", len(got), len(messages))
+	}
+	for i, entry := range got {
+		if !entry.Time.Equal(clock) {
+			t.Errorf("line %d time = %s, want %s", i, entry.Time, clock)
+		}
+		if entry.Recipient != messages[i].Recipient || entry.Body != messages[i].Body || entry.Data["url"] != messages[i].Data["url"] {
+			t.Errorf("line %d = %+v, want %+v", i, entry.Message, messages[i])
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	var buf bytes.Buffer
+	l := NewLog(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	if err := l.Notify(context.Background(), Message{Recipient: "alice", Subject: "reset", Body: "hello", Data: map[string]string{"url": "http://x"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"recipient=alice", "subject=reset", "body=hello", "data.url=http://x"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log %q does not contain %q", buf.String(), want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	argon2SaltSize = 16
+	argon2KeySize  = 32
+)
+
+// Argon2id parameters, see RFC 9106. Memory is in KiB.
+type Argon2id struct {
+	Memory  uint32
+	Time    uint32
+	Threads uint8
+}
+
+// argon2Params is what a PHC string hold besides the algorithm
+type argon2Params struct {
+	version int
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+func (h Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, argon2KeySize)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Time, h.Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func (h Argon2id) Verify(hash, password string) error {
+	return verify(hash, password)
+}
+
+func (h Argon2id) NeedsRehash(hash string) bool {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return p.version != argon2.Version || p.memory != h.Memory || p.time != h.Time || p.threads != h.Threads ||
+		len(p.salt) != argon2SaltSize || len(p.key) != argon2KeySize
+}
+
+func (h Argon2id) validate() error {
+	if h.Time < 1 {
+		return fmt.Errorf("password: argon2id time must be at least 1, got %d", h.Time)
+	}
+	if h.Threads < 1 {
+		return fmt.Errorf("password: argon2id threads must be at least 1, got %d", h.Threads)
+	}
+	// required by argon2 itself
+	if h.Memory < 8*uint32(h.Threads) {
+		return fmt.Errorf("password: argon2id memory must be at least 8 KiB per thread, got %d KiB", h.Memory)
+	}
+
+	return nil
+}
+
+func verifyArgon2id(hash, password string) error {
+	p, err := parseArgon2id(hash)
+	if err != nil {
+		return err
+	}
+	if p.version != argon2.Version {
+		return fmt.Errorf("password: argon2id version %d is not supported", p.version)
+	}
+
+	key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+	if subtle.ConstantTimeCompare(key, p.key) != 1 {
+		return ErrMismatch
+	}
+
+	return nil
+}
+
+// parseArgon2id read $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func parseArgon2id(hash string) (argon2Params, error) {
+	var p argon2Params
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return p, ErrUnknownHash
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &p.version); err != nil {
+		return p, fmt.Errorf("password: argon2id version: %w", err)
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, fmt.Errorf("password: argon2id parameters: %w", err)
+	}
+	if p.time < 1 || p.threads < 1 {
+		return p, fmt.Errorf("password: argon2id parameters %q are not valid", parts[3])
+	}
+
+	var err error
+	if p.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, fmt.Errorf("password: argon2id salt: %w", err)
+	}
+	if p.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return p, fmt.Errorf("password: argon2id hash: %w", err)
+	}
+	if len(p.key) == 0 {
+		return p, fmt.Errorf("password: argon2id hash is empty")
+	}
+
+	return p, nil
+}
@@ -0,0 +1,61 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// BcryptMaxBytes is the longest password bcrypt hash, it count bytes so a multibyte character
+// take up to 4 of them
+const BcryptMaxBytes = 72
+
+type Bcrypt struct {
+	Cost int
+}
+
+// Hash return ErrTooLong over BcryptMaxBytes instead of hashing a truncated password
+func (h Bcrypt) Hash(password string) (string, error) {
+	if len([]byte(password)) > BcryptMaxBytes {
+		return "", ErrTooLong
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hashed), nil
+}
+
+func (h Bcrypt) Verify(hash, password string) error {
+	return verify(hash, password)
+}
+
+// NeedsRehash is also true for a higher cost, lowering the cost is how slow logins are fixed
+func (h Bcrypt) NeedsRehash(hash string) bool {
+	if Algorithm(hash) != AlgorithmBcrypt {
+		return true
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.Cost
+}
+
+func (h Bcrypt) validate() error {
+	if h.Cost < bcrypt.MinCost || h.Cost > bcrypt.MaxCost {
+		return fmt.Errorf("password: bcrypt cost must be between %d and %d, got %d", bcrypt.MinCost, bcrypt.MaxCost, h.Cost)
+	}
+
+	return nil
+}
+
+func verifyBcrypt(hash, password string) error {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrMismatch
+	}
+
+	return err
+}
@@ -0,0 +1,153 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// BreachedList is a bloom filter of leaked passwords keyed by their SHA-1, like the Have I Been
+// Pwned dumps, so the list take a few bits per password and the passwords are never kept. A
+// password not in the list is always accepted, one in the list is refused, and an unlucky good
+// password is refused with the configured false positive rate.
+type BreachedList struct {
+	bits   []uint64
+	size   uint64
+	hashes int
+	count  int
+}
+
+// NewBreachedList size the filter for n passwords with the given false positive rate
+func NewBreachedList(n int, falsePositiveRate float64) *BreachedList {
+	n = max(n, 1)
+
+	size := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	size = max(size, 64)
+	hashes := int(math.Round(float64(size) / float64(n) * math.Ln2))
+
+	return &BreachedList{
+		bits:   make([]uint64, (size+63)/64),
+		size:   size,
+		hashes: max(hashes, 1),
+	}
+}
+
+// LoadBreachedList read a file with one entry per line, either a plain password or the hex SHA-1
+// of one optionally followed by :count (the Have I Been Pwned format). The file is read twice,
+// first to size the filter.
+func LoadBreachedList(path string, falsePositiveRate float64) (*BreachedList, error) {
+	n := 0
+	err := readLines(path, func(string) error {
+		n++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	list := NewBreachedList(n, falsePositiveRate)
+	err = readLines(path, func(line string) error {
+		sum, ok := parseSHA1(line)
+		if !ok {
+			sum = sha1.Sum([]byte(line))
+		}
+		list.add(sum)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Add a plain password to the list
+func (l *BreachedList) Add(password string) {
+	l.add(sha1.Sum([]byte(password)))
+}
+
+// Contains report whether the password is probably in the list, always false for a nil list
+func (l *BreachedList) Contains(password string) bool {
+	if l == nil {
+		return false
+	}
+
+	h1, h2 := l.split(sha1.Sum([]byte(password)))
+	for i := 0; i < l.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % l.size
+		if l.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Len is the number of entries added
+func (l *BreachedList) Len() int {
+	if l == nil {
+		return 0
+	}
+
+	return l.count
+}
+
+func (l *BreachedList) add(sum [sha1.Size]byte) {
+	h1, h2 := l.split(sum)
+	for i := 0; i < l.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % l.size
+		l.bits[bit/64] |= 1 << (bit % 64)
+	}
+	l.count++
+}
+
+// split derive the bit positions by double hashing, the SHA-1 is already uniform
+func (l *BreachedList) split(sum [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(sum[0:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
+
+// parseSHA1 accept "<40 hex>" or "<40 hex>:<count>", in upper or lower case
+func parseSHA1(line string) ([sha1.Size]byte, bool) {
+	var sum [sha1.Size]byte
+
+	hash, _, _ := strings.Cut(line, ":")
+	if len(hash) != hex.EncodedLen(sha1.Size) {
+		return sum, false
+	}
+	if _, err := hex.Decode(sum[:], []byte(hash)); err != nil {
+		return sum, false
+	}
+
+	return sum, true
+}
+
+// readLines call fn with every non empty line, without the line ending
+func readLines(path string, fn func(line string) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("password: breached list: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if err = fn(line); err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("password: breached list %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+// Package password hash and verify user passwords with bcrypt or Argon2id.
+//
+// Argon2id hashes use the PHC string format, e.g. $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+// with unpadded base64 salt and hash. bcrypt hashes keep their own $2a$<cost>$ format, the one
+// every existing row already use. Every hasher verify hashes of both algorithms, so changing the
+// algorithm or its parameters only need NeedsRehash on the next login.
+//
+// Policy check new passwords against length and character class rules, the username and an
+// optional list of leaked passwords.
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+var (
+	ErrMismatch    = errors.New("password: hash and password do not match")
+	ErrUnknownHash = errors.New("password: unknown hash format")
+	ErrTooLong     = errors.New("password: longer than 72 bytes, bcrypt would truncate it")
+)
+
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify return ErrMismatch when the password is wrong, the hash can be of any algorithm
+	Verify(hash, password string) error
+	// NeedsRehash report whether the hash use another algorithm or other parameters than Hash
+	NeedsRehash(hash string) bool
+}
+
+type Config struct {
+	// Algorithm of the new hashes, bcrypt or argon2id
+	Algorithm  string
+	BcryptCost int
+	// Argon2Memory in KiB
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+// New return the hasher of cfg.Algorithm after checking its parameters
+func New(cfg Config) (Hasher, error) {
+	switch cfg.Algorithm {
+	case AlgorithmBcrypt:
+		h := Bcrypt{Cost: cfg.BcryptCost}
+		return h, h.validate()
+	case AlgorithmArgon2id:
+		h := Argon2id{Memory: cfg.Argon2Memory, Time: cfg.Argon2Time, Threads: cfg.Argon2Threads}
+		return h, h.validate()
+	default:
+		return nil, fmt.Errorf("password: algorithm %q is not supported, use bcrypt or argon2id", cfg.Algorithm)
+	}
+}
+
+// Algorithm return the algorithm of a hash, empty when the format is unknown
+func Algorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return AlgorithmBcrypt
+	default:
+		return ""
+	}
+}
+
+// verify dispatch on the format of the hash, the parameters are read from the hash itself
+func verify(hash, password string) error {
+	switch Algorithm(hash) {
+	case AlgorithmBcrypt:
+		return verifyBcrypt(hash, password)
+	case AlgorithmArgon2id:
+		return verifyArgon2id(hash, password)
+	default:
+		return ErrUnknownHash
+	}
+}
@@ -0,0 +1,140 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// cheap parameters, the tests check the format not the strength
+var (
+	testBcrypt   = Bcrypt{Cost: 4}
+	testArgon2id = Argon2id{Memory: 64, Time: 1, Threads: 1}
+)
+
+func TestVerify(t *testing.T) {
+	bcryptHash, err := testBcrypt.Hash("Secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon2Hash, err := testArgon2id.Hash("Secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		hasher   Hasher
+		hash     string
+		password string
+		wantErr  error
+	}{
+		{name: "bcrypt", hasher: testBcrypt, hash: bcryptHash, password: "Secret123"},
+		{name: "bcrypt wrong password", hasher: testBcrypt, hash: bcryptHash, password: "Secret124", wantErr: ErrMismatch},
+		{name: "argon2id", hasher: testArgon2id, hash: argon2Hash, password: "Secret123"},
+		{name: "argon2id wrong password", hasher: testArgon2id, hash: argon2Hash, password: "secret123", wantErr: ErrMismatch},
+		{name: "bcrypt hash with argon2id hasher", hasher: testArgon2id, hash: bcryptHash, password: "Secret123"},
+		{name: "argon2id hash with bcrypt hasher", hasher: testBcrypt, hash: argon2Hash, password: "Secret123"},
+		{name: "other argon2id parameters", hasher: Argon2id{Memory: 128, Time: 2, Threads: 2}, hash: argon2Hash, password: "Secret123"},
+		{name: "unknown format", hasher: testArgon2id, hash: "plain", password: "plain", wantErr: ErrUnknownHash},
+		{name: "argon2i is not argon2id", hasher: testArgon2id, hash: "$argon2i$v=19$m=64,t=1,p=1$c2FsdA$aGFzaA", password: "x", wantErr: ErrUnknownHash},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.hasher.Verify(tt.hash, tt.password); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// reference vector of the PHC string format, from the argon2 cli
+	const reference = "$argon2id$v=19$m=65536,t=2,p=1$c29tZXNhbHQ$CTFhFdXPJO1aFaMaO6Mm5c8y7cJHAph8ArZWb2GRPPc"
+	if err := testArgon2id.Verify(reference, "password"); err != nil {
+		t.Errorf("Verify reference = %v", err)
+	}
+}
+
+func TestBcryptMaxBytes(t *testing.T) {
+	// 36 characters but 72 bytes, then one more
+	if _, err := testBcrypt.Hash(strings.Repeat("é", 36)); err != nil {
+		t.Errorf("Hash of 72 bytes = %v", err)
+	}
+	if _, err := testBcrypt.Hash(strings.Repeat("é", 36) + "x"); !errors.Is(err, ErrTooLong) {
+		t.Errorf("Hash of 73 bytes = %v, want ErrTooLong", err)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcryptHash, err := testBcrypt.Hash("Secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon2Hash, err := testArgon2id.Hash("Secret123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		hasher Hasher
+		hash   string
+		want   bool
+	}{
+		{name: "bcrypt same cost", hasher: testBcrypt, hash: bcryptHash, want: false},
+		{name: "bcrypt higher cost", hasher: Bcrypt{Cost: 5}, hash: bcryptHash, want: true},
+		{name: "bcrypt lower cost", hasher: Bcrypt{Cost: 3}, hash: bcryptHash, want: true},
+		{name: "argon2id same parameters", hasher: testArgon2id, hash: argon2Hash, want: false},
+		{name: "argon2id more memory", hasher: Argon2id{Memory: 128, Time: 1, Threads: 1}, hash: argon2Hash, want: true},
+		{name: "argon2id more time", hasher: Argon2id{Memory: 64, Time: 2, Threads: 1}, hash: argon2Hash, want: true},
+		{name: "bcrypt to argon2id", hasher: testArgon2id, hash: bcryptHash, want: true},
+		{name: "argon2id to bcrypt", hasher: testBcrypt, hash: argon2Hash, want: true},
+		{name: "unknown format", hasher: testArgon2id, hash: "plain", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hasher.NeedsRehash(tt.hash); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{name: "bcrypt", cfg: Config{Algorithm: AlgorithmBcrypt, BcryptCost: 10}, want: AlgorithmBcrypt},
+		{name: "argon2id", cfg: Config{Algorithm: AlgorithmArgon2id, Argon2Memory: 64, Argon2Time: 1, Argon2Threads: 1}, want: AlgorithmArgon2id},
+		{name: "bcrypt cost too low", cfg: Config{Algorithm: AlgorithmBcrypt, BcryptCost: 3}, wantErr: true},
+		{name: "bcrypt cost too high", cfg: Config{Algorithm: AlgorithmBcrypt, BcryptCost: 32}, wantErr: true},
+		{name: "argon2id no time", cfg: Config{Algorithm: AlgorithmArgon2id, Argon2Memory: 64, Argon2Threads: 1}, wantErr: true},
+		{name: "argon2id no threads", cfg: Config{Algorithm: AlgorithmArgon2id, Argon2Memory: 64, Argon2Time: 1}, wantErr: true},
+		{name: "argon2id memory too low", cfg: Config{Algorithm: AlgorithmArgon2id, Argon2Memory: 15, Argon2Time: 1, Argon2Threads: 2}, wantErr: true},
+		{name: "unknown algorithm", cfg: Config{Algorithm: "scrypt"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			hash, err := h.Hash("Secret123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Algorithm(hash); got != tt.want {
+				t.Errorf("Algorithm(%q) = %q, want %q", hash, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// rules reported in Violation.Rule
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleUpper     = "upper"
+	RuleLower     = "lower"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleUsername  = "username"
+	RuleBreached  = "breached"
+)
+
+// Policy is the set of rules a new password must follow, the zero value accept everything
+type Policy struct {
+	// MinLength and MaxLength count characters, not bytes, 0 disable the bound
+	MinLength int
+	MaxLength int
+	// MaxBytes bound the encoded length, e.g. BcryptMaxBytes, 0 disable it
+	MaxBytes int
+	// RequireXxx ask for at least one character of the class
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+	// ForbidUsername refuse a password containing the username, case insensitive
+	ForbidUsername bool
+	// Breached refuse passwords of a leaked password list, nil skip the check
+	Breached *BreachedList
+}
+
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError list every rule a password violate
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+
+	return "password does not meet the policy: " + strings.Join(messages, ", ")
+}
+
+// Check return every violated rule, none when the password is accepted
+func (p Policy) Check(password, username string) []Violation {
+	var violations []Violation
+	add := func(rule, message string) {
+		violations = append(violations, Violation{Rule: rule, Message: message})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		add(RuleMinLength, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		add(RuleMaxLength, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	} else if p.MaxBytes > 0 && len([]byte(password)) > p.MaxBytes {
+		add(RuleMaxLength, fmt.Sprintf("must be at most %d bytes, non ascii characters take several", p.MaxBytes))
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		add(RuleUpper, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		add(RuleLower, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		add(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		add(RuleSymbol, "must contain a symbol")
+	}
+
+	if p.ForbidUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		add(RuleUsername, "must not contain the username")
+	}
+
+	if p.Breached.Contains(password) {
+		add(RuleBreached, "appear in a list of leaked passwords, choose another one")
+	}
+
+	return violations
+}
+
+// Validate return a *PolicyError when the password violate a rule
+func (p Policy) Validate(password, username string) error {
+	if violations := p.Check(password, username); len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+
+	return nil
+}
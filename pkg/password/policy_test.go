@@ -0,0 +1,117 @@
+package password
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	breached := NewBreachedList(2, 0.001)
+	breached.Add("Password1")
+	breached.Add("Qwerty123")
+
+	policy := Policy{
+		MinLength:      8,
+		MaxLength:      20,
+		RequireUpper:   true,
+		RequireLower:   true,
+		RequireDigit:   true,
+		ForbidUsername: true,
+		Breached:       breached,
+	}
+
+	tests := []struct {
+		name     string
+		policy   Policy
+		password string
+		username string
+		want     []string
+	}{
+		{name: "accepted", policy: policy, password: "Correct7Horse", username: "alice", want: nil},
+		{name: "too short", policy: policy, password: "Ab1", want: []string{RuleMinLength}},
+		{name: "too long", policy: policy, password: "Ab1" + strings.Repeat("x", 20), want: []string{RuleMaxLength}},
+		{name: "length in characters", policy: policy, password: "Ab1éééééé", want: nil},
+		{name: "too many bytes", policy: Policy{MaxLength: 72, MaxBytes: BcryptMaxBytes}, password: strings.Repeat("é", 40), want: []string{RuleMaxLength}},
+		{name: "bytes within the bound", policy: Policy{MaxLength: 72, MaxBytes: BcryptMaxBytes}, password: strings.Repeat("é", 36), want: nil},
+		{name: "missing classes", policy: policy, password: "correcthorse", want: []string{RuleUpper, RuleDigit}},
+		{name: "only digits", policy: policy, password: "12345678", want: []string{RuleUpper, RuleLower}},
+		{name: "symbol required", policy: Policy{RequireSymbol: true}, password: "Correct7Horse", want: []string{RuleSymbol}},
+		{name: "symbol present", policy: Policy{RequireSymbol: true}, password: "Correct 7Horse!", want: nil},
+		{name: "contain username", policy: policy, password: "xxAlice2024", username: "alice", want: []string{RuleUsername}},
+		{name: "username allowed", policy: Policy{}, password: "alice", username: "alice", want: nil},
+		{name: "breached", policy: policy, password: "Password1", want: []string{RuleBreached}},
+		{name: "every violation", policy: policy, password: "alice", username: "alice", want: []string{RuleMinLength, RuleUpper, RuleDigit, RuleUsername}},
+		{name: "zero policy", policy: Policy{}, password: "", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range tt.policy.Check(tt.password, tt.username) {
+				if v.Message == "" {
+					t.Errorf("rule %s without message", v.Rule)
+				}
+				got = append(got, v.Rule)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Check = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	var policyErr *PolicyError
+	if err := policy.Validate("short", ""); !errors.As(err, &policyErr) || len(policyErr.Violations) != 3 {
+		t.Errorf("Validate = %v, want a PolicyError with 3 violations", err)
+	}
+	if err := policy.Validate("Correct7Horse", "alice"); err != nil {
+		t.Errorf("Validate = %v, want nil", err)
+	}
+}
+
+func TestLoadBreachedList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := strings.Join([]string{
+		"letmein",
+		"",
+		// SHA-1 of "password", lower case and in the Have I Been Pwned format
+		"5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8",
+		"7C4A8D09CA3762AF61E59520943DC26494F8941B:123",
+		"Dragon1\r",
+	}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := LoadBreachedList(path, 0.0001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.Len() != 4 {
+		t.Errorf("Len = %d, want 4", list.Len())
+	}
+
+	// 7c4a8d... is the SHA-1 of "123456"
+	for _, password := range []string{"letmein", "password", "123456", "Dragon1"} {
+		if !list.Contains(password) {
+			t.Errorf("Contains(%q) = false, want true", password)
+		}
+	}
+	for _, password := range []string{"Correct7Horse", "Letmein", "dragon1", ""} {
+		if list.Contains(password) {
+			t.Errorf("Contains(%q) = true, want false", password)
+		}
+	}
+
+	var nilList *BreachedList
+	if nilList.Contains("password") || nilList.Len() != 0 {
+		t.Error("nil list must be empty")
+	}
+
+	if _, err = LoadBreachedList(filepath.Join(t.TempDir(), "missing.txt"), 0.001); err == nil {
+		t.Error("missing file loaded")
+	}
+}
@@ -0,0 +1,163 @@
+// Package ratelimit count requests per key with a sliding window counter: the requests of the
+// current fixed window (aligned on the clock, so every instance sharing a Store such as Redis count
+// in the same window) plus those of the previous window weighted by the part of it still inside the
+// sliding window. A client can not send twice the limit across a window boundary, and only two
+// counters are kept per key. Counters of a single instance can live in Memory.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Store interface {
+	// Increment add one to the counter of key and return its new value with the value of the
+	// counter of previous (0 when missing), the counter of key expire after ttl
+	Increment(ctx context.Context, key, previous string, ttl time.Duration) (count, previousCount int64, err error)
+}
+
+// Policy allow Limit requests per Window, Name keep the counters of policies apart
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until every request made so far left the sliding window
+	Reset time.Duration
+	// RetryAfter is the time until one more request is allowed when no other is made, 0 while
+	// Remaining is not
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func New(store Store) *Limiter {
+	return &Limiter{
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Allow count one request of key against the policy, rejected requests are counted too so a client
+// retrying in a loop does not get through earlier
+func (l *Limiter) Allow(ctx context.Context, policy Policy, key string) (Result, error) {
+	now := l.now()
+	start := now.Truncate(policy.Window)
+	elapsed := now.Sub(start)
+
+	// the counter is read again as the previous one during the next window
+	prefix := policy.Name + ":" + key + ":"
+	count, previous, err := l.store.Increment(ctx,
+		prefix+strconv.FormatInt(start.UnixMilli(), 10),
+		prefix+strconv.FormatInt(start.Add(-policy.Window).UnixMilli(), 10),
+		2*policy.Window-elapsed)
+	if err != nil {
+		return Result{}, err
+	}
+
+	estimate := float64(previous)*weight(policy.Window-elapsed, policy.Window) + float64(count)
+	remaining := max(int(math.Floor(float64(policy.Limit)-estimate)), 0)
+
+	result := Result{
+		Allowed:   estimate <= float64(policy.Limit),
+		Limit:     policy.Limit,
+		Remaining: remaining,
+		Reset:     2*policy.Window - elapsed,
+	}
+	if remaining == 0 {
+		result.RetryAfter = retryAfter(policy, elapsed, count, previous)
+	}
+
+	return result, nil
+}
+
+// weight is the part of the window still inside the sliding window
+func weight(left, window time.Duration) float64 {
+	return float64(left) / float64(window)
+}
+
+// retryAfter find when previous*weight+count+1 fit under the limit, in the current window while
+// count+1 does, otherwise in the next one where count become the previous counter
+func retryAfter(policy Policy, elapsed time.Duration, count, previous int64) time.Duration {
+	limit := int64(policy.Limit)
+
+	if count+1 <= limit {
+		// previous > 0 here, otherwise remaining would not be 0
+		at := policy.Window - time.Duration(float64(policy.Window)*float64(limit-count-1)/float64(previous))
+		return max(at-elapsed, 0)
+	}
+
+	at := policy.Window - time.Duration(float64(policy.Window)*float64(limit-1)/float64(count))
+	return policy.Window - elapsed + at
+}
+
+// sweepInterval bound how often Memory drop expired counters
+const sweepInterval = time.Minute
+
+// Memory keep the counters of a single instance
+type Memory struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		counters: make(map[string]*memoryCounter),
+		now:      time.Now,
+	}
+}
+
+func (m *Memory) Increment(ctx context.Context, key, previous string, ttl time.Duration) (int64, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.After(m.nextSweep) {
+		for k, counter := range m.counters {
+			if !now.Before(counter.expiresAt) {
+				delete(m.counters, k)
+			}
+		}
+		m.nextSweep = now.Add(sweepInterval)
+	}
+
+	counter, ok := m.counters[key]
+	if !ok || !now.Before(counter.expiresAt) {
+		counter = &memoryCounter{expiresAt: now.Add(ttl)}
+		m.counters[key] = counter
+	}
+	counter.count++
+
+	var previousCount int64
+	if prev, ok := m.counters[previous]; ok && now.Before(prev.expiresAt) {
+		previousCount = prev.count
+	}
+
+	return counter.count, previousCount, nil
+}
+
+// Len return the number of counters, expired ones included until they are swept
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.counters)
+}
@@ -0,0 +1,124 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	policy := Policy{Name: "test", Limit: 2, Window: time.Minute}
+
+	type request struct {
+		// at is the time since the start of a window
+		at             time.Duration
+		key            string
+		wantAllowed    bool
+		wantRemaining  int
+		wantReset      time.Duration
+		wantRetryAfter time.Duration
+	}
+
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "allow up to the limit",
+			requests: []request{
+				{at: 0, key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Minute},
+				{at: 10 * time.Second, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 110 * time.Second, wantRetryAfter: 80 * time.Second},
+				{at: 20 * time.Second, key: "a", wantAllowed: false, wantRemaining: 0, wantReset: 100 * time.Second, wantRetryAfter: 80 * time.Second},
+			},
+		},
+		{
+			name: "keys are counted apart",
+			requests: []request{
+				{at: 0, key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Minute},
+				{at: 0, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 2 * time.Minute, wantRetryAfter: 90 * time.Second},
+				{at: 0, key: "b", wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Minute},
+			},
+		},
+		{
+			name: "burst across the window boundary is refused",
+			requests: []request{
+				{at: 59 * time.Second, key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 61 * time.Second},
+				{at: 59 * time.Second, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 61 * time.Second, wantRetryAfter: 31 * time.Second},
+				{at: 60 * time.Second, key: "a", wantAllowed: false, wantRemaining: 0, wantReset: 2 * time.Minute, wantRetryAfter: time.Minute},
+			},
+		},
+		{
+			name: "retry after is enough",
+			requests: []request{
+				{at: 50 * time.Second, key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 70 * time.Second},
+				{at: 55 * time.Second, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 65 * time.Second, wantRetryAfter: 35 * time.Second},
+				{at: 61 * time.Second, key: "a", wantAllowed: false, wantRemaining: 0, wantReset: 119 * time.Second, wantRetryAfter: 59 * time.Second},
+				{at: 120 * time.Second, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 2 * time.Minute, wantRetryAfter: time.Minute},
+			},
+		},
+		{
+			name: "previous window fade out",
+			requests: []request{
+				{at: 0, key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Minute},
+				{at: 10 * time.Second, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 110 * time.Second, wantRetryAfter: 80 * time.Second},
+				{at: 90 * time.Second, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 90 * time.Second, wantRetryAfter: 30 * time.Second},
+				{at: 105 * time.Second, key: "a", wantAllowed: false, wantRemaining: 0, wantReset: 75 * time.Second, wantRetryAfter: 45 * time.Second},
+			},
+		},
+		{
+			name: "previous counter expire",
+			requests: []request{
+				{at: 0, key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Minute},
+				{at: 0, key: "a", wantAllowed: true, wantRemaining: 0, wantReset: 2 * time.Minute, wantRetryAfter: 90 * time.Second},
+				{at: 2 * time.Minute, key: "a", wantAllowed: true, wantRemaining: 1, wantReset: 2 * time.Minute},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			clock := start
+
+			store := NewMemory()
+			store.now = func() time.Time { return clock }
+			limiter := New(store)
+			limiter.now = func() time.Time { return clock }
+
+			for i, r := range tt.requests {
+				clock = start.Add(r.at)
+
+				result, err := limiter.Allow(ctx, policy, r.key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != r.wantAllowed || result.Remaining != r.wantRemaining || result.Reset != r.wantReset || result.RetryAfter != r.wantRetryAfter {
+					t.Errorf("request %d = allowed %v, remaining %d, reset %v, retry after %v, want %v, %d, %v, %v",
+						i, result.Allowed, result.Remaining, result.Reset, result.RetryAfter, r.wantAllowed, r.wantRemaining, r.wantReset, r.wantRetryAfter)
+				}
+				if result.Limit != policy.Limit {
+					t.Errorf("request %d limit = %d, want %d", i, result.Limit, policy.Limit)
+				}
+			}
+		})
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	ctx := context.Background()
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	m := NewMemory()
+	m.now = func() time.Time { return clock }
+
+	m.Increment(ctx, "a", "", time.Second)
+	m.Increment(ctx, "b", "", time.Hour)
+
+	clock = clock.Add(2 * sweepInterval)
+	m.Increment(ctx, "c", "", time.Second)
+
+	if m.Len() != 2 {
+		t.Errorf("len = %d, want 2 (expired counter swept)", m.Len())
+	}
+}
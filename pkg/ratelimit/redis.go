@@ -0,0 +1,47 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis share the counters between every instance, keys are prefixed so several applications can
+// share one database
+type Redis struct {
+	Client redis.UniversalClient
+	Prefix string
+}
+
+func NewRedis(client redis.UniversalClient, prefix string) *Redis {
+	return &Redis{
+		Client: client,
+		Prefix: prefix,
+	}
+}
+
+func (r *Redis) Increment(ctx context.Context, key, previous string, ttl time.Duration) (int64, int64, error) {
+	key = r.Prefix + key
+	// ttl is the time left until the end of the next window, so setting it again on every increment
+	// does not push the end (and does not need PEXPIRE NX of redis 7)
+	var incr *redis.IntCmd
+	var get *redis.StringCmd
+	_, err := r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.PExpire(ctx, key, ttl)
+		get = pipe.Get(ctx, r.Prefix+previous)
+		return nil
+	})
+	// a missing previous counter is redis.Nil, read as 0
+	if err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
+
+	previousCount, err := get.Int64()
+	if err != nil && err != redis.Nil {
+		return 0, 0, err
+	}
+
+	return incr.Val(), previousCount, nil
+}
@@ -0,0 +1,100 @@
+// Package secretbox encrypt small secrets stored at rest (TOTP keys, ...) with AES-256-GCM. A nil
+// Box store values as is, sealed values are prefixed so both kinds can be read back.
+package secretbox
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	KeySize = 32
+	prefix  = "v1:"
+)
+
+var ErrNoKey = errors.New("secretbox: value is sealed but no key is configured")
+
+type Box struct {
+	aead cipher.AEAD
+}
+
+// New create a box from a 32 bytes key
+func New(key []byte) (*Box, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("secretbox: key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{aead: aead}, nil
+}
+
+// NewFromBase64 create a box from a base64 (standard encoding) key, an empty key return a nil box
+func NewFromBase64(key string) (*Box, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return nil, fmt.Errorf("secretbox: decode key: %w", err)
+	}
+
+	return New(raw)
+}
+
+func (b *Box) Seal(plaintext string) (string, error) {
+	if b == nil {
+		return plaintext, nil
+	}
+
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return prefix + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open return values stored before a key was configured unchanged
+func (b *Box) Open(value string) (string, error) {
+	encoded, sealed := strings.CutPrefix(value, prefix)
+	if !sealed {
+		return value, nil
+	}
+	if b == nil {
+		return "", ErrNoKey
+	}
+
+	raw, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("secretbox: decode value: %w", err)
+	}
+
+	nonceSize := b.aead.NonceSize()
+	if len(raw) < nonceSize {
+		return "", errors.New("secretbox: value too short")
+	}
+
+	plaintext, err := b.aead.Open(nil, raw[:nonceSize], raw[nonceSize:], nil)
+	if err != nil {
+		return "", fmt.Errorf("secretbox: open value: %w", err)
+	}
+
+	return string(plaintext), nil
+}
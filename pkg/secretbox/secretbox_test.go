@@ -0,0 +1,69 @@
+package secretbox
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBox(t *testing.T) {
+	box, err := New(bytes.Repeat([]byte{1}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherBox, err := New(bytes.Repeat([]byte{2}, KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := box.Seal("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(sealed, prefix) || strings.Contains(sealed, "secret") {
+		t.Fatalf("sealed value %q", sealed)
+	}
+
+	// change a character in the middle, the last one may only hold padding bits
+	i := len(sealed) / 2
+	changed := byte('A')
+	if sealed[i] == 'A' {
+		changed = 'B'
+	}
+	tampered := sealed[:i] + string(changed) + sealed[i+1:]
+
+	tests := []struct {
+		name    string
+		box     *Box
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{name: "open sealed", box: box, value: sealed, want: "secret"},
+		{name: "plain value unchanged", box: box, value: "plain", want: "plain"},
+		{name: "nil box plain value", box: nil, value: "plain", want: "plain"},
+		{name: "nil box sealed value", box: nil, value: sealed, wantErr: true},
+		{name: "wrong key", box: otherBox, value: sealed, wantErr: true},
+		{name: "tampered", box: box, value: tampered, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.box.Open(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Open = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err = (*Box)(nil).Open(sealed); !errors.Is(err, ErrNoKey) {
+		t.Errorf("nil box error = %v, want ErrNoKey", err)
+	}
+	if _, err = New([]byte("short")); err == nil {
+		t.Error("short key must be refused")
+	}
+}
@@ -0,0 +1,155 @@
+package tokensigner
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Key is one signing or verification key identified by kid.
+// Private key only needed for the key used to sign, verification only need the public part (or the secret for HS256).
+type Key struct {
+	Id         string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey crypto.PrivateKey
+	PublicKey  crypto.PublicKey
+}
+
+type KeyConfig struct {
+	Id             string
+	Algorithm      string
+	Secret         string
+	PrivateKeyFile string
+	PublicKeyFile  string
+}
+
+// LoadKey build Key from config, HS256 use Secret and RS256/EdDSA read PEM files.
+// For asymmetric key the public key is derived from the private key when PublicKeyFile is empty.
+func LoadKey(cfg KeyConfig) (Key, error) {
+	key := Key{
+		Id:        cfg.Id,
+		Algorithm: cfg.Algorithm,
+	}
+
+	if key.Id == "" {
+		return key, errors.New("tokensigner: key id is required")
+	}
+
+	switch cfg.Algorithm {
+	case AlgorithmHS256:
+		if cfg.Secret == "" {
+			return key, fmt.Errorf("tokensigner: key %s: secret is required for HS256", cfg.Id)
+		}
+		key.Secret = []byte(cfg.Secret)
+
+	case AlgorithmRS256:
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+			key.PrivateKey = private
+			key.PublicKey = &private.PublicKey
+		}
+
+		if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+
+			public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+			key.PublicKey = public
+		}
+
+	case AlgorithmEdDSA:
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+			key.PrivateKey = private
+			key.PublicKey = private.(ed25519.PrivateKey).Public()
+		}
+
+		if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+
+			public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return key, fmt.Errorf("tokensigner: key %s: %w", cfg.Id, err)
+			}
+			key.PublicKey = public
+		}
+
+	default:
+		return key, fmt.Errorf("tokensigner: key %s: unsupported algorithm %q", cfg.Id, cfg.Algorithm)
+	}
+
+	if cfg.Algorithm != AlgorithmHS256 && key.PublicKey == nil {
+		return key, fmt.Errorf("tokensigner: key %s: private or public key file is required for %s", cfg.Id, cfg.Algorithm)
+	}
+
+	return key, nil
+}
+
+func (k Key) method() jwt.SigningMethod {
+	return jwt.GetSigningMethod(k.Algorithm)
+}
+
+func (k Key) signingKey() interface{} {
+	if k.Algorithm == AlgorithmHS256 {
+		return k.Secret
+	}
+
+	return k.PrivateKey
+}
+
+func (k Key) verificationKey() interface{} {
+	if k.Algorithm == AlgorithmHS256 {
+		return k.Secret
+	}
+
+	return k.PublicKey
+}
+
+func (k Key) canSign() bool {
+	if k.Algorithm == AlgorithmHS256 {
+		return len(k.Secret) > 0
+	}
+
+	switch k.PrivateKey.(type) {
+	case *rsa.PrivateKey, ed25519.PrivateKey:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,148 @@
+package tokensigner
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey        = errors.New("tokensigner: unknown key id")
+	ErrAlgorithmMismatch = errors.New("tokensigner: token algorithm does not match key")
+)
+
+type Config struct {
+	SigningKey KeyConfig
+	// VerificationKeys are extra keys still accepted for verification, e.g. the previous key while rotating
+	VerificationKeys []KeyConfig
+	Validation       ValidationConfig
+}
+
+// ValidationConfig pin what Parse accept, empty AllowedAlgorithms mean the algorithms of the registered keys
+type ValidationConfig struct {
+	Issuer            string
+	Audience          string
+	AllowedAlgorithms []string
+	Leeway            time.Duration
+}
+
+// Signer sign token with one active key and verify token with any of the registered keys (by kid header)
+type Signer struct {
+	signingKey Key
+	keys       map[string]Key
+	keyOrder   []string
+	validation ValidationConfig
+}
+
+func New(validation ValidationConfig, signingKey Key, verificationKeys ...Key) (*Signer, error) {
+	if !signingKey.canSign() {
+		return nil, fmt.Errorf("tokensigner: key %s can not be used for signing", signingKey.Id)
+	}
+
+	s := &Signer{
+		signingKey: signingKey,
+		keys:       make(map[string]Key),
+		validation: validation,
+	}
+
+	for _, key := range append([]Key{signingKey}, verificationKeys...) {
+		if _, ok := s.keys[key.Id]; ok {
+			return nil, fmt.Errorf("tokensigner: duplicate key id %s", key.Id)
+		}
+
+		s.keys[key.Id] = key
+		s.keyOrder = append(s.keyOrder, key.Id)
+
+		if len(validation.AllowedAlgorithms) == 0 && !slices.Contains(s.validation.AllowedAlgorithms, key.Algorithm) {
+			s.validation.AllowedAlgorithms = append(s.validation.AllowedAlgorithms, key.Algorithm)
+		}
+	}
+
+	// a key that can never pass validation is a configuration mistake
+	for _, kid := range s.keyOrder {
+		if !slices.Contains(s.validation.AllowedAlgorithms, s.keys[kid].Algorithm) {
+			return nil, fmt.Errorf("tokensigner: key %s algorithm %s is not in allowed algorithms", kid, s.keys[kid].Algorithm)
+		}
+	}
+
+	return s, nil
+}
+
+func NewFromConfig(cfg Config) (*Signer, error) {
+	signingKey, err := LoadKey(cfg.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var verificationKeys []Key
+	for _, keyCfg := range cfg.VerificationKeys {
+		key, err := LoadKey(keyCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		verificationKeys = append(verificationKeys, key)
+	}
+
+	return New(cfg.Validation, signingKey, verificationKeys...)
+}
+
+func (s *Signer) Issuer() string {
+	return s.validation.Issuer
+}
+
+func (s *Signer) Audience() string {
+	return s.validation.Audience
+}
+
+// Sign sign the claims with the active signing key and set the kid header
+func (s *Signer) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signingKey.method(), claims)
+	token.Header["kid"] = s.signingKey.Id
+
+	return token.SignedString(s.signingKey.signingKey())
+}
+
+// Keyfunc select verification key from kid header, token without kid is checked against the signing key
+func (s *Signer) Keyfunc(token *jwt.Token) (interface{}, error) {
+	key := s.signingKey
+
+	if kid, ok := token.Header["kid"]; ok {
+		kidStr, ok := kid.(string)
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+
+		if key, ok = s.keys[kidStr]; !ok {
+			return nil, ErrUnknownKey
+		}
+	}
+
+	// never let the token choose another algorithm than the key is made for
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrAlgorithmMismatch
+	}
+
+	return key.verificationKey(), nil
+}
+
+// Parse verify signature and validate algorithm, exp, iat, nbf and the configured issuer/audience
+func (s *Signer) Parse(tokenString string, claims jwt.Claims, options ...jwt.ParserOption) (*jwt.Token, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(s.validation.AllowedAlgorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(s.validation.Leeway),
+	}
+	if s.validation.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(s.validation.Issuer))
+	}
+	if s.validation.Audience != "" {
+		opts = append(opts, jwt.WithAudience(s.validation.Audience))
+	}
+
+	return jwt.ParseWithClaims(tokenString, claims, s.Keyfunc, append(opts, options...)...)
+}
@@ -0,0 +1,411 @@
+package tokensigner
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testKeys(t *testing.T) (Key, Key, Key) {
+	t.Helper()
+
+	rsaPrivate, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hs := Key{Id: "hs", Algorithm: AlgorithmHS256, Secret: []byte("0123456789abcdef0123456789abcdef")}
+	rs := Key{Id: "rs", Algorithm: AlgorithmRS256, PrivateKey: rsaPrivate, PublicKey: &rsaPrivate.PublicKey}
+	ed := Key{Id: "ed", Algorithm: AlgorithmEdDSA, PrivateKey: edPrivate, PublicKey: edPublic}
+
+	return hs, rs, ed
+}
+
+func testClaims(issuer, audience string, exp time.Time) *jwt.RegisteredClaims {
+	return &jwt.RegisteredClaims{
+		Subject:   "42",
+		Issuer:    issuer,
+		Audience:  jwt.ClaimStrings{audience},
+		IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(exp),
+	}
+}
+
+// publicOnly keep what a verifier of another service would have
+func publicOnly(key Key) Key {
+	key.PrivateKey = nil
+	return key
+}
+
+func TestSignParse(t *testing.T) {
+	hs, rs, ed := testKeys(t)
+	validation := ValidationConfig{Issuer: "api", Audience: "web"}
+	later := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		key     Key
+		claims  *jwt.RegisteredClaims
+		tamper  bool
+		wantErr error
+	}{
+		{name: "HS256", key: hs, claims: testClaims("api", "web", later)},
+		{name: "RS256", key: rs, claims: testClaims("api", "web", later)},
+		{name: "EdDSA", key: ed, claims: testClaims("api", "web", later)},
+		{name: "expired", key: rs, claims: testClaims("api", "web", time.Now().Add(-time.Second)), wantErr: jwt.ErrTokenExpired},
+		{name: "wrong issuer", key: ed, claims: testClaims("other", "web", later), wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "wrong audience", key: hs, claims: testClaims("api", "mobile", later), wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "without exp", key: hs, claims: &jwt.RegisteredClaims{Issuer: "api", Audience: jwt.ClaimStrings{"web"}}, wantErr: jwt.ErrTokenRequiredClaimMissing},
+		{name: "tampered signature", key: ed, claims: testClaims("api", "web", later), tamper: true, wantErr: jwt.ErrTokenSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signer, err := New(validation, tt.key)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := signer.Sign(tt.claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.tamper {
+				// flip a bit of the first byte of the 64 bytes Ed25519 signature, the last character may only hold padding bits
+				sig := token[len(token)-len(base64.RawURLEncoding.EncodeToString(make([]byte, 64))):]
+				raw, err := base64.RawURLEncoding.DecodeString(sig)
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw[0] ^= 1
+				token = token[:len(token)-len(sig)] + base64.RawURLEncoding.EncodeToString(raw)
+			}
+
+			claims := new(jwt.RegisteredClaims)
+			parsed, err := signer.Parse(token, claims)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if parsed.Header["kid"] != tt.key.Id {
+				t.Errorf("kid = %v, want %s", parsed.Header["kid"], tt.key.Id)
+			}
+			if claims.Subject != "42" {
+				t.Errorf("subject = %q, want 42", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	_, rsOld, edNew := testKeys(t)
+	rsOld.Id = "old"
+	edNew.Id = "new"
+	_, unknown, _ := testKeys(t)
+	unknown.Id = "unknown"
+
+	oldSigner, err := New(ValidationConfig{}, rsOld)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// after the rotation the old key is only kept to verify the tokens it signed
+	newSigner, err := New(ValidationConfig{}, edNew, publicOnly(rsOld))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknownSigner, err := New(ValidationConfig{}, unknown)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	sign := func(s *Signer) string {
+		token, err := s.Sign(testClaims("", "", later))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	// token without kid, only accepted with the algorithm of the signing key
+	withoutKid := func(key Key) string {
+		token, err := jwt.NewWithClaims(key.method(), testClaims("", "", later)).SignedString(key.signingKey())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		token   string
+		wantErr error
+	}{
+		{name: "new key", signer: newSigner, token: sign(newSigner)},
+		{name: "old key kept for verification", signer: newSigner, token: sign(oldSigner)},
+		{name: "algorithm of the new key not allowed by the old signer", signer: oldSigner, token: sign(newSigner), wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "unknown kid", signer: newSigner, token: sign(unknownSigner), wantErr: ErrUnknownKey},
+		{name: "without kid use the signing key", signer: newSigner, token: withoutKid(edNew)},
+		{name: "without kid signed by another key", signer: oldSigner, token: withoutKid(unknown), wantErr: jwt.ErrTokenSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.signer.Parse(tt.token, new(jwt.RegisteredClaims)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyfunc(t *testing.T) {
+	hs, rs, ed := testKeys(t)
+
+	signer, err := New(ValidationConfig{}, rs, publicOnly(ed), hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		method  jwt.SigningMethod
+		header  map[string]interface{}
+		wantKey interface{}
+		wantErr error
+	}{
+		{name: "RS256 key", method: jwt.SigningMethodRS256, header: map[string]interface{}{"kid": "rs"}, wantKey: rs.PublicKey},
+		{name: "EdDSA key", method: jwt.SigningMethodEdDSA, header: map[string]interface{}{"kid": "ed"}, wantKey: ed.PublicKey},
+		{name: "HS256 key", method: jwt.SigningMethodHS256, header: map[string]interface{}{"kid": "hs"}, wantKey: hs.Secret},
+		{name: "no kid use the signing key", method: jwt.SigningMethodRS256, header: map[string]interface{}{}, wantKey: rs.PublicKey},
+		// the classic confusion: the RSA public key used as HMAC secret
+		{name: "HS256 with the RSA kid", method: jwt.SigningMethodHS256, header: map[string]interface{}{"kid": "rs"}, wantErr: ErrAlgorithmMismatch},
+		{name: "HS256 without kid", method: jwt.SigningMethodHS256, header: map[string]interface{}{}, wantErr: ErrAlgorithmMismatch},
+		{name: "RS256 with the EdDSA kid", method: jwt.SigningMethodRS256, header: map[string]interface{}{"kid": "ed"}, wantErr: ErrAlgorithmMismatch},
+		{name: "none algorithm", method: jwt.SigningMethodNone, header: map[string]interface{}{"kid": "rs"}, wantErr: ErrAlgorithmMismatch},
+		{name: "unknown kid", method: jwt.SigningMethodRS256, header: map[string]interface{}{"kid": "other"}, wantErr: ErrUnknownKey},
+		{name: "kid not a string", method: jwt.SigningMethodRS256, header: map[string]interface{}{"kid": 1}, wantErr: ErrUnknownKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := signer.Keyfunc(&jwt.Token{Method: tt.method, Header: tt.header})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Keyfunc error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(key, tt.wantKey) {
+				t.Errorf("Keyfunc returned another key")
+			}
+		})
+	}
+
+	// end to end: a token forged with the public key as HMAC secret is refused
+	publicDER, err := x509.MarshalPKIXPublicKey(rs.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims("", "", time.Now().Add(time.Hour)))
+	forged.Header["kid"] = "rs"
+	token, err := forged.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = signer.Parse(token, new(jwt.RegisteredClaims)); err == nil {
+		t.Error("token forged with the RSA public key as HMAC secret accepted")
+	}
+}
+
+func TestNew(t *testing.T) {
+	hs, rs, ed := testKeys(t)
+
+	tests := []struct {
+		name       string
+		validation ValidationConfig
+		signing    Key
+		others     []Key
+		wantErr    bool
+	}{
+		{name: "signing key only", signing: rs},
+		{name: "with verification keys", signing: ed, others: []Key{publicOnly(rs), hs}},
+		{name: "public key can not sign", signing: publicOnly(ed), wantErr: true},
+		{name: "empty secret can not sign", signing: Key{Id: "hs", Algorithm: AlgorithmHS256}, wantErr: true},
+		{name: "duplicate kid", signing: rs, others: []Key{{Id: "rs", Algorithm: AlgorithmEdDSA, PublicKey: ed.PublicKey}}, wantErr: true},
+		{name: "key outside allowed algorithms", validation: ValidationConfig{AllowedAlgorithms: []string{AlgorithmRS256}}, signing: rs, others: []Key{publicOnly(ed)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.validation, tt.signing, tt.others...); (err != nil) != tt.wantErr {
+				t.Errorf("New error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// strict verifiers only accept integer NumericDate, New must not change the precision of jwt
+func TestSecondPrecision(t *testing.T) {
+	hs, _, _ := testKeys(t)
+
+	signer, err := New(ValidationConfig{}, hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	token, err := signer.Sign(&jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(now), ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`{"exp":%d,"iat":%d}`, now.Add(time.Hour).Unix(), now.Unix())
+	if string(payload) != want {
+		t.Errorf("payload = %s, want %s", payload, want)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	hs, rs, ed := testKeys(t)
+
+	signer, err := New(ValidationConfig{}, rs, publicOnly(ed), hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := signer.JWKS()
+
+	// HS256 secret never published, keys in registration order
+	if len(jwks.Keys) != 2 {
+		t.Fatalf("JWKS has %d keys, want 2: %+v", len(jwks.Keys), jwks.Keys)
+	}
+
+	rsaJWK, edJWK := jwks.Keys[0], jwks.Keys[1]
+	if rsaJWK.Kty != "RSA" || rsaJWK.Alg != AlgorithmRS256 || rsaJWK.Kid != "rs" || rsaJWK.Use != "sig" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+	n, err := base64.RawURLEncoding.DecodeString(rsaJWK.N)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := base64.RawURLEncoding.DecodeString(rsaJWK.E)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaPublic := rs.PublicKey.(*rsa.PublicKey)
+	if new(big.Int).SetBytes(n).Cmp(rsaPublic.N) != 0 || new(big.Int).SetBytes(e).Int64() != int64(rsaPublic.E) {
+		t.Error("RSA JWK n or e does not match the public key")
+	}
+
+	if edJWK.Kty != "OKP" || edJWK.Crv != "Ed25519" || edJWK.Alg != AlgorithmEdDSA || edJWK.Kid != "ed" || edJWK.Use != "sig" {
+		t.Errorf("Ed25519 JWK = %+v", edJWK)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(edJWK.X)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.PublicKey(x).Equal(ed.PublicKey) {
+		t.Error("Ed25519 JWK x does not match the public key")
+	}
+
+	hsOnly, err := New(ValidationConfig{}, hs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := hsOnly.JWKS().Keys; keys == nil || len(keys) != 0 {
+		t.Errorf("HS256 only JWKS = %+v, want an empty list", keys)
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	_, rs, ed := testKeys(t)
+	dir := t.TempDir()
+
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	marshalPrivate := func(key interface{}) []byte {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	marshalPublic := func(key interface{}) []byte {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+
+	rsPrivateFile := writePEM("rs.pem", "PRIVATE KEY", marshalPrivate(rs.PrivateKey))
+	rsPublicFile := writePEM("rs.pub.pem", "PUBLIC KEY", marshalPublic(rs.PublicKey))
+	edPrivateFile := writePEM("ed.pem", "PRIVATE KEY", marshalPrivate(ed.PrivateKey))
+	edPublicFile := writePEM("ed.pub.pem", "PUBLIC KEY", marshalPublic(ed.PublicKey))
+
+	tests := []struct {
+		name     string
+		cfg      KeyConfig
+		wantSign bool
+		wantErr  bool
+	}{
+		{name: "HS256", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmHS256, Secret: "secret"}, wantSign: true},
+		{name: "RS256 private", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmRS256, PrivateKeyFile: rsPrivateFile}, wantSign: true},
+		{name: "RS256 public only", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmRS256, PublicKeyFile: rsPublicFile}},
+		{name: "EdDSA private", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmEdDSA, PrivateKeyFile: edPrivateFile}, wantSign: true},
+		{name: "EdDSA public only", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmEdDSA, PublicKeyFile: edPublicFile}},
+		{name: "missing id", cfg: KeyConfig{Algorithm: AlgorithmHS256, Secret: "secret"}, wantErr: true},
+		{name: "HS256 without secret", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmHS256}, wantErr: true},
+		{name: "RS256 without file", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmRS256}, wantErr: true},
+		{name: "EdDSA file of a RSA key", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmEdDSA, PrivateKeyFile: rsPrivateFile}, wantErr: true},
+		{name: "missing file", cfg: KeyConfig{Id: "a", Algorithm: AlgorithmRS256, PrivateKeyFile: filepath.Join(dir, "missing.pem")}, wantErr: true},
+		{name: "unsupported algorithm", cfg: KeyConfig{Id: "a", Algorithm: "none"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadKey(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadKey error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if key.canSign() != tt.wantSign {
+				t.Errorf("canSign = %v, want %v", key.canSign(), tt.wantSign)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+// Package totp implement time based one time passwords (RFC 6238) with the parameters every
+// authenticator app support: HMAC-SHA1, 6 digits and 30 seconds steps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	// secretSize is the key length recommended by RFC 4226 for HMAC-SHA1
+	secretSize = 20
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret return a random key encoded in base32, the format authenticator apps expect
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return encoding.EncodeToString(b), nil
+}
+
+// Step return the time step of t, codes of the same step are equal
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code return the code of secret at time t
+func Code(secret string, t time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	return hotp(key, uint64(Step(t)), Digits), nil
+}
+
+// Validate check code against the steps from skew before to skew after t, so a slow user or a
+// drifting clock is still accepted. The matching step is returned, callers must remember it and
+// refuse codes of the same or an older step so a code can not be replayed.
+func Validate(secret string, code string, t time.Time, skew int) (step int64, ok bool, err error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if len(code) != Digits {
+		return 0, false, nil
+	}
+
+	current := Step(t)
+	for i := -skew; i <= skew; i++ {
+		candidate := current + int64(i)
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(candidate), Digits)), []byte(code)) == 1 {
+			return candidate, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// URI build the otpauth:// key uri shown as QR code to enroll an authenticator app
+func URI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(Digits))
+	query.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	label := url.PathEscape(issuer + ":" + account)
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return nil, fmt.Errorf("totp: invalid secret: %w", err)
+	}
+
+	return key, nil
+}
+
+// hotp is the HMAC based one time password of RFC 4226
+func hotp(key []byte, counter uint64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
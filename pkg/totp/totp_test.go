@@ -0,0 +1,109 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// key of the RFC 4226 and RFC 6238 test vectors
+const rfcKey = "12345678901234567890"
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		if got := hotp([]byte(rfcKey), uint64(counter), 6); got != code {
+			t.Errorf("hotp(%d) = %s, want %s", counter, got, code)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B, SHA1 with 8 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		step := Step(time.Unix(tt.unix, 0))
+		if got := hotp([]byte(rfcKey), uint64(step), 8); got != tt.want {
+			t.Errorf("totp(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(rfcKey))
+	now := time.Unix(1111111111, 0)
+
+	codeAt := func(t time.Time) string {
+		code, _ := Code(secret, t)
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantOk   bool
+		wantStep int64
+	}{
+		{name: "current step", code: codeAt(now), skew: 1, wantOk: true, wantStep: Step(now)},
+		{name: "previous step within skew", code: codeAt(now.Add(-Period)), skew: 1, wantOk: true, wantStep: Step(now) - 1},
+		{name: "next step within skew", code: codeAt(now.Add(Period)), skew: 1, wantOk: true, wantStep: Step(now) + 1},
+		{name: "outside skew", code: codeAt(now.Add(-2 * Period)), skew: 1},
+		{name: "no skew", code: codeAt(now.Add(-Period)), skew: 0},
+		{name: "wrong length", code: codeAt(now)[:5], skew: 1},
+		{name: "wrong code", code: "000000", skew: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok, err := Validate(secret, tt.code, now, tt.skew)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.wantOk || step != tt.wantStep {
+				t.Errorf("Validate = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOk)
+			}
+		})
+	}
+
+	if _, _, err := Validate("not base32!", "123456", now, 1); err == nil {
+		t.Error("invalid secret must return an error")
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// lower case and padded secrets typed by hand are accepted too
+	for _, s := range []string{secret, strings.ToLower(secret)} {
+		if _, err = Code(s, time.Now()); err != nil {
+			t.Errorf("Code(%q) error %v", s, err)
+		}
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Acme Corp", "alice", "JBSWY3DPEHPK3PXP")
+	want := "otpauth://totp/Acme%20Corp:alice?algorithm=SHA1&digits=6&issuer=Acme+Corp&period=30&secret=JBSWY3DPEHPK3PXP"
+
+	if got != want {
+		t.Errorf("URI = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+// Package workqueue run background jobs with a fixed number of workers and a bounded queue, so a
+// burst of requests can not start an unbounded number of goroutines. Stop refuse new jobs and wait
+// for the queued ones, register it so it is stopped before what the jobs use (database, ...).
+package workqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrFull    = errors.New("workqueue: queue is full")
+	ErrStopped = errors.New("workqueue: stopped")
+)
+
+// Job get a context with the values of the one given to Submit, cancelled only when Stop give up
+// waiting
+type Job func(ctx context.Context)
+
+type queued struct {
+	ctx context.Context
+	job Job
+}
+
+type Queue struct {
+	workers int
+	jobs    chan queued
+
+	mu      sync.Mutex
+	started bool
+	stopped bool
+	wg      sync.WaitGroup
+	// ctx is cancelled when Stop time out, running jobs should give up
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New create a queue of workers goroutines (at least 1) holding up to size waiting jobs
+func New(workers, size int) *Queue {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Queue{
+		workers: max(workers, 1),
+		jobs:    make(chan queued, max(size, 0)),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Submit queue the job without waiting, ErrFull when every worker is busy and the queue is full
+func (q *Queue) Submit(ctx context.Context, job Job) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.stopped {
+		return ErrStopped
+	}
+
+	select {
+	case q.jobs <- queued{ctx: context.WithoutCancel(ctx), job: job}:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+// Start the workers, jobs submitted before wait in the queue
+func (q *Queue) Start(ctx context.Context) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.started {
+		return errors.New("workqueue: already started")
+	}
+	q.started = true
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	return nil
+}
+
+func (q *Queue) work() {
+	defer q.wg.Done()
+
+	for item := range q.jobs {
+		q.run(item)
+	}
+}
+
+func (q *Queue) run(item queued) {
+	ctx, cancel := context.WithCancel(item.ctx)
+	stop := context.AfterFunc(q.ctx, cancel)
+	defer func() {
+		stop()
+		cancel()
+	}()
+
+	item.job(ctx)
+}
+
+// Stop refuse new jobs and wait until the queued ones are done. When ctx end first the running jobs
+// are cancelled, the jobs still waiting are dropped and ctx error is returned.
+func (q *Queue) Stop(ctx context.Context) error {
+	q.mu.Lock()
+	if q.stopped {
+		q.mu.Unlock()
+		return nil
+	}
+	q.stopped = true
+	close(q.jobs)
+	started := q.started
+	q.mu.Unlock()
+
+	if !started {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		q.cancel()
+		// drain so the workers end after their current job
+		for range q.jobs {
+		}
+		return ctx.Err()
+	}
+}
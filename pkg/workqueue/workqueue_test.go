@@ -0,0 +1,93 @@
+package workqueue
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func TestQueue(t *testing.T) {
+	q := New(2, 10)
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var done atomic.Int32
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	for i := 0; i < 10; i++ {
+		// the submitter context may already be cancelled (request answered), its values are kept
+		if err := q.Submit(cancelled, func(ctx context.Context) {
+			time.Sleep(time.Millisecond)
+			if ctx.Value(ctxKey{}) == "request" && ctx.Err() == nil {
+				done.Add(1)
+			}
+		}); err != nil {
+			t.Fatalf("submit %d: %v", i, err)
+		}
+	}
+
+	if err := q.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := done.Load(); got != 10 {
+		t.Errorf("jobs done before Stop returned = %d, want 10", got)
+	}
+
+	if err := q.Submit(ctx, func(context.Context) {}); !errors.Is(err, ErrStopped) {
+		t.Errorf("submit after stop = %v, want ErrStopped", err)
+	}
+	if err := q.Stop(context.Background()); err != nil {
+		t.Errorf("second stop = %v, want nil", err)
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	q := New(1, 1)
+
+	// not started, the only slot is taken by the first job
+	if err := q.Submit(context.Background(), func(context.Context) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Submit(context.Background(), func(context.Context) {}); !errors.Is(err, ErrFull) {
+		t.Errorf("submit over the size = %v, want ErrFull", err)
+	}
+
+	if err := q.Stop(context.Background()); err != nil {
+		t.Errorf("stop of a queue never started = %v, want nil", err)
+	}
+}
+
+func TestQueueStopTimeout(t *testing.T) {
+	q := New(1, 1)
+	if err := q.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	running := make(chan struct{})
+	cancelled := make(chan struct{})
+	q.Submit(context.Background(), func(ctx context.Context) {
+		close(running)
+		<-ctx.Done()
+		close(cancelled)
+	})
+	<-running
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := q.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("stop = %v, want deadline exceeded", err)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("running job not cancelled after the stop timeout")
+	}
+}